@@ -61,7 +61,19 @@ func (src *VSphereCluster) ConvertTo(dstRaw conversion.Hub) error { // nolint
 	if restored.Spec.Thumbprint != "" {
 		dst.Spec.Thumbprint = restored.Spec.Thumbprint
 	}
+	if restored.Spec.CABundle != nil {
+		dst.Spec.CABundle = restored.Spec.CABundle
+	}
+	if restored.Spec.CASecretRef != nil {
+		dst.Spec.CASecretRef = restored.Spec.CASecretRef
+	}
+	if restored.Spec.IdentityRef != nil {
+		dst.Spec.IdentityRef = restored.Spec.IdentityRef
+	}
+	dst.Spec.FailureDomains = restored.Spec.FailureDomains
 
+	dst.Status.FailureDomains = restored.Status.FailureDomains
+	dst.Status.ControlPlaneAntiAffinityHash = restored.Status.ControlPlaneAntiAffinityHash
 	dst.Status.Conditions = restored.Status.Conditions
 
 	return nil
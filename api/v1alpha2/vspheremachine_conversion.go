@@ -37,6 +37,7 @@ func (src *VSphereMachine) ConvertTo(dstRaw conversion.Hub) error { // nolint
 	}
 
 	dst.Spec.VirtualMachineCloneSpec = restored.Spec.VirtualMachineCloneSpec
+	dst.Status.Template = restored.Status.Template
 	dst.Status.Conditions = restored.Status.Conditions
 
 	return nil
@@ -128,3 +129,13 @@ func Convert_v1alpha3_VSphereMachineStatus_To_v1alpha2_VSphereMachineStatus(in *
 
 	return nil
 }
+
+// Convert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine converts from the Hub version (v1alpha3) of the VirtualMachine to this version.
+func Convert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine(in *infrav1alpha3.VirtualMachine, out *VirtualMachine, s apiconversion.Scope) error { // nolint
+	return autoConvert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine(in, out, s)
+}
+
+// Convert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec converts from the Hub version (v1alpha3) of the NetworkDeviceSpec to this version.
+func Convert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(in *infrav1alpha3.NetworkDeviceSpec, out *NetworkDeviceSpec, s apiconversion.Scope) error { // nolint
+	return autoConvert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(in, out, s)
+}
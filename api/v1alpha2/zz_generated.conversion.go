@@ -142,11 +142,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1alpha3.NetworkDeviceSpec)(nil), (*NetworkDeviceSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(a.(*v1alpha3.NetworkDeviceSpec), b.(*NetworkDeviceSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*NetworkRouteSpec)(nil), (*v1alpha3.NetworkRouteSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha2_NetworkRouteSpec_To_v1alpha3_NetworkRouteSpec(a.(*NetworkRouteSpec), b.(*v1alpha3.NetworkRouteSpec), scope)
 	}); err != nil {
@@ -262,11 +257,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1alpha3.VirtualMachine)(nil), (*VirtualMachine)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine(a.(*v1alpha3.VirtualMachine), b.(*VirtualMachine), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddConversionFunc((*CPICloudConfig)(nil), (*v1alpha3.CPICloudConfig)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha2_CPICloudConfig_To_v1alpha3_CPICloudConfig(a.(*CPICloudConfig), b.(*v1alpha3.CPICloudConfig), scope)
 	}); err != nil {
@@ -297,6 +287,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1alpha3.NetworkDeviceSpec)(nil), (*NetworkDeviceSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(a.(*v1alpha3.NetworkDeviceSpec), b.(*NetworkDeviceSpec), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1alpha3.VSphereClusterSpec)(nil), (*VSphereClusterSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_VSphereClusterSpec_To_v1alpha2_VSphereClusterSpec(a.(*v1alpha3.VSphereClusterSpec), b.(*VSphereClusterSpec), scope)
 	}); err != nil {
@@ -317,6 +312,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1alpha3.VirtualMachine)(nil), (*VirtualMachine)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine(a.(*v1alpha3.VirtualMachine), b.(*VirtualMachine), scope)
+	}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -670,6 +670,10 @@ func Convert_v1alpha2_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(in *Networ
 
 func autoConvert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(in *v1alpha3.NetworkDeviceSpec, out *NetworkDeviceSpec, s conversion.Scope) error {
 	out.NetworkName = in.NetworkName
+	// WARNING: in.PortGroupKey requires manual conversion: does not exist in peer-type
+	// WARNING: in.DVSUUID requires manual conversion: does not exist in peer-type
+	// WARNING: in.SegmentID requires manual conversion: does not exist in peer-type
+	// WARNING: in.AdapterType requires manual conversion: does not exist in peer-type
 	out.DeviceName = in.DeviceName
 	out.DHCP4 = in.DHCP4
 	out.DHCP6 = in.DHCP6
@@ -684,11 +688,6 @@ func autoConvert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(in *v1
 	return nil
 }
 
-// Convert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec is an autogenerated conversion function.
-func Convert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(in *v1alpha3.NetworkDeviceSpec, out *NetworkDeviceSpec, s conversion.Scope) error {
-	return autoConvert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(in, out, s)
-}
-
 func autoConvert_v1alpha2_NetworkRouteSpec_To_v1alpha3_NetworkRouteSpec(in *NetworkRouteSpec, out *v1alpha3.NetworkRouteSpec, s conversion.Scope) error {
 	out.To = in.To
 	out.Via = in.Via
@@ -714,7 +713,17 @@ func Convert_v1alpha3_NetworkRouteSpec_To_v1alpha2_NetworkRouteSpec(in *v1alpha3
 }
 
 func autoConvert_v1alpha2_NetworkSpec_To_v1alpha3_NetworkSpec(in *NetworkSpec, out *v1alpha3.NetworkSpec, s conversion.Scope) error {
-	out.Devices = *(*[]v1alpha3.NetworkDeviceSpec)(unsafe.Pointer(&in.Devices))
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make([]v1alpha3.NetworkDeviceSpec, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha2_NetworkDeviceSpec_To_v1alpha3_NetworkDeviceSpec(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Devices = nil
+	}
 	out.Routes = *(*[]v1alpha3.NetworkRouteSpec)(unsafe.Pointer(&in.Routes))
 	out.PreferredAPIServerCIDR = in.PreferredAPIServerCIDR
 	return nil
@@ -726,7 +735,17 @@ func Convert_v1alpha2_NetworkSpec_To_v1alpha3_NetworkSpec(in *NetworkSpec, out *
 }
 
 func autoConvert_v1alpha3_NetworkSpec_To_v1alpha2_NetworkSpec(in *v1alpha3.NetworkSpec, out *NetworkSpec, s conversion.Scope) error {
-	out.Devices = *(*[]NetworkDeviceSpec)(unsafe.Pointer(&in.Devices))
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = make([]NetworkDeviceSpec, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha3_NetworkDeviceSpec_To_v1alpha2_NetworkDeviceSpec(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.Devices = nil
+	}
 	out.Routes = *(*[]NetworkRouteSpec)(unsafe.Pointer(&in.Routes))
 	out.PreferredAPIServerCIDR = in.PreferredAPIServerCIDR
 	return nil
@@ -855,11 +874,15 @@ func autoConvert_v1alpha3_VSphereClusterSpec_To_v1alpha2_VSphereClusterSpec(in *
 	out.Server = in.Server
 	out.Insecure = (*bool)(unsafe.Pointer(in.Insecure))
 	// WARNING: in.Thumbprint requires manual conversion: does not exist in peer-type
+	// WARNING: in.CABundle requires manual conversion: does not exist in peer-type
+	// WARNING: in.CASecretRef requires manual conversion: does not exist in peer-type
 	if err := Convert_v1alpha3_CPIConfig_To_v1alpha2_CPIConfig(&in.CloudProviderConfiguration, &out.CloudProviderConfiguration, s); err != nil {
 		return err
 	}
 	// WARNING: in.ControlPlaneEndpoint requires manual conversion: does not exist in peer-type
 	// WARNING: in.LoadBalancerRef requires manual conversion: does not exist in peer-type
+	// WARNING: in.IdentityRef requires manual conversion: does not exist in peer-type
+	// WARNING: in.FailureDomains requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -871,6 +894,8 @@ func autoConvert_v1alpha2_VSphereClusterStatus_To_v1alpha3_VSphereClusterStatus(
 
 func autoConvert_v1alpha3_VSphereClusterStatus_To_v1alpha2_VSphereClusterStatus(in *v1alpha3.VSphereClusterStatus, out *VSphereClusterStatus, s conversion.Scope) error {
 	out.Ready = in.Ready
+	// WARNING: in.FailureDomains requires manual conversion: does not exist in peer-type
+	// WARNING: in.ControlPlaneAntiAffinityHash requires manual conversion: does not exist in peer-type
 	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
@@ -983,6 +1008,7 @@ func autoConvert_v1alpha3_VSphereMachineStatus_To_v1alpha2_VSphereMachineStatus(
 	out.Network = *(*[]NetworkStatus)(unsafe.Pointer(&in.Network))
 	// WARNING: in.FailureReason requires manual conversion: does not exist in peer-type
 	// WARNING: in.FailureMessage requires manual conversion: does not exist in peer-type
+	// WARNING: in.Template requires manual conversion: does not exist in peer-type
 	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
@@ -1118,10 +1144,6 @@ func autoConvert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine(in *v1alpha3
 	out.BiosUUID = in.BiosUUID
 	out.State = VirtualMachineState(in.State)
 	out.Network = *(*[]NetworkStatus)(unsafe.Pointer(&in.Network))
+	// WARNING: in.Disks requires manual conversion: does not exist in peer-type
 	return nil
 }
-
-// Convert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine is an autogenerated conversion function.
-func Convert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine(in *v1alpha3.VirtualMachine, out *VirtualMachine, s conversion.Scope) error {
-	return autoConvert_v1alpha3_VirtualMachine_To_v1alpha2_VirtualMachine(in, out, s)
-}
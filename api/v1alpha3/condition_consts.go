@@ -47,6 +47,34 @@ const (
 	// while installing the container storage interface  addon; those kind of errors are usually transient
 	// the operation is automatically re-tried by the controller.
 	CSIProvisioningFailedReason = "CSIProvisioningFailed"
+
+	// ControlPlaneAntiAffinityCondition documents the status of the DRS anti-affinity rules that keep the
+	// VSphereCluster's control plane VMs on separate ESXi hosts of each compute cluster.
+	ControlPlaneAntiAffinityCondition clusterv1.ConditionType = "ControlPlaneAntiAffinity"
+
+	// AntiAffinityRuleFailedReason (Severity=Warning) documents a VSphereCluster controller detecting
+	// an error while reconciling the anti-affinity rules of the control plane VMs; those kind of errors are
+	// usually transient and the operation is automatically re-tried by the controller.
+	AntiAffinityRuleFailedReason = "AntiAffinityRuleFailed"
+)
+
+// Conditions and condition Reasons for the VSphereClusterIdentity object.
+
+const (
+	// CredentialsAvailableCondition documents the status of the credentials referenced by a VSphereClusterIdentity.
+	CredentialsAvailableCondition clusterv1.ConditionType = "CredentialsAvailable"
+
+	// SecretNotAvailableReason (Severity=Warning) documents a VSphereClusterIdentity whose Secret cannot be found
+	// in the controller manager namespace.
+	SecretNotAvailableReason = "SecretNotAvailable"
+
+	// SecretOwnerReferenceFailedReason (Severity=Warning) documents a VSphereClusterIdentity controller failing
+	// to set itself as the owner of the referenced Secret; the operation is automatically re-tried by the controller.
+	SecretOwnerReferenceFailedReason = "SecretOwnerReferenceFailed"
+
+	// SecretAlreadyInUseReason (Severity=Warning) documents a VSphereClusterIdentity referencing a Secret that
+	// is already owned by another VSphereClusterIdentity.
+	SecretAlreadyInUseReason = "SecretAlreadyInUse"
 )
 
 // Conditions and condition Reasons for the VSphereMachine and the VSphereVM object.
@@ -70,9 +98,19 @@ const (
 	// NOTE: This reason does not apply to VSphereVM (this state happens before the VSphereVM is actually created).
 	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
 
+	// TemplateNotFoundReason (Severity=Warning) documents a VSphereMachine whose TemplateSelector does not
+	// select exactly one template for the Machine's Kubernetes version.
+	//
+	// NOTE: This reason does not apply to VSphereVM (this state happens before the VSphereVM is actually created).
+	TemplateNotFoundReason = "TemplateNotFound"
+
 	// CloningReason documents (Severity=Info) a VSphereMachine/VSphereVM currently executing the clone operation.
 	CloningReason = "Cloning"
 
+	// WaitingForCapacityReason (Severity=Info) documents a VSphereMachine/VSphereVM waiting for one of the
+	// in-flight clone operations on the vSphere server to complete before starting its own clone operation.
+	WaitingForCapacityReason = "WaitingForCapacity"
+
 	// CloningFailedReason (Severity=Warning) documents a VSphereMachine/VSphereVM controller detecting
 	// an error while provisioning; those kind of errors are usually transient and failed provisioning
 	// are automatically re-tried by the controller.
@@ -86,6 +124,10 @@ const (
 	// are automatically re-tried by the controller.
 	PoweringOnFailedReason = "PoweringOnFailed"
 
+	// ShuttingDownGuestReason documents (Severity=Info) a VSphereMachine/VSphereVM whose guest is being shut down
+	// before the VM is destroyed; the VM is powered off if the guest does not shut down within the shutdown timeout.
+	ShuttingDownGuestReason = "ShuttingDownGuest"
+
 	// TaskFailure (Severity=Warning) documents a VSphereMachine/VSphere task failure; the reconcile look will automatically
 	// retry the operation, but a user intervention might be required to fix the problem.
 	TaskFailure = "TaskFailure"
@@ -96,3 +138,50 @@ const (
 	// NOTE: This reason does not apply to VSphereVM (this state happens after the VSphereVM is in ready state).
 	WaitingForNetworkAddressesReason = "WaitingForNetworkAddresses"
 )
+
+// Conditions and condition Reasons for the VSphereVM object.
+
+const (
+	// StoragePolicyCompliantCondition documents whether the datastores of a VSphereVM are compatible with its
+	// storage policy. The condition is only set when the VSphereVM has a storage policy.
+	StoragePolicyCompliantCondition clusterv1.ConditionType = "StoragePolicyCompliant"
+
+	// StoragePolicyNotCompliantReason (Severity=Warning) documents a VSphereVM with one or more datastores that
+	// are not compatible with its storage policy.
+	StoragePolicyNotCompliantReason = "StoragePolicyNotCompliant"
+
+	// StoragePolicyCheckFailedReason (Severity=Warning) documents a VSphereVM controller detecting an error
+	// while checking the compliance of a VSphereVM with its storage policy.
+	StoragePolicyCheckFailedReason = "StoragePolicyCheckFailed"
+
+	// VMResizedCondition documents whether the VM of a VSphereVM has the number of CPUs and the memory size of
+	// its spec. The condition is only set when the VSphereVM has in-place resize enabled, and it is reflected on
+	// the VSphereMachine.
+	VMResizedCondition clusterv1.ConditionType = "VMResized"
+
+	// ResizingReason (Severity=Info) documents a VSphereVM whose VM is being reconfigured with the number of CPUs
+	// and the memory size of its spec.
+	ResizingReason = "Resizing"
+
+	// ShuttingDownGuestForResizeReason (Severity=Info) documents a VSphereVM whose guest is being shut down since
+	// its CPUs or memory cannot be hot-added; the VM is powered off if the guest does not shut down within the
+	// shutdown timeout.
+	ShuttingDownGuestForResizeReason = "ShuttingDownGuestForResize"
+
+	// PoweringOffForResizeReason (Severity=Info) documents a VSphereVM whose VM is being powered off since its
+	// CPUs or memory cannot be hot-added; the VM is powered on again once it is resized.
+	PoweringOffForResizeReason = "PoweringOffForResize"
+
+	// ResizeFailedReason (Severity=Warning) documents a VSphereVM controller detecting an error while resizing
+	// the VM; those kind of errors are usually transient and the operation is automatically re-tried by the controller.
+	ResizeFailedReason = "ResizeFailed"
+
+	// LinkedCloneCondition documents whether the VM of a VSphereVM that requests a linked clone is a linked clone.
+	// The condition is only set when the VSphereVM requests the LinkedClone clone mode, and it is reflected on
+	// the VSphereMachine.
+	LinkedCloneCondition clusterv1.ConditionType = "LinkedClone"
+
+	// LinkedCloneFallbackReason (Severity=Warning) documents a VSphereVM whose VM is a full clone rather than a
+	// linked clone, since its template has no snapshot from which to create a linked clone.
+	LinkedCloneFallbackReason = "LinkedCloneFallback"
+)
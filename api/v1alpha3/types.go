@@ -18,6 +18,8 @@ package v1alpha3
 
 import (
 	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -47,14 +49,147 @@ const (
 	// clone mode, but it also prevents expanding a VMs disk beyond the size of
 	// the source VM/template.
 	LinkedClone CloneMode = "linkedClone"
+
+	// InstantClone means resulting VMs are forked from the running source VM
+	// and share its memory and disk state, so they do not need to boot. The
+	// source VM must be powered on, and should be frozen by its guest so that
+	// the forked VMs resume with the guest waiting for their network identity
+	// and bootstrap data.
+	InstantClone CloneMode = "instantClone"
+)
+
+// BootstrapFormat is the format of a machine's bootstrap data.
+type BootstrapFormat string
+
+const (
+	// CloudConfigBootstrapFormat indicates the bootstrap data is a
+	// cloud-config document processed by cloud-init.
+	CloudConfigBootstrapFormat BootstrapFormat = "cloud-config"
+
+	// IgnitionBootstrapFormat indicates the bootstrap data is an Ignition
+	// config, as used by Flatcar Container Linux and Fedora CoreOS.
+	IgnitionBootstrapFormat BootstrapFormat = "ignition"
+)
+
+// BootstrapTransport is the way in which a machine's bootstrap data and
+// metadata are provided to its guest.
+type BootstrapTransport string
+
+const (
+	// GuestInfoBootstrapTransport indicates the bootstrap data and metadata
+	// are provided as guestinfo extra config keys, which are read by the
+	// VMware guestinfo datasource of cloud-init.
+	GuestInfoBootstrapTransport BootstrapTransport = "guestInfo"
+
+	// NoCloudBootstrapTransport indicates the bootstrap data and metadata
+	// are provided on an ISO attached to the VM as a CD-ROM, which is read
+	// by the NoCloud datasource of cloud-init.
+	NoCloudBootstrapTransport BootstrapTransport = "noCloud"
+
+	// VAppBootstrapTransport indicates the bootstrap data and metadata are
+	// provided as the template's vApp properties in the OVF environment,
+	// which are read by the OVF datasource of cloud-init.
+	VAppBootstrapTransport BootstrapTransport = "vApp"
+)
+
+// DiskProvisioningType is the provisioning type of a virtual disk.
+type DiskProvisioningType string
+
+const (
+	// ThinProvisioning allocates the disk's storage on demand, as data is
+	// written to the disk.
+	ThinProvisioning DiskProvisioningType = "thin"
+
+	// ThickProvisioning allocates all of the disk's storage when the disk is
+	// created, and zeroes it on first write.
+	ThickProvisioning DiskProvisioningType = "thick"
+
+	// EagerZeroedThickProvisioning allocates and zeroes all of the disk's
+	// storage when the disk is created. This is the slowest provisioning type,
+	// but it has the best first-write performance.
+	EagerZeroedThickProvisioning DiskProvisioningType = "eagerZeroedThick"
+)
+
+// Firmware is the firmware with which a VM boots.
+type Firmware string
+
+const (
+	// BIOSFirmware indicates the VM boots with legacy BIOS firmware.
+	BIOSFirmware Firmware = "bios"
+
+	// EFIFirmware indicates the VM boots with UEFI firmware, which is
+	// required for Secure Boot and a virtual TPM.
+	EFIFirmware Firmware = "efi"
 )
 
+// SharesLevel is the level of the shares of a VM's CPU or memory allocation,
+// which determines its priority relative to other VMs under contention.
+type SharesLevel string
+
+const (
+	// LowSharesLevel allocates half as many shares as NormalSharesLevel.
+	LowSharesLevel SharesLevel = "low"
+
+	// NormalSharesLevel allocates shares in proportion to the VM's number of
+	// CPUs or size of memory.
+	NormalSharesLevel SharesLevel = "normal"
+
+	// HighSharesLevel allocates twice as many shares as NormalSharesLevel.
+	HighSharesLevel SharesLevel = "high"
+
+	// CustomSharesLevel allocates the number of shares in SharesSpec.Value.
+	CustomSharesLevel SharesLevel = "custom"
+)
+
+// TemplateSelector selects a template by the vSphere tags and custom
+// attributes assigned to it. A tag is matched by the name of its category
+// and a custom attribute by its name, so the tag "ubuntu-2004" in the
+// category "os" and a custom attribute "os" with the value "ubuntu-2004"
+// both match the label "os: ubuntu-2004".
+type TemplateSelector struct {
+	// MatchLabels are the tags and custom attributes a template must have to
+	// be selected.
+	// +optional
+	MatchLabels map[string]string `json:"matchLabels,omitempty"`
+
+	// VersionKey is the tag category or custom attribute whose value is
+	// matched against the Machine's Kubernetes version. The leading "v" of
+	// both versions is ignored.
+	// Defaults to "k8s-version".
+	// +optional
+	VersionKey string `json:"versionKey,omitempty"`
+}
+
+// DefaultTemplateVersionKey is the tag category or custom attribute matched
+// against the Machine's Kubernetes version when a TemplateSelector does not
+// specify a VersionKey.
+const DefaultTemplateVersionKey = "k8s-version"
+
 // VirtualMachineCloneSpec is information used to clone a virtual machine.
 type VirtualMachineCloneSpec struct {
 	// Template is the name or inventory path of the template used to clone
-	// the virtual machine.
-	// +kubebuilder:validation:MinLength=1
-	Template string `json:"template"`
+	// the virtual machine. When ContentLibrary is set, Template is the name
+	// of the library item used to deploy the virtual machine instead.
+	// Template and TemplateSelector are mutually exclusive.
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// TemplateSelector selects the template used to clone the virtual
+	// machine by its vSphere tags and custom attributes rather than by name.
+	// The VSphereMachine controller resolves the selector to the template
+	// whose version matches the Machine's Kubernetes version when it creates
+	// the VSphereVM, so TemplateSelector is only supported by VSphereMachines.
+	// +optional
+	TemplateSelector *TemplateSelector `json:"templateSelector,omitempty"`
+
+	// ContentLibrary is the name or ID of the Content Library that contains
+	// the Template, which may be an OVF template or a VM template. Items of
+	// subscribed libraries are synchronized before they are deployed.
+	// Since library items are deployed rather than cloned, the CloneMode and
+	// Snapshot fields are ignored, and the Datastore may not be a datastore
+	// cluster. The ProvisioningType is only applied to OVF templates.
+	// +optional
+	ContentLibrary string `json:"contentLibrary,omitempty"`
 
 	// CloneMode specifies the type of clone operation.
 	// The LinkedClone mode is only support for templates that have at least
@@ -62,29 +197,89 @@ type VirtualMachineCloneSpec struct {
 	// to FullClone.
 	// When LinkedClone mode is enabled the DiskGiB field is ignored as it is
 	// not possible to expand disks of linked clones.
+	// The InstantClone mode forks the VM from the running VM named by the
+	// Template. The network devices of the source VM are connected to the
+	// networks of the VM, so the source VM must have as many network devices
+	// as the VM. The DiskGiB, NumCPUs, NumCoresPerSocket and MemoryMiB fields
+	// are ignored, since instant clones inherit them from the source VM, and
+	// the ContentLibrary, Snapshot, AdditionalDisks and InPlaceResize fields
+	// may not be set. Neither may the bootstrap data of instant clones be
+	// Ignition configs.
 	// Defaults to LinkedClone, but fails gracefully to FullClone if the source
 	// of the clone operation has no snapshots.
+	// +kubebuilder:validation:Enum=fullClone;linkedClone;instantClone
 	// +optional
 	CloneMode CloneMode `json:"cloneMode,omitempty"`
 
 	// Snapshot is the name of the snapshot from which to create a linked clone.
 	// This field is ignored if LinkedClone is not enabled.
-	// Defaults to the source's current snapshot.
+	// Defaults to the source's current snapshot, or to "capv-linked-clone"
+	// if ManageSnapshot is set.
 	// +optional
 	Snapshot string `json:"snapshot,omitempty"`
 
+	// ManageSnapshot makes the controller linked clone the virtual machine
+	// from the snapshot named by Snapshot of a base virtual machine, rather
+	// than from a snapshot of the template. The template is cloned once per
+	// datastore to a base virtual machine named
+	// "<template>-<datastore>-<version>", where version is derived from the
+	// template's change version, and the snapshot is created on the base
+	// virtual machine. The template itself is never modified, and a new base
+	// virtual machine is created whenever the template changes. On the
+	// template's own datastore, a template that has the snapshot, or any
+	// snapshot if Snapshot is not set, is linked cloned from directly.
+	// May only be set with the LinkedClone clone mode.
+	// +optional
+	ManageSnapshot bool `json:"manageSnapshot,omitempty"`
+
+	// BootstrapFormat is the format of the bootstrap data. Ignition configs
+	// are provided to the guest with the machine's network configuration as
+	// systemd-networkd units, rather than as cloud-init metadata.
+	// Defaults to the format in the "format" key of the bootstrap data
+	// secret, or to cloud-config if the secret has no format.
+	// +kubebuilder:validation:Enum=cloud-config;ignition
+	// +optional
+	BootstrapFormat BootstrapFormat `json:"bootstrapFormat,omitempty"`
+
+	// BootstrapTransport is the way in which the bootstrap data and metadata
+	// are provided to the guest. The NoCloud transport uploads an ISO to the
+	// VM's datastore and attaches it to the VM as a CD-ROM, which is detached
+	// and deleted once the VM reports its IP addresses. It may not be used
+	// with Content Library templates.
+	// The VApp transport sets the template's "user-data", "instance-id",
+	// "local-hostname" and "network-config" vApp properties, of which only
+	// "user-data" is required.
+	// Neither transport may be used with Ignition bootstrap data or instant
+	// clones.
+	// Defaults to GuestInfo.
+	// +kubebuilder:validation:Enum=guestInfo;noCloud;vApp
+	// +optional
+	BootstrapTransport BootstrapTransport `json:"bootstrapTransport,omitempty"`
+
 	// Server is the IP address or FQDN of the vSphere server on which
 	// the virtual machine is created/located.
 	// +optional
 	Server string `json:"server,omitempty"`
 
-	// Thumbprint is the colon-separated SHA-1 checksum of the given vCenter server's host certificate
-	// When this is set to empty, this VirtualMachine would be created
+	// Thumbprint is the colon-separated SHA-1 or SHA-256 checksum of the given vCenter server's host certificate
+	// When this, CABundle and CASecretRef are set to empty, this VirtualMachine would be created
 	// without TLS certificate validation of the communication between Cluster API Provider vSphere
 	// and the VMware vCenter server.
 	// +optional
 	Thumbprint string `json:"thumbprint,omitempty"`
 
+	// CABundle is a PEM-encoded bundle of CA certificates used to validate
+	// the vSphere server's certificate.
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+
+	// CASecretRef references a Secret in the same namespace that contains a
+	// PEM-encoded bundle of CA certificates used to validate the vSphere
+	// server's certificate. The certificates are used in addition to those in
+	// CABundle.
+	// +optional
+	CASecretRef *CASecretReference `json:"caSecretRef,omitempty"`
+
 	// Datacenter is the name or inventory path of the datacenter in which the
 	// virtual machine is created/located.
 	// +optional
@@ -95,11 +290,21 @@ type VirtualMachineCloneSpec struct {
 	// +optional
 	Folder string `json:"folder,omitempty"`
 
-	// Datastore is the name or inventory path of the datastore in which the
-	// virtual machine is created/located.
+	// Datastore is the name or inventory path of the datastore or datastore
+	// cluster in which the virtual machine is created/located. When this is a
+	// datastore cluster, the virtual machine is created in the datastore
+	// recommended by Storage DRS.
 	// +optional
 	Datastore string `json:"datastore,omitempty"`
 
+	// StoragePolicyName is the name of the storage policy applied to the
+	// virtual machine and its disks.
+	// When Datastore is not set, the virtual machine is created in the
+	// datastore compatible with the storage policy that has the most free
+	// space.
+	// +optional
+	StoragePolicyName string `json:"storagePolicyName,omitempty"`
+
 	// ResourcePool is the name or inventory path of the resource pool in which
 	// the virtual machine is created/located.
 	// +optional
@@ -124,15 +329,210 @@ type VirtualMachineCloneSpec struct {
 	// virtual machine is cloned.
 	// +optional
 	MemoryMiB int64 `json:"memoryMiB,omitempty"`
+	// InPlaceResize allows the NumCPUs, NumCoresPerSocket and MemoryMiB
+	// fields to be changed after the virtual machine is created, in which
+	// case the virtual machine is reconfigured rather than replaced.
+	// CPUs and memory are hot-added to a powered on virtual machine if its
+	// template enables CPU or memory hot-add, otherwise the virtual machine
+	// is powered off, reconfigured and powered on again.
+	// The progress of a resize is reported by the VMResized condition.
+	// May not be set with the InstantClone clone mode.
+	// +optional
+	InPlaceResize bool `json:"inPlaceResize,omitempty"`
+	// ShutdownTimeout is how long the guest of the virtual machine is given
+	// to shut down through VMware Tools when the virtual machine is deleted,
+	// after which the virtual machine is powered off. A zero timeout powers
+	// off the virtual machine without shutting down its guest.
+	// Defaults to the controller manager's --vm-shutdown-timeout flag.
+	// May be changed after the virtual machine is created.
+	// +optional
+	ShutdownTimeout *metav1.Duration `json:"shutdownTimeout,omitempty"`
+	// Resources are the reservations, limits and shares of the virtual
+	// machine's CPU and memory.
+	// Defaults to the eponymous property values in the template from which
+	// the virtual machine is cloned.
+	// +optional
+	Resources *VirtualMachineResources `json:"resources,omitempty"`
+	// Firmware is the firmware with which the virtual machine boots.
+	// Defaults to the eponymous property value in the template from which
+	// the virtual machine is cloned.
+	// +kubebuilder:validation:Enum=bios;efi
+	// +optional
+	Firmware Firmware `json:"firmware,omitempty"`
+	// SecureBoot enables UEFI Secure Boot for the virtual machine, which
+	// requires the efi firmware.
+	// Defaults to the setting of the template from which the virtual machine
+	// is cloned.
+	// +optional
+	SecureBoot bool `json:"secureBoot,omitempty"`
+	// VTPM adds a virtual TPM to the virtual machine if the template from
+	// which it is cloned does not have one. A virtual TPM requires the efi
+	// firmware and a key provider configured in vCenter, since vSphere
+	// encrypts the files of virtual machines with a virtual TPM.
+	// +optional
+	VTPM bool `json:"vtpm,omitempty"`
 	// DiskGiB is the size of a virtual machine's disk, in GiB.
 	// Defaults to the eponymous property value in the template from which the
 	// virtual machine is cloned.
+	// The disk of an existing virtual machine is grown when DiskGiB is
+	// increased, provided the FullClone clone mode or a ContentLibrary is set.
+	// The disks of linked clones, including those that fell back to full
+	// clones, are never grown.
+	// The guestinfo.disk.expanded extra config key then names the grown disks
+	// so that the guest can grow their partitions and file systems.
 	// +optional
 	DiskGiB int32 `json:"diskGiB,omitempty"`
+	// DiskIndex is the zero-based index, in device order, of the template's
+	// disk that is resized to DiskGiB. This makes it possible to clone
+	// templates with more than one disk.
+	// Defaults to 0, the template's first disk.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	DiskIndex int32 `json:"diskIndex,omitempty"`
+	// ProvisioningType is the provisioning type of the virtual machine's
+	// disks. It is only applied to the template's disks by full clones, as
+	// the disks of linked clones are backed by the template's snapshot.
+	// Defaults to the provisioning type of the template's disks.
+	// +kubebuilder:validation:Enum=thin;thick;eagerZeroedThick
+	// +optional
+	ProvisioningType DiskProvisioningType `json:"provisioningType,omitempty"`
+	// AdditionalDisks is a list of data disks that are created and attached
+	// to the virtual machine when it is cloned, in addition to the disks of
+	// the template.
+	// +optional
+	AdditionalDisks []AdditionalDiskSpec `json:"additionalDisks,omitempty"`
 	// CustomVMXKeys is a dictionary of advanced VMX options that can be set on VM
 	// Defaults to empty map
 	// +optional
 	CustomVMXKeys map[string]string `json:"customVMXKeys,omitempty"`
+	// VAppProperties is a map of the IDs of the template's vApp properties to
+	// the values set on the VM, such as the properties from which appliances
+	// read their configuration in the OVF environment. The template must
+	// have the properties.
+	// +optional
+	VAppProperties map[string]string `json:"vAppProperties,omitempty"`
+	// Tags is a list of the vSphere tags that are attached to the VM, in
+	// addition to the tags of its cluster, namespace and role, which are
+	// attached in the "capv-cluster", "capv-namespace" and "capv-role"
+	// categories. Missing tags and categories are created.
+	// +optional
+	Tags []TagSpec `json:"tags,omitempty"`
+	// CustomAttributes is a map of the names of the custom attributes that
+	// are set on the VM to their values. Missing custom attributes are
+	// defined for VMs.
+	// +optional
+	CustomAttributes map[string]string `json:"customAttributes,omitempty"`
+}
+
+// VirtualMachineResources are the allocations of a virtual machine's CPU and
+// memory.
+type VirtualMachineResources struct {
+	// CPU is the allocation of the virtual machine's CPU, in MHz.
+	// +optional
+	CPU *ResourceAllocation `json:"cpu,omitempty"`
+
+	// Memory is the allocation of the virtual machine's memory, in MiB.
+	// +optional
+	Memory *ResourceAllocation `json:"memory,omitempty"`
+}
+
+// ResourceAllocation is the allocation of a virtual machine's CPU or memory.
+// The reservation and limit are in MHz for CPU and in MiB for memory.
+type ResourceAllocation struct {
+	// Reservation is the amount of the resource that is guaranteed to the
+	// virtual machine. The virtual machine's resource pool must have enough
+	// unreserved capacity for the reservation when the virtual machine is
+	// cloned.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Reservation *int64 `json:"reservation,omitempty"`
+
+	// Limit is the maximum amount of the resource the virtual machine may
+	// use, or -1 if its use is unlimited.
+	// +optional
+	Limit *int64 `json:"limit,omitempty"`
+
+	// Shares determine the virtual machine's priority relative to other
+	// virtual machines under contention for the resource.
+	// +optional
+	Shares *SharesSpec `json:"shares,omitempty"`
+}
+
+// SharesSpec is the number of shares of a virtual machine's CPU or memory.
+type SharesSpec struct {
+	// Level is the level of the shares.
+	// +kubebuilder:validation:Enum=low;normal;high;custom
+	Level SharesLevel `json:"level"`
+
+	// Value is the number of shares. It must be set if, and only if, the
+	// level is custom.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Value *int32 `json:"value,omitempty"`
+}
+
+// TagSpec identifies a vSphere tag by its category and name.
+type TagSpec struct {
+	// Category is the name of the tag's category.
+	// +kubebuilder:validation:MinLength=1
+	Category string `json:"category"`
+
+	// Name is the name of the tag.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// AdditionalDiskSpec describes a data disk that is created and attached to a
+// virtual machine when it is cloned.
+type AdditionalDiskSpec struct {
+	// SizeGiB is the size of the disk, in GiB.
+	// Like DiskGiB, it may be increased to grow the disk of an existing
+	// virtual machine whose clone spec sets the FullClone clone mode or a
+	// ContentLibrary.
+	// +kubebuilder:validation:Minimum=1
+	SizeGiB int32 `json:"sizeGiB"`
+
+	// Datastore is the name or inventory path of the datastore in which the
+	// disk is created.
+	// Defaults to the datastore of the virtual machine.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// ProvisioningType is the provisioning type of the disk.
+	// Defaults to the ProvisioningType of the virtual machine, or thin if
+	// that is not set.
+	// +kubebuilder:validation:Enum=thin;thick;eagerZeroedThick
+	// +optional
+	ProvisioningType DiskProvisioningType `json:"provisioningType,omitempty"`
+
+	// ControllerNumber is the bus number of the template's SCSI controller to
+	// which the disk is attached.
+	// Defaults to the controller of the disk selected by DiskIndex.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3
+	// +optional
+	ControllerNumber *int32 `json:"controllerNumber,omitempty"`
+
+	// UnitNumber is the unit number of the disk on its controller. Unit
+	// number 7 is reserved for the SCSI controller itself.
+	// Defaults to the controller's first free unit number.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=15
+	// +optional
+	UnitNumber *int32 `json:"unitNumber,omitempty"`
+}
+
+// CASecretReference references a key in a Secret that contains a PEM-encoded
+// bundle of CA certificates.
+type CASecretReference struct {
+	// Name is the name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Key is the key in the Secret's data that contains the CA bundle.
+	// Defaults to "ca.crt".
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // VSphereMachineTemplateResource describes the data needed to create a VSphereMachine from a template
@@ -170,6 +570,15 @@ func (v APIEndpoint) String() string {
 	return fmt.Sprintf("%s:%d", v.Host, v.Port)
 }
 
+// RequestsFullClone returns true if the clone spec explicitly requests a full
+// clone, either with the FullClone clone mode or by deploying an item of a
+// content library. Only the disks of such virtual machines are grown, since a
+// virtual machine that falls back from the LinkedClone clone mode to a full
+// clone is not known to be a full clone until it is cloned.
+func (c *VirtualMachineCloneSpec) RequestsFullClone() bool {
+	return c.CloneMode == FullClone || c.ContentLibrary != ""
+}
+
 // NetworkSpec defines the virtual machine's network configuration.
 type NetworkSpec struct {
 	// Devices is the list of network devices used by the virtual machine.
@@ -188,12 +597,57 @@ type NetworkSpec struct {
 	PreferredAPIServerCIDR string `json:"preferredAPIServerCidr,omitempty"`
 }
 
+// NetworkAdapterType is the type of a virtual network adapter.
+type NetworkAdapterType string
+
+const (
+	// VMXNET3NetworkAdapter is the paravirtualized VMXNET 3 adapter, which
+	// requires VMware Tools or a guest driver.
+	VMXNET3NetworkAdapter NetworkAdapterType = "vmxnet3"
+
+	// E1000ENetworkAdapter is an emulated Intel 82574 adapter, which most
+	// guests support without additional drivers.
+	E1000ENetworkAdapter NetworkAdapterType = "e1000e"
+
+	// SRIOVNetworkAdapter is an SR-IOV passthrough adapter backed by a
+	// virtual function of a physical adapter of the host.
+	SRIOVNetworkAdapter NetworkAdapterType = "sriov"
+)
+
 // NetworkDeviceSpec defines the network configuration for a virtual machine's
 // network device.
 type NetworkDeviceSpec struct {
-	// NetworkName is the name of the vSphere network to which the device
-	// will be connected.
-	NetworkName string `json:"networkName"`
+	// NetworkName is the name or inventory path of the vSphere network to
+	// which the device will be connected. It is an error if more than one
+	// network matches the name and the device's other network references.
+	// Either NetworkName, PortGroupKey or SegmentID must be set.
+	// +optional
+	NetworkName string `json:"networkName,omitempty"`
+
+	// PortGroupKey is the key of the distributed port group to which the
+	// device will be connected, such as "dvportgroup-42".
+	// +optional
+	PortGroupKey string `json:"portGroupKey,omitempty"`
+
+	// DVSUUID is the UUID of the distributed switch whose port group the
+	// device will be connected to. It selects between port groups with the
+	// same name on different distributed switches.
+	// +optional
+	DVSUUID string `json:"dvsUUID,omitempty"`
+
+	// SegmentID is the ID of the NSX-T segment to which the device will be
+	// connected. It is matched against the segment ID and logical switch
+	// UUID of NSX-backed distributed port groups, and the ID of opaque
+	// networks.
+	// +optional
+	SegmentID string `json:"segmentID,omitempty"`
+
+	// AdapterType is the type of the virtual network adapter. The sriov type
+	// requires the virtual machine's memory to be fully reserved.
+	// Defaults to vmxnet3.
+	// +kubebuilder:validation:Enum=vmxnet3;e1000e;sriov
+	// +optional
+	AdapterType NetworkAdapterType `json:"adapterType,omitempty"`
 
 	// DeviceName may be used to explicitly assign a name to the network device
 	// as it exists in the guest operating system.
@@ -326,6 +780,37 @@ type VirtualMachine struct {
 
 	// Network is the status of the VM's network devices.
 	Network []NetworkStatus `json:"network"`
+
+	// Disks is the status of the VM's disks.
+	Disks []DiskStatus `json:"disks,omitempty"`
+}
+
+// DiskStatus provides information about one of a VM's disks.
+type DiskStatus struct {
+	// Label is the label of the disk device, ex. "Hard disk 1".
+	Label string `json:"label"`
+
+	// FileName is the datastore path of the disk's backing file.
+	// +optional
+	FileName string `json:"fileName,omitempty"`
+
+	// Datastore is the name of the datastore that contains the disk's
+	// backing file.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// SizeGiB is the size of the disk, in GiB.
+	SizeGiB int32 `json:"sizeGiB"`
+
+	// ProvisioningType is the provisioning type of the disk.
+	// +optional
+	ProvisioningType DiskProvisioningType `json:"provisioningType,omitempty"`
+
+	// ControllerNumber is the bus number of the disk's controller.
+	ControllerNumber int32 `json:"controllerNumber"`
+
+	// UnitNumber is the unit number of the disk on its controller.
+	UnitNumber int32 `json:"unitNumber"`
 }
 
 // SSHUser is granted remote access to a system.
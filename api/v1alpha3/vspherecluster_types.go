@@ -39,11 +39,25 @@ type VSphereClusterSpec struct {
 	// +optional
 	Insecure *bool `json:"insecure,omitempty"`
 
-	// Thumbprint is the colon-separated SHA-1 checksum of the given vCenter server's host certificate
+	// Thumbprint is the colon-separated SHA-1 or SHA-256 checksum of the given vCenter server's host certificate
 	// When provided, Insecure should not be set to true
 	// +optional
 	Thumbprint string `json:"thumbprint,omitempty"`
 
+	// CABundle is a PEM-encoded bundle of CA certificates used to validate
+	// the vSphere server's certificate.
+	// When provided, Insecure should not be set to true
+	// +optional
+	CABundle []byte `json:"caBundle,omitempty"`
+
+	// CASecretRef references a Secret in the same namespace that contains a
+	// PEM-encoded bundle of CA certificates used to validate the vSphere
+	// server's certificate. The certificates are used in addition to those in
+	// CABundle.
+	// When provided, Insecure should not be set to true
+	// +optional
+	CASecretRef *CASecretReference `json:"caSecretRef,omitempty"`
+
 	// CloudProviderConfiguration holds the cluster-wide configuration for the
 	// vSphere cloud provider.
 	CloudProviderConfiguration CPIConfig `json:"cloudProviderConfiguration,omitempty"`
@@ -59,6 +73,74 @@ type VSphereClusterSpec struct {
 	// non-empty Status.Address value.
 	// +optional
 	LoadBalancerRef *corev1.ObjectReference `json:"loadBalancerRef,omitempty"`
+
+	// IdentityRef is a reference to the identity whose credentials are used
+	// to access the vSphere endpoint for this cluster. When nil, the
+	// credentials the controller manager was started with are used.
+	// +optional
+	IdentityRef *VSphereIdentityReference `json:"identityRef,omitempty"`
+
+	// FailureDomains are the failure domains the cluster's machines may be
+	// placed in. Each failure domain is published in the VSphereCluster's
+	// Status.FailureDomains, and a Machine whose Spec.FailureDomain refers
+	// to it is placed according to the failure domain's placement fields.
+	// +optional
+	FailureDomains []VSphereFailureDomain `json:"failureDomains,omitempty"`
+}
+
+// VSphereFailureDomain maps a failure domain to the vSphere infrastructure
+// its machines are placed in: a datacenter, a compute cluster or a host group
+// of a compute cluster. The placement fields that are not set are inherited
+// from the VSphereMachine and from the cloud provider configuration's
+// Workspace.
+type VSphereFailureDomain struct {
+	// Name is the name of the failure domain, which is referenced by
+	// Machine.Spec.FailureDomain.
+	Name string `json:"name"`
+
+	// ControlPlane determines whether the failure domain is suitable for
+	// control plane machines. Defaults to true.
+	// +optional
+	ControlPlane *bool `json:"controlPlane,omitempty"`
+
+	// Datacenter is the name or inventory path of the datacenter in which
+	// the failure domain's machines are created.
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// ComputeCluster is the name or inventory path of the compute cluster in
+	// which the failure domain's machines are created. The machines are
+	// created in the compute cluster's root resource pool unless
+	// ResourcePool is set.
+	// +optional
+	ComputeCluster string `json:"computeCluster,omitempty"`
+
+	// HostGroup is the name of a DRS host group of the ComputeCluster. When
+	// set, the failure domain's machines are added to a VM group with a
+	// VM/Host affinity rule that places them on the hosts of the host group.
+	// +optional
+	HostGroup string `json:"hostGroup,omitempty"`
+
+	// ResourcePool is the name or inventory path of the resource pool in
+	// which the failure domain's machines are created.
+	// +optional
+	ResourcePool string `json:"resourcePool,omitempty"`
+
+	// Folder is the name or inventory path of the folder in which the
+	// failure domain's machines are created.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+
+	// Datastore is the name or inventory path of the datastore or datastore
+	// cluster in which the failure domain's machines are created.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// Networks are the names of the networks the failure domain's machines
+	// are connected to. The first network is used by the machine's first
+	// network device, the second by the second device, and so on.
+	// +optional
+	Networks []string `json:"networks,omitempty"`
 }
 
 // VSphereClusterStatus defines the observed state of VSphereClusterSpec
@@ -66,6 +148,17 @@ type VSphereClusterStatus struct {
 	// +optional
 	Ready bool `json:"ready,omitempty"`
 
+	// FailureDomains is a list of the failure domains that Cluster API
+	// spreads the cluster's machines across.
+	// +optional
+	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// ControlPlaneAntiAffinityHash is a hash of the control plane VSphereVMs
+	// and their BIOS UUIDs for which the anti-affinity rules were last
+	// reconciled. The rules are only reconciled again when it changes.
+	// +optional
+	ControlPlaneAntiAffinityHash string `json:"controlPlaneAntiAffinityHash,omitempty"`
+
 	// Conditions defines current service state of the VSphereCluster.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
@@ -38,16 +38,42 @@ func (r *VSphereCluster) ValidateCreate() error {
 	if spec.Thumbprint != "" && spec.Insecure != nil && *spec.Insecure {
 		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "Insecure"), spec.Insecure, "cannot be set to true at the same time as .spec.Thumbprint"))
 	}
+	if (len(spec.CABundle) > 0 || spec.CASecretRef != nil) && spec.Insecure != nil && *spec.Insecure {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "Insecure"), spec.Insecure, "cannot be set to true at the same time as .spec.caBundle or .spec.caSecretRef"))
+	}
+	allErrs = append(allErrs, validateCABundle(field.NewPath("spec", "caBundle"), spec.CABundle)...)
+	allErrs = append(allErrs, validateFailureDomains(field.NewPath("spec", "failureDomains"), spec.FailureDomains)...)
 
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *VSphereCluster) ValidateUpdate(old runtime.Object) error {
-	return nil
+	allErrs := validateFailureDomains(field.NewPath("spec", "failureDomains"), r.Spec.FailureDomains)
+	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *VSphereCluster) ValidateDelete() error {
 	return nil
 }
+
+func validateFailureDomains(fldPath *field.Path, failureDomains []VSphereFailureDomain) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, failureDomain := range failureDomains {
+		namePath := fldPath.Index(i).Child("name")
+		switch {
+		case failureDomain.Name == "":
+			allErrs = append(allErrs, field.Required(namePath, "must be set"))
+		case names[failureDomain.Name]:
+			allErrs = append(allErrs, field.Duplicate(namePath, failureDomain.Name))
+		}
+		names[failureDomain.Name] = true
+
+		if failureDomain.HostGroup != "" && failureDomain.ComputeCluster == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("computeCluster"), "must be set when hostGroup is set"))
+		}
+	}
+	return allErrs
+}
@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
 )
 
 //nolint
@@ -29,6 +30,7 @@ func TestVSphereCluster_ValidateCreate(t *testing.T) {
 	tests := []struct {
 		name           string
 		vsphereCluster *VSphereCluster
+		spec           VSphereClusterSpec
 		wantErr        bool
 	}{
 		{
@@ -46,10 +48,53 @@ func TestVSphereCluster_ValidateCreate(t *testing.T) {
 			vsphereCluster: createVSphereCluster("foo.com", true, "thumprint:foo"),
 			wantErr:        true,
 		},
+		{
+			name: "insecure false with ca secret ref",
+			spec: VSphereClusterSpec{Insecure: pointer.BoolPtr(false), CASecretRef: &CASecretReference{Name: "vcenter-ca"}},
+		},
+		{
+			name:    "insecure true with ca secret ref",
+			spec:    VSphereClusterSpec{Insecure: pointer.BoolPtr(true), CASecretRef: &CASecretReference{Name: "vcenter-ca"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid ca bundle",
+			spec:    VSphereClusterSpec{CABundle: []byte("not a certificate")},
+			wantErr: true,
+		},
+		{
+			name: "failure domains",
+			spec: VSphereClusterSpec{FailureDomains: []VSphereFailureDomain{
+				{Name: "az1", ComputeCluster: "cluster1"},
+				{Name: "az2", ComputeCluster: "cluster2", HostGroup: "hosts2"},
+			}},
+		},
+		{
+			name:    "failure domain without name",
+			spec:    VSphereClusterSpec{FailureDomains: []VSphereFailureDomain{{Datacenter: "dc1"}}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate failure domain names",
+			spec: VSphereClusterSpec{FailureDomains: []VSphereFailureDomain{
+				{Name: "az1", ComputeCluster: "cluster1"},
+				{Name: "az1", ComputeCluster: "cluster2"},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "failure domain host group without compute cluster",
+			spec:    VSphereClusterSpec{FailureDomains: []VSphereFailureDomain{{Name: "az1", HostGroup: "hosts1"}}},
+			wantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err := tc.vsphereCluster.ValidateCreate()
+			vsphereCluster := tc.vsphereCluster
+			if vsphereCluster == nil {
+				vsphereCluster = &VSphereCluster{Spec: tc.spec}
+			}
+			err := vsphereCluster.ValidateCreate()
 			if tc.wantErr {
 				g.Expect(err).To(HaveOccurred())
 			} else {
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// VSphereIdentityKind is the kind of resource a VSphereIdentityReference
+// points to.
+type VSphereIdentityKind string
+
+const (
+	// VSphereClusterIdentityKind indicates the credentials are provided by a
+	// cluster-scoped VSphereClusterIdentity.
+	VSphereClusterIdentityKind = VSphereIdentityKind("VSphereClusterIdentity")
+
+	// SecretKind indicates the credentials are provided by a Secret in the
+	// same namespace as the VSphereCluster.
+	SecretKind = VSphereIdentityKind("Secret")
+)
+
+// VSphereClusterIdentitySpec defines the desired state of VSphereClusterIdentity.
+type VSphereClusterIdentitySpec struct {
+	// SecretName references a Secret in the namespace of the controller manager
+	// that contains the "username" and "password" keys used to log into
+	// vSphere. The Secret is adopted by the VSphereClusterIdentity and is
+	// garbage collected along with it.
+	// +kubebuilder:validation:MinLength=1
+	SecretName string `json:"secretName"`
+
+	// AllowedNamespaces restricts the namespaces of the VSphereClusters that
+	// may use this identity. When nil, no namespace is allowed to use it.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+}
+
+// VSphereClusterIdentityStatus defines the observed state of VSphereClusterIdentity.
+type VSphereClusterIdentityStatus struct {
+	// Ready is true when the referenced Secret exists and has been adopted
+	// by the identity.
+	// +optional
+	Ready bool `json:"ready,omitempty"`
+
+	// Conditions defines current service state of the VSphereClusterIdentity.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// AllowedNamespaces selects the namespaces that may use an identity.
+type AllowedNamespaces struct {
+	// Selector is a label query over the namespaces that may use the
+	// identity. An empty selector matches all namespaces.
+	// +optional
+	Selector metav1.LabelSelector `json:"selector"`
+}
+
+// VSphereIdentityReference references the source of the credentials used to
+// access the vSphere endpoint of a VSphereCluster.
+type VSphereIdentityReference struct {
+	// Kind of the identity. Can be VSphereClusterIdentity or Secret.
+	// +kubebuilder:validation:Enum=VSphereClusterIdentity;Secret
+	Kind VSphereIdentityKind `json:"kind"`
+
+	// Name of the identity.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=vsphereclusteridentities,scope=Cluster,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+
+// VSphereClusterIdentity is the Schema for the vsphereclusteridentities API
+type VSphereClusterIdentity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VSphereClusterIdentitySpec   `json:"spec,omitempty"`
+	Status VSphereClusterIdentityStatus `json:"status,omitempty"`
+}
+
+func (m *VSphereClusterIdentity) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+func (m *VSphereClusterIdentity) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// VSphereClusterIdentityList contains a list of VSphereClusterIdentity
+type VSphereClusterIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VSphereClusterIdentity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VSphereClusterIdentity{}, &VSphereClusterIdentityList{})
+}
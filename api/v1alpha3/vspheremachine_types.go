@@ -91,6 +91,11 @@ type VSphereMachineStatus struct {
 	// +optional
 	FailureMessage *string `json:"failureMessage,omitempty"`
 
+	// Template is the template the VSphereMachine's TemplateSelector
+	// resolved to when its VSphereVM was created.
+	// +optional
+	Template string `json:"template,omitempty"`
+
 	// Conditions defines current service state of the VSphereMachine.
 	// +optional
 	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
@@ -52,6 +52,16 @@ func (r *VSphereMachine) ValidateCreate() error {
 			}
 		}
 	}
+	allErrs = append(allErrs, validateCABundle(field.NewPath("spec", "caBundle"), spec.CABundle)...)
+	allErrs = append(allErrs, validateAdditionalDisks(field.NewPath("spec", "additionalDisks"), spec.AdditionalDisks)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateBootstrapTransport(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateResources(field.NewPath("spec").Child("resources"), spec.Resources)...)
+	allErrs = append(allErrs, validateShutdownTimeout(field.NewPath("spec", "shutdownTimeout"), spec.ShutdownTimeout)...)
+	allErrs = append(allErrs, validateFirmware(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateNetworkDevices(field.NewPath("spec").Child("network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateTemplate(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
@@ -82,6 +92,19 @@ func (r *VSphereMachine) ValidateUpdate(old runtime.Object) error {
 	delete(oldVSphereMachineNetwork, "devices")
 	delete(newVSphereMachineNetwork, "devices")
 
+	// allow changes to the shutdown timeout
+	delete(oldVSphereMachineSpec, "shutdownTimeout")
+	delete(newVSphereMachineSpec, "shutdownTimeout")
+	allErrs = append(allErrs, validateShutdownTimeout(field.NewPath("spec", "shutdownTimeout"), r.Spec.ShutdownTimeout)...)
+
+	// allow changes to the size of the VM if it is resized in place
+	allowInPlaceResize(oldVSphereMachineSpec, newVSphereMachineSpec)
+
+	// allow the disks to be grown
+	allowDiskExpansion(oldVSphereMachineSpec, newVSphereMachineSpec)
+	allErrs = append(allErrs, validateDiskExpansion(field.NewPath("spec"), old.(*VSphereMachine).Spec.VirtualMachineCloneSpec, r.Spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec"), r.Spec.VirtualMachineCloneSpec)...)
+
 	if !reflect.DeepEqual(oldVSphereMachineSpec, newVSphereMachineSpec) {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "cannot be modified"))
 	}
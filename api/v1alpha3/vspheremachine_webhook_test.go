@@ -18,8 +18,11 @@ package v1alpha3
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
 )
 
 var (
@@ -33,6 +36,7 @@ func TestVSphereMachine_ValidateCreate(t *testing.T) {
 	tests := []struct {
 		name           string
 		vsphereMachine *VSphereMachine
+		spec           VirtualMachineCloneSpec
 		wantErr        bool
 	}{
 		{
@@ -50,10 +54,165 @@ func TestVSphereMachine_ValidateCreate(t *testing.T) {
 			vsphereMachine: createVSphereMachine("foo.com", nil, "", []string{"192.168.0.1/32", "192.168.0.3/32"}),
 			wantErr:        false,
 		},
+		{
+			name: "template selector",
+			spec: VirtualMachineCloneSpec{TemplateSelector: &TemplateSelector{MatchLabels: map[string]string{"os": "ubuntu-2004"}}},
+		},
+		{
+			name: "template and template selector",
+			spec: VirtualMachineCloneSpec{
+				Template:         "ubuntu-2004-kube-v1.19.1",
+				TemplateSelector: &TemplateSelector{MatchLabels: map[string]string{"os": "ubuntu-2004"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "instant clone",
+			spec: VirtualMachineCloneSpec{CloneMode: InstantClone},
+		},
+		{
+			name: "instant clone with additional disks",
+			spec: VirtualMachineCloneSpec{
+				CloneMode:       InstantClone,
+				AdditionalDisks: []AdditionalDiskSpec{{SizeGiB: 10}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "noCloud bootstrap transport",
+			spec: VirtualMachineCloneSpec{BootstrapTransport: NoCloudBootstrapTransport},
+		},
+		{
+			name:    "noCloud bootstrap transport with instant clone",
+			spec:    VirtualMachineCloneSpec{CloneMode: InstantClone, BootstrapTransport: NoCloudBootstrapTransport},
+			wantErr: true,
+		},
+		{
+			name:    "ignition bootstrap data with instant clone",
+			spec:    VirtualMachineCloneSpec{CloneMode: InstantClone, BootstrapFormat: IgnitionBootstrapFormat},
+			wantErr: true,
+		},
+		{
+			name:    "vApp bootstrap transport with ignition bootstrap data",
+			spec:    VirtualMachineCloneSpec{BootstrapTransport: VAppBootstrapTransport, BootstrapFormat: IgnitionBootstrapFormat},
+			wantErr: true,
+		},
+		{
+			name: "resources",
+			spec: VirtualMachineCloneSpec{Resources: &VirtualMachineResources{
+				CPU:    &ResourceAllocation{Reservation: pointer.Int64Ptr(1000), Limit: pointer.Int64Ptr(-1), Shares: &SharesSpec{Level: HighSharesLevel}},
+				Memory: &ResourceAllocation{Reservation: pointer.Int64Ptr(2048), Limit: pointer.Int64Ptr(4096), Shares: &SharesSpec{Level: CustomSharesLevel, Value: pointer.Int32Ptr(40960)}},
+			}},
+		},
+		{
+			name: "resources with a limit below the reservation",
+			spec: VirtualMachineCloneSpec{Resources: &VirtualMachineResources{
+				Memory: &ResourceAllocation{Reservation: pointer.Int64Ptr(2048), Limit: pointer.Int64Ptr(1024)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "resources with custom shares without a value",
+			spec: VirtualMachineCloneSpec{Resources: &VirtualMachineResources{
+				CPU: &ResourceAllocation{Shares: &SharesSpec{Level: CustomSharesLevel}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "resources with instant clone",
+			spec: VirtualMachineCloneSpec{CloneMode: InstantClone, Resources: &VirtualMachineResources{
+				CPU: &ResourceAllocation{Reservation: pointer.Int64Ptr(1000)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "secure boot and vTPM with efi firmware",
+			spec: VirtualMachineCloneSpec{Firmware: EFIFirmware, SecureBoot: true, VTPM: true},
+		},
+		{
+			name: "secure boot with the template's firmware",
+			spec: VirtualMachineCloneSpec{SecureBoot: true},
+		},
+		{
+			name:    "secure boot with bios firmware",
+			spec:    VirtualMachineCloneSpec{Firmware: BIOSFirmware, SecureBoot: true},
+			wantErr: true,
+		},
+		{
+			name:    "vTPM with bios firmware",
+			spec:    VirtualMachineCloneSpec{Firmware: BIOSFirmware, VTPM: true},
+			wantErr: true,
+		},
+		{
+			name:    "vTPM with instant clone",
+			spec:    VirtualMachineCloneSpec{CloneMode: InstantClone, VTPM: true},
+			wantErr: true,
+		},
+		{
+			name: "managed snapshot with linked clone",
+			spec: VirtualMachineCloneSpec{CloneMode: LinkedClone, ManageSnapshot: true},
+		},
+		{
+			name:    "managed snapshot with full clone",
+			spec:    VirtualMachineCloneSpec{CloneMode: FullClone, ManageSnapshot: true},
+			wantErr: true,
+		},
+		{
+			name:    "negative shutdown timeout",
+			spec:    VirtualMachineCloneSpec{ShutdownTimeout: &metav1.Duration{Duration: -time.Minute}},
+			wantErr: true,
+		},
+		{
+			name: "network device referenced by port group key",
+			spec: VirtualMachineCloneSpec{Network: NetworkSpec{Devices: []NetworkDeviceSpec{
+				{PortGroupKey: "dvportgroup-42", AdapterType: E1000ENetworkAdapter},
+			}}},
+		},
+		{
+			name: "network device without a network reference",
+			spec: VirtualMachineCloneSpec{Network: NetworkSpec{Devices: []NetworkDeviceSpec{
+				{DVSUUID: "50 2c 3f 6a"},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "network adapter type with instant clone",
+			spec: VirtualMachineCloneSpec{CloneMode: InstantClone, Network: NetworkSpec{Devices: []NetworkDeviceSpec{
+				{NetworkName: "VM Network", AdapterType: E1000ENetworkAdapter},
+			}}},
+			wantErr: true,
+		},
+		{
+			name: "additional disks with distinct unit numbers",
+			spec: VirtualMachineCloneSpec{AdditionalDisks: []AdditionalDiskSpec{
+				{SizeGiB: 10},
+				{SizeGiB: 10, UnitNumber: pointer.Int32Ptr(1)},
+				{SizeGiB: 10, ControllerNumber: pointer.Int32Ptr(1), UnitNumber: pointer.Int32Ptr(1)},
+			}},
+		},
+		{
+			name: "additional disks with the same unit number on a controller",
+			spec: VirtualMachineCloneSpec{AdditionalDisks: []AdditionalDiskSpec{
+				{SizeGiB: 10, ControllerNumber: pointer.Int32Ptr(1), UnitNumber: pointer.Int32Ptr(1)},
+				{SizeGiB: 10, ControllerNumber: pointer.Int32Ptr(1), UnitNumber: pointer.Int32Ptr(1)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "additional disk with the unit number of the SCSI controller",
+			spec: VirtualMachineCloneSpec{AdditionalDisks: []AdditionalDiskSpec{
+				{SizeGiB: 10, ControllerNumber: pointer.Int32Ptr(0), UnitNumber: pointer.Int32Ptr(7)},
+			}},
+			wantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err := tc.vsphereMachine.ValidateCreate()
+			vsphereMachine := tc.vsphereMachine
+			if vsphereMachine == nil {
+				vsphereMachine = &VSphereMachine{Spec: VSphereMachineSpec{VirtualMachineCloneSpec: tc.spec}}
+			}
+			err := vsphereMachine.ValidateCreate()
 			if tc.wantErr {
 				g.Expect(err).To(HaveOccurred())
 			} else {
@@ -72,6 +231,8 @@ func TestVSphereMachine_ValidateUpdate(t *testing.T) {
 		name              string
 		oldVSphereMachine *VSphereMachine
 		vsphereMachine    *VSphereMachine
+		oldSpec           VirtualMachineCloneSpec
+		spec              VirtualMachineCloneSpec
 		wantErr           bool
 	}{
 		{
@@ -92,10 +253,70 @@ func TestVSphereMachine_ValidateUpdate(t *testing.T) {
 			vsphereMachine:    createVSphereMachine("bar.com", &someProviderID, "", []string{"192.168.0.1/32", "192.168.0.10/32"}),
 			wantErr:           true,
 		},
+		{
+			name:    "updating the size cannot be done without in-place resize",
+			oldSpec: VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 4096},
+			spec:    VirtualMachineCloneSpec{NumCPUs: 4, MemoryMiB: 8192},
+			wantErr: true,
+		},
+		{
+			name:    "updating the size can be done with in-place resize",
+			oldSpec: VirtualMachineCloneSpec{InPlaceResize: true, NumCPUs: 2, MemoryMiB: 4096},
+			spec:    VirtualMachineCloneSpec{InPlaceResize: true, NumCPUs: 4, MemoryMiB: 8192},
+		},
+		{
+			name:    "in-place resize can be enabled together with a new size",
+			oldSpec: VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 4096},
+			spec:    VirtualMachineCloneSpec{InPlaceResize: true, NumCPUs: 4, MemoryMiB: 8192},
+		},
+		{
+			name:    "in-place resize cannot be enabled for an instant clone",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: InstantClone},
+			spec:    VirtualMachineCloneSpec{CloneMode: InstantClone, InPlaceResize: true},
+			wantErr: true,
+		},
+		{
+			name:    "growing the disks of a full clone can be done",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 20, AdditionalDisks: []AdditionalDiskSpec{{SizeGiB: 10}}},
+			spec:    VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 40, AdditionalDisks: []AdditionalDiskSpec{{SizeGiB: 50}}},
+		},
+		{
+			name:    "shrinking the disk of a full clone cannot be done",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 40},
+			spec:    VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 20},
+			wantErr: true,
+		},
+		{
+			name:    "shrinking an additional disk cannot be done",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: FullClone, AdditionalDisks: []AdditionalDiskSpec{{SizeGiB: 50}}},
+			spec:    VirtualMachineCloneSpec{CloneMode: FullClone, AdditionalDisks: []AdditionalDiskSpec{{SizeGiB: 10}}},
+			wantErr: true,
+		},
+		{
+			name:    "growing the disk of a linked clone cannot be done",
+			oldSpec: VirtualMachineCloneSpec{DiskGiB: 20},
+			spec:    VirtualMachineCloneSpec{DiskGiB: 40},
+			wantErr: true,
+		},
+		{
+			name: "updating the shutdown timeout can be done",
+			spec: VirtualMachineCloneSpec{ShutdownTimeout: &metav1.Duration{Duration: 10 * time.Minute}},
+		},
+		{
+			name:    "adding an additional disk cannot be done",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: FullClone},
+			spec:    VirtualMachineCloneSpec{CloneMode: FullClone, AdditionalDisks: []AdditionalDiskSpec{{SizeGiB: 10}}},
+			wantErr: true,
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err := tc.vsphereMachine.ValidateUpdate(tc.oldVSphereMachine)
+			oldVSphereMachine, vsphereMachine := tc.oldVSphereMachine, tc.vsphereMachine
+			if vsphereMachine == nil {
+				oldVSphereMachine = &VSphereMachine{Spec: VSphereMachineSpec{VirtualMachineCloneSpec: tc.oldSpec}}
+				vsphereMachine = &VSphereMachine{Spec: VSphereMachineSpec{VirtualMachineCloneSpec: tc.spec}}
+			}
+			err := vsphereMachine.ValidateUpdate(oldVSphereMachine)
 			if tc.wantErr {
 				g.Expect(err).To(HaveOccurred())
 			} else {
@@ -120,7 +341,8 @@ func createVSphereMachine(server string, providerID *string, preferredAPIServerC
 	}
 	for _, ip := range ips {
 		VSphereMachine.Spec.Network.Devices = append(VSphereMachine.Spec.Network.Devices, NetworkDeviceSpec{
-			IPAddrs: []string{ip},
+			NetworkName: "VM Network",
+			IPAddrs:     []string{ip},
 		})
 	}
 	return VSphereMachine
@@ -53,6 +53,16 @@ func (r *VSphereMachineTemplate) ValidateCreate() error {
 			allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "template", "spec", "network", "devices", "ipAddrs"), "cannot be set in templates"))
 		}
 	}
+	allErrs = append(allErrs, validateCABundle(field.NewPath("spec", "template", "spec", "caBundle"), spec.CABundle)...)
+	allErrs = append(allErrs, validateAdditionalDisks(field.NewPath("spec", "template", "spec", "additionalDisks"), spec.AdditionalDisks)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateBootstrapTransport(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateResources(field.NewPath("spec", "template", "spec").Child("resources"), spec.Resources)...)
+	allErrs = append(allErrs, validateShutdownTimeout(field.NewPath("spec", "template", "spec", "shutdownTimeout"), spec.ShutdownTimeout)...)
+	allErrs = append(allErrs, validateFirmware(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateNetworkDevices(field.NewPath("spec", "template", "spec").Child("network", "devices"), spec.Network.Devices)...)
+	allErrs = append(allErrs, validateTemplate(field.NewPath("spec", "template", "spec"), spec.VirtualMachineCloneSpec)...)
+
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
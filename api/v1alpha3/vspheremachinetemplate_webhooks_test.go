@@ -125,7 +125,8 @@ func createVSphereMachineTemplate(server string, providerID *string, preferredAP
 	}
 	for _, ip := range ips {
 		VSphereMachineTemplate.Spec.Template.Spec.Network.Devices = append(VSphereMachineTemplate.Spec.Template.Spec.Network.Devices, NetworkDeviceSpec{
-			IPAddrs: []string{ip},
+			NetworkName: "VM Network",
+			IPAddrs:     []string{ip},
 		})
 	}
 	return VSphereMachineTemplate
@@ -46,6 +46,12 @@ type VSphereVMSpec struct {
 	// this CRD as unstructured data.
 	// +optional
 	BiosUUID string `json:"biosUUID,omitempty"`
+
+	// HostGroup is the name of a DRS host group of the compute cluster in
+	// which the VM is created. When set, the VM is added to a VM group with
+	// a VM/Host affinity rule that places it on the hosts of the host group.
+	// +optional
+	HostGroup string `json:"hostGroup,omitempty"`
 }
 
 // VSphereVMStatus defines the observed state of VSphereVM
@@ -65,7 +71,8 @@ type VSphereVMStatus struct {
 	// CloneMode is the type of clone operation used to clone this VM. Since
 	// LinkedMode is the default but fails gracefully if the source of the
 	// clone has no snapshots, this field may be used to determine the actual
-	// type of clone operation used to create this VM.
+	// type of clone operation used to create this VM. VMs forked from a
+	// running VM have the InstantClone mode.
 	// +optional
 	CloneMode CloneMode `json:"cloneMode,omitempty"`
 
@@ -74,17 +81,58 @@ type VSphereVMStatus struct {
 	// +optional
 	Snapshot string `json:"snapshot,omitempty"`
 
+	// Datastore is the name of the datastore in which the VM was created.
+	// It differs from the spec's Datastore when that is a datastore cluster,
+	// in which case the datastore is recommended by Storage DRS, or when the
+	// datastore is selected by the spec's StoragePolicyName.
+	// +optional
+	Datastore string `json:"datastore,omitempty"`
+
+	// Tags are the tags that were last attached to the VM. The tags are
+	// only attached again once the VM's tags differ from these.
+	// +optional
+	Tags []TagSpec `json:"tags,omitempty"`
+
+	// BootstrapISO is the datastore path of the NoCloud ISO attached to the
+	// VM, if the VM uses the NoCloud bootstrap transport. It is cleared once
+	// the ISO is detached and deleted.
+	// +optional
+	BootstrapISO string `json:"bootstrapISO,omitempty"`
+
 	// TaskRef is a managed object reference to a Task related to the machine.
 	// This value is set automatically at runtime and should not be set or
 	// modified by users.
 	// +optional
 	TaskRef string `json:"taskRef,omitempty"`
 
+	// PendingCustomization is true from when a VM starts to be deployed
+	// from a Content Library item until the deployed VM is reconfigured
+	// with the VSphereVM's customization, such as its size, network devices
+	// and bootstrap data. Such a VM is reconfigured again rather than
+	// powered on.
+	// This value is set automatically at runtime and should not be set or
+	// modified by users.
+	// +optional
+	PendingCustomization bool `json:"pendingCustomization,omitempty"`
+
+	// ShutdownStartTime is when the guest of the VM was asked to shut down
+	// before the VM is destroyed or resized. The VM is powered off if it is still
+	// powered on once its shutdown timeout has elapsed since then.
+	// This value is set automatically at runtime and should not be set or
+	// modified by users.
+	// +optional
+	ShutdownStartTime *metav1.Time `json:"shutdownStartTime,omitempty"`
+
 	// Network returns the network status for each of the machine's configured
 	// network interfaces.
 	// +optional
 	Network []NetworkStatus `json:"network,omitempty"`
 
+	// Disks returns the layout of the machine's disks, including the disks of
+	// the template and the additional disks.
+	// +optional
+	Disks []DiskStatus `json:"disks,omitempty"`
+
 	// FailureReason will be set in the event that there is a terminal problem
 	// reconciling the vspherevm and will contain a succinct value suitable
 	// for vm interpretation.
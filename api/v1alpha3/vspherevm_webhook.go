@@ -52,6 +52,18 @@ func (r *VSphereVM) ValidateCreate() error {
 			}
 		}
 	}
+	allErrs = append(allErrs, validateCABundle(field.NewPath("spec", "caBundle"), spec.CABundle)...)
+	allErrs = append(allErrs, validateAdditionalDisks(field.NewPath("spec", "additionalDisks"), spec.AdditionalDisks)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateBootstrapTransport(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateResources(field.NewPath("spec").Child("resources"), spec.Resources)...)
+	allErrs = append(allErrs, validateShutdownTimeout(field.NewPath("spec", "shutdownTimeout"), spec.ShutdownTimeout)...)
+	allErrs = append(allErrs, validateFirmware(field.NewPath("spec"), spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateNetworkDevices(field.NewPath("spec").Child("network", "devices"), spec.Network.Devices)...)
+	if spec.TemplateSelector != nil {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "templateSelector"), "cannot be set on a VSphereVM"))
+	}
+
 	return aggregateObjErrors(r.GroupVersionKind().GroupKind(), r.Name, allErrs)
 }
 
@@ -86,6 +98,19 @@ func (r *VSphereVM) ValidateUpdate(old runtime.Object) error { //nolint
 	delete(oldVSphereVMNetwork, "devices")
 	delete(newVSphereVMNetwork, "devices")
 
+	// allow changes to the shutdown timeout
+	delete(oldVSphereVMSpec, "shutdownTimeout")
+	delete(newVSphereVMSpec, "shutdownTimeout")
+	allErrs = append(allErrs, validateShutdownTimeout(field.NewPath("spec", "shutdownTimeout"), r.Spec.ShutdownTimeout)...)
+
+	// allow changes to the size of the VM if it is resized in place
+	allowInPlaceResize(oldVSphereVMSpec, newVSphereVMSpec)
+
+	// allow the disks to be grown
+	allowDiskExpansion(oldVSphereVMSpec, newVSphereVMSpec)
+	allErrs = append(allErrs, validateDiskExpansion(field.NewPath("spec"), old.(*VSphereVM).Spec.VirtualMachineCloneSpec, r.Spec.VirtualMachineCloneSpec)...)
+	allErrs = append(allErrs, validateCloneMode(field.NewPath("spec"), r.Spec.VirtualMachineCloneSpec)...)
+
 	if !reflect.DeepEqual(oldVSphereVMSpec, newVSphereVMSpec) {
 		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec"), "cannot be modified"))
 	}
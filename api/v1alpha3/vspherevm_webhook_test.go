@@ -73,6 +73,8 @@ func TestVSphereVM_ValidateUpdate(t *testing.T) {
 		name         string
 		oldVSphereVM *VSphereVM
 		vSphereVM    *VSphereVM
+		oldSpec      VirtualMachineCloneSpec
+		spec         VirtualMachineCloneSpec
 		wantErr      bool
 	}{
 		{
@@ -99,10 +101,60 @@ func TestVSphereVM_ValidateUpdate(t *testing.T) {
 			vSphereVM:    createVSphereVM("bar.com", biosUUID, "", []string{"192.168.0.1/32", "192.168.0.10/32"}, nil),
 			wantErr:      true,
 		},
+		{
+			name:    "updating the size cannot be done without in-place resize",
+			oldSpec: VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 4096},
+			spec:    VirtualMachineCloneSpec{NumCPUs: 4, MemoryMiB: 8192},
+			wantErr: true,
+		},
+		{
+			name:    "updating the size can be done with in-place resize",
+			oldSpec: VirtualMachineCloneSpec{InPlaceResize: true, NumCPUs: 2, MemoryMiB: 4096},
+			spec:    VirtualMachineCloneSpec{InPlaceResize: true, NumCPUs: 4, MemoryMiB: 8192},
+		},
+		{
+			name:    "growing the disk of a full clone can be done",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 20},
+			spec:    VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 40},
+		},
+		{
+			name:    "shrinking the disk of a full clone cannot be done",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 40},
+			spec:    VirtualMachineCloneSpec{CloneMode: FullClone, DiskGiB: 20},
+			wantErr: true,
+		},
+		{
+			name:    "growing the disk of a linked clone cannot be done",
+			oldSpec: VirtualMachineCloneSpec{CloneMode: LinkedClone, DiskGiB: 20},
+			spec:    VirtualMachineCloneSpec{CloneMode: LinkedClone, DiskGiB: 40},
+			wantErr: true,
+		},
+		{
+			name: "growing the disk of a linked clone that fell back to a full clone cannot be done",
+			oldVSphereVM: &VSphereVM{
+				Spec:   VSphereVMSpec{VirtualMachineCloneSpec: VirtualMachineCloneSpec{DiskGiB: 20}},
+				Status: VSphereVMStatus{CloneMode: FullClone},
+			},
+			vSphereVM: &VSphereVM{
+				Spec:   VSphereVMSpec{VirtualMachineCloneSpec: VirtualMachineCloneSpec{DiskGiB: 40}},
+				Status: VSphereVMStatus{CloneMode: FullClone},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "growing the disk of a content library item can be done",
+			oldSpec: VirtualMachineCloneSpec{ContentLibrary: "library", DiskGiB: 20},
+			spec:    VirtualMachineCloneSpec{ContentLibrary: "library", DiskGiB: 40},
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			err := tc.vSphereVM.ValidateUpdate(tc.oldVSphereVM)
+			oldVSphereVM, vSphereVM := tc.oldVSphereVM, tc.vSphereVM
+			if vSphereVM == nil {
+				oldVSphereVM = &VSphereVM{Spec: VSphereVMSpec{VirtualMachineCloneSpec: tc.oldSpec}}
+				vSphereVM = &VSphereVM{Spec: VSphereVMSpec{VirtualMachineCloneSpec: tc.spec}}
+			}
+			err := vSphereVM.ValidateUpdate(oldVSphereVM)
 			if tc.wantErr {
 				g.Expect(err).To(HaveOccurred())
 			} else {
@@ -128,7 +180,8 @@ func createVSphereVM(server string, biosUUID string, preferredAPIServerCIDR stri
 	}
 	for _, ip := range ips {
 		VSphereVM.Spec.Network.Devices = append(VSphereVM.Spec.Network.Devices, NetworkDeviceSpec{
-			IPAddrs: []string{ip},
+			NetworkName: "VM Network",
+			IPAddrs:     []string{ip},
 		})
 	}
 	return VSphereVM
@@ -17,7 +17,11 @@ limitations under the License.
 package v1alpha3
 
 import (
+	"encoding/pem"
+	"fmt"
+
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
@@ -33,3 +37,264 @@ func aggregateObjErrors(gk schema.GroupKind, name string, allErrs field.ErrorLis
 		allErrs,
 	)
 }
+
+// validateCABundle validates that a CA bundle contains PEM-encoded
+// certificates.
+func validateCABundle(fldPath *field.Path, caBundle []byte) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(caBundle) > 0 {
+		if block, _ := pem.Decode(caBundle); block == nil || block.Type != "CERTIFICATE" {
+			allErrs = append(allErrs, field.Invalid(fldPath, "", "must contain PEM-encoded certificates"))
+		}
+	}
+	return allErrs
+}
+
+// scsiControllerUnitNumber is the unit number the SCSI controller occupies on
+// its own bus.
+const scsiControllerUnitNumber = 7
+
+// validateAdditionalDisks validates that the additional disks do not claim the
+// same unit number on a controller, or the unit number reserved for the SCSI
+// controller.
+func validateAdditionalDisks(fldPath *field.Path, disks []AdditionalDiskSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	type slot struct {
+		controllerNumber int32
+		defaultBus       bool
+		unitNumber       int32
+	}
+	slots := map[slot]bool{}
+	for i, disk := range disks {
+		if disk.UnitNumber == nil {
+			continue
+		}
+		unitNumberPath := fldPath.Index(i).Child("unitNumber")
+		s := slot{defaultBus: disk.ControllerNumber == nil, unitNumber: *disk.UnitNumber}
+		if disk.ControllerNumber != nil {
+			s.controllerNumber = *disk.ControllerNumber
+			if *disk.UnitNumber == scsiControllerUnitNumber {
+				allErrs = append(allErrs, field.Invalid(unitNumberPath, *disk.UnitNumber, "is reserved for the SCSI controller"))
+			}
+		}
+		if slots[s] {
+			allErrs = append(allErrs, field.Duplicate(unitNumberPath, *disk.UnitNumber))
+		}
+		slots[s] = true
+	}
+	return allErrs
+}
+
+// validateTemplate validates that a clone spec does not set both a template
+// and a template selector.
+func validateTemplate(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Template != "" && spec.TemplateSelector != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("templateSelector"), "cannot be set with template"))
+	}
+	return allErrs
+}
+
+// validateCloneMode validates that a clone spec only manages the snapshot of
+// its template for linked clones, and that a clone spec whose VM is an instant
+// clone does not set the fields that instant clones do not support.
+func validateCloneMode(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.ManageSnapshot {
+		if spec.CloneMode != "" && spec.CloneMode != LinkedClone {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("manageSnapshot"), fmt.Sprintf("cannot be set with the %s clone mode", spec.CloneMode)))
+		}
+		if spec.ContentLibrary != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("manageSnapshot"), "cannot be set with contentLibrary"))
+		}
+	}
+	if spec.CloneMode != InstantClone {
+		return allErrs
+	}
+	if spec.ContentLibrary != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("contentLibrary"), "cannot be set with the instantClone clone mode"))
+	}
+	if spec.Snapshot != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("snapshot"), "cannot be set with the instantClone clone mode"))
+	}
+	if len(spec.AdditionalDisks) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("additionalDisks"), "cannot be set with the instantClone clone mode"))
+	}
+	if len(spec.VAppProperties) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("vAppProperties"), "cannot be set with the instantClone clone mode"))
+	}
+	if spec.Resources != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("resources"), "cannot be set with the instantClone clone mode"))
+	}
+	if spec.Firmware != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("firmware"), "cannot be set with the instantClone clone mode"))
+	}
+	if spec.SecureBoot {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("secureBoot"), "cannot be set with the instantClone clone mode"))
+	}
+	if spec.VTPM {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("vtpm"), "cannot be set with the instantClone clone mode"))
+	}
+	if spec.InPlaceResize {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("inPlaceResize"), "cannot be set with the instantClone clone mode"))
+	}
+	if spec.BootstrapFormat == IgnitionBootstrapFormat {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("bootstrapFormat"), "cannot be ignition with the instantClone clone mode"))
+	}
+	for i, device := range spec.Network.Devices {
+		if device.AdapterType != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("network", "devices").Index(i).Child("adapterType"), "cannot be set with the instantClone clone mode"))
+		}
+	}
+	return allErrs
+}
+
+// allowInPlaceResize removes the fields of the old and new unstructured
+// clone specs that may be changed in place: the inPlaceResize field itself,
+// and the number of CPUs and memory size if the new clone spec enables
+// in-place resize.
+func allowInPlaceResize(oldSpec, newSpec map[string]interface{}) {
+	keys := []string{"inPlaceResize"}
+	if inPlaceResize, _ := newSpec["inPlaceResize"].(bool); inPlaceResize {
+		keys = append(keys, "numCPUs", "numCoresPerSocket", "memoryMiB")
+	}
+	for _, key := range keys {
+		delete(oldSpec, key)
+		delete(newSpec, key)
+	}
+}
+
+// validateDiskExpansion validates that the disks of the old clone spec are
+// only grown by the new clone spec, and only if it requests a full clone,
+// since linked clones share their disks with the snapshot of their template.
+// The VSphereVM controller grows the disks by the same rule.
+func validateDiskExpansion(fldPath *field.Path, oldSpec, newSpec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	fullClone := newSpec.RequestsFullClone()
+	validateSize := func(fldPath *field.Path, oldSize, newSize int32) {
+		switch {
+		case newSize == oldSize:
+		case newSize < oldSize:
+			allErrs = append(allErrs, field.Invalid(fldPath, newSize, "cannot be decreased"))
+		case !fullClone:
+			allErrs = append(allErrs, field.Forbidden(fldPath, "can only be increased with the fullClone clone mode or a content library"))
+		}
+	}
+	validateSize(fldPath.Child("diskGiB"), oldSpec.DiskGiB, newSpec.DiskGiB)
+	for i, disk := range newSpec.AdditionalDisks {
+		if i < len(oldSpec.AdditionalDisks) {
+			validateSize(fldPath.Child("additionalDisks").Index(i).Child("sizeGiB"), oldSpec.AdditionalDisks[i].SizeGiB, disk.SizeGiB)
+		}
+	}
+	return allErrs
+}
+
+// allowDiskExpansion removes the disk sizes from the old and new unstructured
+// clone specs, whose changes are validated by validateDiskExpansion.
+func allowDiskExpansion(oldSpec, newSpec map[string]interface{}) {
+	for _, spec := range []map[string]interface{}{oldSpec, newSpec} {
+		delete(spec, "diskGiB")
+		disks, _ := spec["additionalDisks"].([]interface{})
+		for _, disk := range disks {
+			if disk, ok := disk.(map[string]interface{}); ok {
+				delete(disk, "sizeGiB")
+			}
+		}
+	}
+}
+
+// validateBootstrapTransport validates that a clone spec whose VM does not
+// use the guestinfo bootstrap transport does not set the fields that the
+// transport does not support.
+func validateBootstrapTransport(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	transport := spec.BootstrapTransport
+	if transport == "" || transport == GuestInfoBootstrapTransport {
+		return allErrs
+	}
+	if spec.BootstrapFormat == IgnitionBootstrapFormat {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("bootstrapFormat"), "cannot be ignition with the "+string(transport)+" bootstrap transport"))
+	}
+	if spec.ContentLibrary != "" && transport == NoCloudBootstrapTransport {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("contentLibrary"), "cannot be set with the noCloud bootstrap transport"))
+	}
+	if spec.CloneMode == InstantClone {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("cloneMode"), "cannot be instantClone with the "+string(transport)+" bootstrap transport"))
+	}
+	return allErrs
+}
+
+// validateShutdownTimeout validates that the shutdown timeout of a VM is not
+// negative.
+func validateShutdownTimeout(fldPath *field.Path, timeout *metav1.Duration) field.ErrorList {
+	var allErrs field.ErrorList
+	if timeout != nil && timeout.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath, timeout.Duration.String(), "cannot be negative"))
+	}
+	return allErrs
+}
+
+// validateResources validates that the limits of a VM's resource allocations
+// are either unlimited or at least their reservations, and that their shares
+// have a value if, and only if, their level is custom.
+func validateResources(fldPath *field.Path, resources *VirtualMachineResources) field.ErrorList {
+	var allErrs field.ErrorList
+	if resources == nil {
+		return allErrs
+	}
+	allErrs = append(allErrs, validateResourceAllocation(fldPath.Child("cpu"), resources.CPU)...)
+	allErrs = append(allErrs, validateResourceAllocation(fldPath.Child("memory"), resources.Memory)...)
+	return allErrs
+}
+
+func validateResourceAllocation(fldPath *field.Path, allocation *ResourceAllocation) field.ErrorList {
+	var allErrs field.ErrorList
+	if allocation == nil {
+		return allErrs
+	}
+	if limit := allocation.Limit; limit != nil {
+		switch {
+		case *limit < -1:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("limit"), *limit, "must be -1 for unlimited, or greater than or equal to 0"))
+		case *limit != -1 && allocation.Reservation != nil && *allocation.Reservation > *limit:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("limit"), *limit, "must be greater than or equal to the reservation"))
+		}
+	}
+	if shares := allocation.Shares; shares != nil {
+		switch {
+		case shares.Level == CustomSharesLevel && shares.Value == nil:
+			allErrs = append(allErrs, field.Required(fldPath.Child("shares", "value"), "must be set with the custom level"))
+		case shares.Level != CustomSharesLevel && shares.Value != nil:
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("shares", "value"), "can only be set with the custom level"))
+		}
+	}
+	return allErrs
+}
+
+// validateFirmware validates that a clone spec does not enable Secure Boot or
+// a virtual TPM with the bios firmware, since both require the efi firmware.
+func validateFirmware(fldPath *field.Path, spec VirtualMachineCloneSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Firmware != BIOSFirmware {
+		return allErrs
+	}
+	if spec.SecureBoot {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("secureBoot"), "cannot be set with the bios firmware"))
+	}
+	if spec.VTPM {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("vtpm"), "cannot be set with the bios firmware"))
+	}
+	return allErrs
+}
+
+// validateNetworkDevices validates that each network device references its
+// network by a name, port group key or segment ID.
+func validateNetworkDevices(fldPath *field.Path, devices []NetworkDeviceSpec) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, device := range devices {
+		if device.NetworkName == "" && device.PortGroupKey == "" && device.SegmentID == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Index(i).Child("networkName"), "networkName, portGroupKey or segmentID must be set"))
+		}
+	}
+	return allErrs
+}
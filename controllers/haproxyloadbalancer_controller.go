@@ -556,6 +556,17 @@ func (r haproxylbReconciler) reconcileVMPre7(ctx *context.HAProxyLoadBalancerCon
 		// clone spec.
 		ctx.HAProxyLoadBalancer.Spec.VirtualMachineConfiguration.DeepCopyInto(&vm.Spec.VirtualMachineCloneSpec)
 
+		// Verify the vSphere server's certificate the same way as for the
+		// cluster's machines unless the load balancer specifies otherwise.
+		if ref := ctx.Cluster.Spec.InfrastructureRef; ref != nil && ref.Kind == "VSphereCluster" {
+			vsphereCluster := &infrav1.VSphereCluster{}
+			vsphereClusterKey := ctrlclient.ObjectKey{Namespace: ctx.Cluster.Namespace, Name: ref.Name}
+			if err := r.Client.Get(ctx, vsphereClusterKey, vsphereCluster); err != nil {
+				return errors.Wrapf(err, "failed to get VSphereCluster %s", vsphereClusterKey)
+			}
+			infrautilv1.InheritTrust(&vm.Spec.VirtualMachineCloneSpec, vsphereCluster)
+		}
+
 		objectKey, err := ctrlclient.ObjectKeyFromObject(vm)
 		if err != nil {
 			return err
@@ -17,13 +17,18 @@ limitations under the License.
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
 	apiv1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -32,8 +37,11 @@ import (
 	"k8s.io/apimachinery/pkg/util/wait"
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/cloudprovider"
+	govmomicluster "sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 	infrautilv1 "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	clusterutilv1 "sigs.k8s.io/cluster-api/util"
@@ -60,6 +68,7 @@ var (
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vspherevms,verbs=get;list;watch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
 
 // AddClusterControllerToManager adds the cluster controller to the provided
@@ -79,7 +88,10 @@ func AddClusterControllerToManager(ctx *context.ControllerManagerContext, mgr ma
 		Logger:                   ctx.Logger.WithName(controllerNameShort),
 	}
 
-	reconciler := clusterReconciler{ControllerContext: controllerContext}
+	reconciler := clusterReconciler{
+		ControllerContext:  controllerContext,
+		controlPlaneVMRefs: &sync.Map{},
+	}
 
 	return ctrl.NewControllerManagedBy(mgr).
 		// Watch the controlled, infrastructure resource.
@@ -100,6 +112,14 @@ func AddClusterControllerToManager(ctx *context.ControllerManagerContext, mgr ma
 				ToRequests: handler.ToRequestsFunc(reconciler.controlPlaneMachineToCluster),
 			},
 		).
+		// Watch the VMs that belong to the control plane. This controller
+		// maintains the anti-affinity rules that keep them on separate hosts.
+		Watches(
+			&source.Kind{Type: &infrav1.VSphereVM{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: handler.ToRequestsFunc(reconciler.controlPlaneVMToCluster),
+			},
+		).
 		// Watch the load balancer resource that may be used to provide HA to
 		// the VSphereCluster control plane.
 		// TODO(akutz) Figure out how to watch LB resources without requiring
@@ -127,6 +147,11 @@ func AddClusterControllerToManager(ctx *context.ControllerManagerContext, mgr ma
 
 type clusterReconciler struct {
 	*context.ControllerContext
+
+	// controlPlaneVMRefs caches the references to the control plane VMs of
+	// each VSphereCluster, keyed by the VSphereCluster's namespace and name,
+	// so the VMs are only looked up by their BIOS UUIDs once.
+	controlPlaneVMRefs *sync.Map
 }
 
 // Reconcile ensures the back-end state reflects the Kubernetes resource state intent.
@@ -221,6 +246,9 @@ func (r clusterReconciler) reconcileDelete(ctx *context.ClusterContext) (reconci
 		return reconcile.Result{}, err
 	}
 
+	// Remove the VMs that are being deleted from the anti-affinity rules.
+	r.reconcileControlPlaneAntiAffinity(ctx)
+
 	if len(vsphereMachines) > 0 {
 		ctx.Logger.Info("Waiting for VSphereMachines to be deleted", "count", len(vsphereMachines))
 		return reconcile.Result{RequeueAfter: 10 * time.Second}, nil
@@ -240,6 +268,7 @@ func (r clusterReconciler) reconcileDelete(ctx *context.ClusterContext) (reconci
 	conditions.MarkFalse(ctx.VSphereCluster, infrav1.LoadBalancerAvailableCondition, clusterv1.DeletedReason, clusterv1.ConditionSeverityInfo, "")
 
 	// Cluster is deleted so remove the finalizer.
+	r.controlPlaneVMRefs.Delete(ctx.VSphereCluster.Namespace + "/" + ctx.VSphereCluster.Name)
 	ctrlutil.RemoveFinalizer(ctx.VSphereCluster, infrav1.ClusterFinalizer)
 
 	return reconcile.Result{}, nil
@@ -267,6 +296,14 @@ func (r clusterReconciler) reconcileNormal(ctx *context.ClusterContext) (reconci
 	conditions.MarkTrue(ctx.VSphereCluster, infrav1.LoadBalancerAvailableCondition)
 	ctx.VSphereCluster.Status.Ready = true
 
+	// Publish the failure domains so Cluster API can spread the cluster's
+	// machines across them.
+	ctx.VSphereCluster.Status.FailureDomains = infrautilv1.GetFailureDomains(ctx.VSphereCluster)
+
+	// Keep the control plane VMs on separate hosts. A failure is reported by
+	// the condition, but does not prevent the cluster from being reconciled.
+	r.reconcileControlPlaneAntiAffinity(ctx)
+
 	// Ensure the VSphereCluster is reconciled when the API server first comes online.
 	// A reconcile event will only be triggered if the Cluster is not marked as
 	// ControlPlaneInitialized.
@@ -679,9 +716,14 @@ func (r clusterReconciler) reconcileStorageProvider(ctx *context.ClusterContext)
 		return err
 	}
 
+	creds, err := r.getCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
 	// we have to marshal a separate INI file for CSI since it does not
 	// support Secrets for vCenter credentials yet.
-	cloudConfig, err := cloudprovider.ConfigForCSI(*ctx.VSphereCluster, *ctx.Cluster, ctx.Username, ctx.Password).MarshalINI()
+	cloudConfig, err := cloudprovider.ConfigForCSI(*ctx.VSphereCluster, *ctx.Cluster, creds.Username, creds.Password).MarshalINI()
 	if err != nil {
 		return err
 	}
@@ -735,10 +777,15 @@ func (r clusterReconciler) reconcileCloudConfigSecret(ctx *context.ClusterContex
 			ctx.Cluster.Namespace, ctx.Cluster.Name)
 	}
 
+	creds, err := r.getCredentials(ctx)
+	if err != nil {
+		return err
+	}
+
 	credentials := map[string]string{}
 	for server := range ctx.VSphereCluster.Spec.CloudProviderConfiguration.VCenter {
-		credentials[fmt.Sprintf("%s.username", server)] = ctx.Username
-		credentials[fmt.Sprintf("%s.password", server)] = ctx.Password
+		credentials[fmt.Sprintf("%s.username", server)] = creds.Username
+		credentials[fmt.Sprintf("%s.password", server)] = creds.Password
 	}
 	// Define the kubeconfig secret for the target cluster.
 	secret := &apiv1.Secret{
@@ -768,6 +815,188 @@ func (r clusterReconciler) reconcileCloudConfigSecret(ctx *context.ClusterContex
 	return nil
 }
 
+// getCredentials returns the credentials used to access the vSphere endpoint
+// of the cluster. The credentials of the identity referenced by the
+// VSphereCluster are used if there is one, otherwise the controller
+// manager's credentials are used.
+func (r clusterReconciler) getCredentials(ctx *context.ClusterContext) (*identity.Credentials, error) {
+	if ctx.VSphereCluster.Spec.IdentityRef == nil {
+		username, password := ctx.GetCredentials()
+		return &identity.Credentials{Username: username, Password: password}, nil
+	}
+	creds, err := identity.GetCredentials(ctx, ctx.Client, ctx.VSphereCluster, ctx.Namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"failed to get credentials for VSphereCluster %s/%s",
+			ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	}
+	return creds, nil
+}
+
+// reconcileControlPlaneAntiAffinity ensures each compute cluster that runs the
+// VSphereCluster's control plane VMs has a DRS anti-affinity rule whose
+// members are those VMs. VMs that are being deleted are removed from the
+// rules, and the result is reported by the ControlPlaneAntiAffinity
+// condition. The rules are only reconciled when the control plane VSphereVMs
+// or their BIOS UUIDs change, or when they could not be reconciled before.
+func (r clusterReconciler) reconcileControlPlaneAntiAffinity(ctx *context.ClusterContext) {
+	vsphereVMs := &infrav1.VSphereVMList{}
+	if err := r.Client.List(ctx, vsphereVMs,
+		client.InNamespace(ctx.VSphereCluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: ctx.Cluster.Name},
+		client.HasLabels{clusterv1.MachineControlPlaneLabelName}); err != nil {
+		err = errors.Wrapf(err, "unable to list control plane VSphereVMs of VSphereCluster %s/%s",
+			ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+		ctx.Logger.Error(err, "failed to reconcile control plane anti-affinity rules")
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneAntiAffinityCondition, infrav1.AntiAffinityRuleFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return
+	}
+
+	hash := controlPlaneVMsHash(vsphereVMs.Items)
+	if hash == ctx.VSphereCluster.Status.ControlPlaneAntiAffinityHash &&
+		conditions.IsTrue(ctx.VSphereCluster, infrav1.ControlPlaneAntiAffinityCondition) {
+		return
+	}
+
+	if err := r.reconcileAntiAffinityRules(ctx, vsphereVMs.Items); err != nil {
+		ctx.Logger.Error(err, "failed to reconcile control plane anti-affinity rules")
+		conditions.MarkFalse(ctx.VSphereCluster, infrav1.ControlPlaneAntiAffinityCondition, infrav1.AntiAffinityRuleFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return
+	}
+	ctx.VSphereCluster.Status.ControlPlaneAntiAffinityHash = hash
+	conditions.MarkTrue(ctx.VSphereCluster, infrav1.ControlPlaneAntiAffinityCondition)
+}
+
+// controlPlaneVMsHash returns a hash of the VSphereVMs that have a BIOS UUID,
+// their BIOS UUIDs and whether they are being deleted.
+func controlPlaneVMsHash(vsphereVMs []infrav1.VSphereVM) string {
+	var members []string
+	for i := range vsphereVMs {
+		vsphereVM := &vsphereVMs[i]
+		if vsphereVM.Spec.BiosUUID == "" {
+			continue
+		}
+		members = append(members, fmt.Sprintf("%s/%s/%s/%t",
+			vsphereVM.Name, vsphereVM.Spec.Server, vsphereVM.Spec.BiosUUID, vsphereVM.DeletionTimestamp.IsZero()))
+	}
+	sort.Strings(members)
+	sum := sha256.Sum256([]byte(strings.Join(members, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r clusterReconciler) reconcileAntiAffinityRules(ctx *context.ClusterContext, vsphereVMs []infrav1.VSphereVM) error {
+	// The references are cached by BIOS UUID. Only the references to the
+	// current VMs are kept, and none are kept if the rules could not be
+	// reconciled, in case one of the references is stale.
+	cacheKey := ctx.VSphereCluster.Namespace + "/" + ctx.VSphereCluster.Name
+	cachedRefs := map[string]vimtypes.ManagedObjectReference{}
+	if cached, ok := r.controlPlaneVMRefs.Load(cacheKey); ok {
+		cachedRefs = cached.(map[string]vimtypes.ManagedObjectReference)
+	}
+	r.controlPlaneVMRefs.Delete(cacheKey)
+	refs := map[string]vimtypes.ManagedObjectReference{}
+
+	// Group the VMs by the compute cluster they run in. The compute clusters
+	// of the VMs that are being deleted are included, so the VMs are removed
+	// from the rules before they are destroyed.
+	type ruleMembers struct {
+		computeCluster *object.ClusterComputeResource
+		vms            []vimtypes.ManagedObjectReference
+	}
+	rules := map[string]*ruleMembers{}
+	for i := range vsphereVMs {
+		vsphereVM := &vsphereVMs[i]
+		if vsphereVM.Spec.BiosUUID == "" {
+			continue
+		}
+		s, err := r.getVMSession(ctx, vsphereVM)
+		if err != nil {
+			return err
+		}
+		defer s.Release()
+		refKey := vsphereVM.Spec.Server + "/" + vsphereVM.Spec.BiosUUID
+		ref, ok := cachedRefs[refKey]
+		if !ok {
+			found, err := s.FindByBIOSUUID(ctx, vsphereVM.Spec.BiosUUID)
+			if err != nil {
+				return errors.Wrapf(err, "unable to find VM for VSphereVM %s/%s", vsphereVM.Namespace, vsphereVM.Name)
+			}
+			if found == nil {
+				continue
+			}
+			ref = found.Reference()
+		}
+		refs[refKey] = ref
+		vm := object.NewVirtualMachine(s.Client.Client, ref)
+		computeCluster, err := govmomicluster.GetComputeCluster(ctx, vm)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get compute cluster of VSphereVM %s/%s", vsphereVM.Namespace, vsphereVM.Name)
+		}
+		if computeCluster == nil {
+			continue
+		}
+		key := vsphereVM.Spec.Server + "/" + computeCluster.Reference().Value
+		if rules[key] == nil {
+			rules[key] = &ruleMembers{computeCluster: computeCluster}
+		}
+		if vsphereVM.DeletionTimestamp.IsZero() {
+			rules[key].vms = append(rules[key].vms, vm.Reference())
+		}
+	}
+
+	ruleName := fmt.Sprintf("%s-%s-control-plane", ctx.VSphereCluster.Namespace, ctx.VSphereCluster.Name)
+	for _, rule := range rules {
+		if err := govmomicluster.EnsureAntiAffinityRule(ctx, rule.computeCluster, ruleName, rule.vms); err != nil {
+			return err
+		}
+	}
+	r.controlPlaneVMRefs.Store(cacheKey, refs)
+	return nil
+}
+
+// getVMSession gets or creates a session to the vSphere endpoint of the
+// VSphereVM using the VSphereCluster's credentials.
+func (r clusterReconciler) getVMSession(ctx *context.ClusterContext, vsphereVM *infrav1.VSphereVM) (*session.Session, error) {
+	username, password := ctx.GetCredentials()
+	params, err := identity.GetSessionParams(ctx, ctx.Client, ctx.VSphereCluster, vsphereVM, ctx.Namespace,
+		identity.Credentials{Username: username, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	return ctx.SessionProvider.GetOrCreate(ctx, params)
+}
+
+// controlPlaneVMToCluster is a handler.ToRequestsFunc that triggers
+// reconcile events for a VSphereCluster resource when one of its control
+// plane VSphereVMs is created or deleted.
+func (r clusterReconciler) controlPlaneVMToCluster(o handler.MapObject) []ctrl.Request {
+	vsphereVM, ok := o.Object.(*infrav1.VSphereVM)
+	if !ok {
+		r.Logger.Error(nil, fmt.Sprintf("expected a VSphereVM but got a %T", o.Object))
+		return nil
+	}
+	if !infrautilv1.IsControlPlaneMachine(vsphereVM) || vsphereVM.Spec.BiosUUID == "" {
+		return nil
+	}
+
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, vsphereVM.ObjectMeta)
+	if err != nil {
+		r.Logger.Error(err, "VSphereVM is missing cluster label or cluster does not exist",
+			"namespace", vsphereVM.Namespace, "name", vsphereVM.Name)
+		return nil
+	}
+	if cluster.Spec.InfrastructureRef == nil {
+		return nil
+	}
+
+	return []ctrl.Request{{
+		NamespacedName: types.NamespacedName{
+			Namespace: vsphereVM.Namespace,
+			Name:      cluster.Spec.InfrastructureRef.Name,
+		},
+	}}
+}
+
 // controlPlaneMachineToCluster is a handler.ToRequestsFunc to be used
 // to enqueue requests for reconciliation for VSphereCluster to update
 // its status.apiEndpoints field.
@@ -0,0 +1,163 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	ctrlutil "sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+)
+
+var (
+	identityControlledType     = &infrav1.VSphereClusterIdentity{}
+	identityControlledTypeName = reflect.TypeOf(identityControlledType).Elem().Name()
+)
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusteridentities,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=vsphereclusteridentities/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;patch
+
+// AddVSphereClusterIdentityControllerToManager adds the VSphereClusterIdentity
+// controller to the provided manager.
+func AddVSphereClusterIdentityControllerToManager(ctx *context.ControllerManagerContext, mgr manager.Manager) error {
+
+	var (
+		controllerNameShort = fmt.Sprintf("%s-controller", strings.ToLower(identityControlledTypeName))
+		controllerNameLong  = fmt.Sprintf("%s/%s/%s", ctx.Namespace, ctx.Name, controllerNameShort)
+	)
+
+	// Build the controller context.
+	controllerContext := &context.ControllerContext{
+		ControllerManagerContext: ctx,
+		Name:                     controllerNameShort,
+		Recorder:                 record.New(mgr.GetEventRecorderFor(controllerNameLong)),
+		Logger:                   ctx.Logger.WithName(controllerNameShort),
+	}
+
+	reconciler := clusterIdentityReconciler{ControllerContext: controllerContext}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		// Watch the controlled, infrastructure resource.
+		For(identityControlledType).
+		// Watch the Secrets adopted by an identity so changes to them are
+		// reflected in the identity's status.
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			&handler.EnqueueRequestForOwner{OwnerType: identityControlledType, IsController: true},
+		).
+		WithOptions(controller.Options{MaxConcurrentReconciles: ctx.MaxConcurrentReconciles}).
+		Complete(reconciler)
+}
+
+type clusterIdentityReconciler struct {
+	*context.ControllerContext
+}
+
+// Reconcile ensures the Secret referenced by a VSphereClusterIdentity exists
+// and is owned by the identity.
+func (r clusterIdentityReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error) {
+	logger := r.Logger.WithName(req.Name)
+
+	identity := &infrav1.VSphereClusterIdentity{}
+	if err := r.Client.Get(r, req.NamespacedName, identity); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.V(4).Info("VSphereClusterIdentity not found, won't reconcile")
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	// Create the patch helper.
+	patchHelper, err := patch.NewHelper(identity, r.Client)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrapf(
+			err,
+			"failed to init patch helper for %s %s",
+			identity.GroupVersionKind(),
+			identity.Name)
+	}
+
+	// Always issue a patch when exiting this function so changes to the
+	// resource are patched back to the API server.
+	defer func() {
+		conditions.SetSummary(identity, conditions.WithConditions(infrav1.CredentialsAvailableCondition))
+		if err := patchHelper.Patch(r, identity); err != nil {
+			if reterr == nil {
+				reterr = err
+			}
+			logger.Error(err, "patch failed", "identity", identity.Name)
+		}
+	}()
+
+	// The Secret is garbage collected along with the identity, so there is
+	// nothing to do upon deletion.
+	if !identity.DeletionTimestamp.IsZero() {
+		return reconcile.Result{}, nil
+	}
+
+	identity.Status.Ready = false
+
+	secret := &corev1.Secret{}
+	secretKey := client.ObjectKey{Namespace: r.Namespace, Name: identity.Spec.SecretName}
+	if err := r.Client.Get(r, secretKey, secret); err != nil {
+		conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondition, infrav1.SecretNotAvailableReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to get secret %s for VSphereClusterIdentity %s", secretKey, identity.Name)
+	}
+
+	// A Secret may only back a single identity.
+	if owner := metav1.GetControllerOf(secret); owner != nil && owner.UID != identity.UID {
+		conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondition, infrav1.SecretAlreadyInUseReason, clusterv1.ConditionSeverityWarning,
+			"secret %s is already in use by %s %s", secretKey, owner.Kind, owner.Name)
+		return reconcile.Result{}, nil
+	}
+
+	original := secret.DeepCopy()
+	if err := ctrlutil.SetControllerReference(identity, secret, r.Scheme); err != nil {
+		conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondition, infrav1.SecretOwnerReferenceFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to set owner reference on secret %s", secretKey)
+	}
+	if err := r.Client.Patch(r, secret, client.MergeFrom(original)); err != nil {
+		conditions.MarkFalse(identity, infrav1.CredentialsAvailableCondition, infrav1.SecretOwnerReferenceFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return reconcile.Result{}, errors.Wrapf(err, "failed to patch secret %s", secretKey)
+	}
+
+	conditions.MarkTrue(identity, infrav1.CredentialsAvailableCondition)
+	identity.Status.Ready = true
+
+	return reconcile.Result{}, nil
+}
@@ -49,7 +49,10 @@ import (
 	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 	infrautilv1 "sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
@@ -350,6 +353,16 @@ func (r machineReconciler) reconcileNormal(ctx *context.MachineContext) (reconci
 	vmObj.SetAPIVersion(vm.GetObjectKind().GroupVersionKind().GroupVersion().String())
 	vmObj.SetKind(vm.GetObjectKind().GroupVersionKind().Kind)
 
+	// Reflect the progress of the in-place resize of the VM, if any, and
+	// whether the VM is a linked clone.
+	for _, t := range []clusterv1.ConditionType{infrav1.VMResizedCondition, infrav1.LinkedCloneCondition} {
+		if c := conditions.Get(conditions.UnstructuredGetter(vmObj), t); c != nil {
+			conditions.Set(ctx.VSphereMachine, c)
+		} else {
+			conditions.Delete(ctx.VSphereMachine, t)
+		}
+	}
+
 	// Waits the VM's ready state.
 	if ok, err := r.waitReadyState(ctx, vmObj); !ok {
 		if err != nil {
@@ -390,6 +403,15 @@ func (r machineReconciler) reconcileNormal(ctx *context.MachineContext) (reconci
 }
 
 func (r machineReconciler) reconcileNormalPre7(ctx *context.MachineContext, vsphereVM *infrav1.VSphereVM) (runtime.Object, error) {
+	// Get the failure domain the Machine is placed in, if any.
+	var failureDomain *infrav1.VSphereFailureDomain
+	if name := ctx.Machine.Spec.FailureDomain; name != nil && *name != "" {
+		var err error
+		if failureDomain, err = infrautilv1.GetFailureDomain(ctx.VSphereCluster, *name); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create or update the VSphereVM resource.
 	vm := &infrav1.VSphereVM{
 		ObjectMeta: metav1.ObjectMeta{
@@ -439,18 +461,21 @@ func (r machineReconciler) reconcileNormalPre7(ctx *context.MachineContext, vsph
 		// Several of the VSphereVM's clone spec properties can be derived
 		// from multiple places. The order is:
 		//
-		//   1. From the VSphereMachine.Spec (the DeepCopyInto above)
-		//   2. From the VSphereCluster.Spec.CloudProviderConfiguration.Workspace
-		//   3. From the VSphereCluster.Spec
+		//   1. From the VSphereCluster.Spec.FailureDomains entry that
+		//      matches the Machine.Spec.FailureDomain
+		//   2. From the VSphereMachine.Spec (the DeepCopyInto above)
+		//   3. From the VSphereCluster.Spec.CloudProviderConfiguration.Workspace
+		//   4. From the VSphereCluster.Spec
+		if failureDomain != nil {
+			infrautilv1.ApplyFailureDomain(&vm.Spec, failureDomain)
+		}
 		vsphereCloudConfig := ctx.VSphereCluster.Spec.CloudProviderConfiguration.Workspace
 		if vm.Spec.Server == "" {
 			if vm.Spec.Server = vsphereCloudConfig.Server; vm.Spec.Server == "" {
 				vm.Spec.Server = ctx.VSphereCluster.Spec.Server
 			}
 		}
-		if vm.Spec.Thumbprint == "" {
-			vm.Spec.Thumbprint = ctx.VSphereCluster.Spec.Thumbprint
-		}
+		infrautilv1.InheritTrust(&vm.Spec.VirtualMachineCloneSpec, ctx.VSphereCluster)
 		if vm.Spec.Datacenter == "" {
 			vm.Spec.Datacenter = vsphereCloudConfig.Datacenter
 		}
@@ -463,6 +488,19 @@ func (r machineReconciler) reconcileNormalPre7(ctx *context.MachineContext, vsph
 		if vm.Spec.ResourcePool == "" {
 			vm.Spec.ResourcePool = vsphereCloudConfig.ResourcePool
 		}
+
+		// The VSphereMachine's template selector is resolved when the
+		// VSphereVM is created. Afterwards the VSphereVM keeps its template,
+		// so templates tagged later do not affect existing machines.
+		if vm.Spec.TemplateSelector != nil {
+			vm.Spec.TemplateSelector = nil
+			if vsphereVM != nil {
+				vm.Spec.Template = vsphereVM.Spec.Template
+			} else if vm.Spec.Template, err = r.resolveTemplate(ctx, vm); err != nil {
+				return err
+			}
+		}
+
 		if vsphereVM != nil {
 			vm.Spec.BiosUUID = vsphereVM.Spec.BiosUUID
 		}
@@ -477,10 +515,50 @@ func (r machineReconciler) reconcileNormalPre7(ctx *context.MachineContext, vsph
 			"namespace", vm.Namespace, "name", vm.Name)
 		return nil, err
 	}
+	if ctx.VSphereMachine.Spec.TemplateSelector != nil {
+		ctx.VSphereMachine.Status.Template = vm.Spec.Template
+	}
 
 	return vm, nil
 }
 
+// resolveTemplate returns the template selected by the VSphereMachine's
+// template selector for the Machine's Kubernetes version. The
+// VMProvisionedCondition is marked with the TemplateNotFoundReason if the
+// selector does not select exactly one template.
+func (r machineReconciler) resolveTemplate(ctx *context.MachineContext, vm *infrav1.VSphereVM) (string, error) {
+	s, err := r.getVMSession(ctx, vm)
+	if err != nil {
+		return "", err
+	}
+	defer s.Release()
+	var version string
+	if ctx.Machine.Spec.Version != nil {
+		version = *ctx.Machine.Spec.Version
+	}
+	tpl, err := template.Resolve(ctx, s, ctx.VSphereMachine.Spec.TemplateSelector, version)
+	if err != nil {
+		if _, ok := err.(*template.NotFoundError); ok {
+			conditions.MarkFalse(ctx.VSphereMachine, infrav1.VMProvisionedCondition, infrav1.TemplateNotFoundReason, clusterv1.ConditionSeverityWarning, err.Error())
+		}
+		return "", errors.Wrapf(err, "failed to resolve template for %s", ctx)
+	}
+	ctx.Logger.Info("resolved template", "template", tpl, "version", version)
+	return tpl, nil
+}
+
+// getVMSession gets or creates a session to the vSphere endpoint of the
+// VSphereVM that is about to be created for the VSphereMachine.
+func (r machineReconciler) getVMSession(ctx *context.MachineContext, vm *infrav1.VSphereVM) (*session.Session, error) {
+	username, password := ctx.GetCredentials()
+	params, err := identity.GetSessionParams(ctx, ctx.Client, ctx.VSphereCluster, vm, ctx.Namespace,
+		identity.Credentials{Username: username, Password: password})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get session parameters for VSphereMachine %s", ctx)
+	}
+	return ctx.SessionProvider.GetOrCreate(ctx, params)
+}
+
 func (r machineReconciler) reconcileNetwork(ctx *context.MachineContext, vm *unstructured.Unstructured) (bool, error) {
 	var errs []error
 	if networkStatusListOfIfaces, ok, _ := unstructured.NestedSlice(vm.Object, "status", "network"); ok {
@@ -46,6 +46,7 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/identity"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi"
@@ -137,12 +138,11 @@ func (r vmReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error)
 	}
 
 	// Get or create an authenticated session to the vSphere endpoint.
-	authSession, err := session.GetOrCreate(r.Context,
-		vsphereVM.Spec.Server, vsphereVM.Spec.Datacenter,
-		r.ControllerManagerContext.Username, r.ControllerManagerContext.Password, vsphereVM.Spec.Thumbprint)
+	authSession, err := r.retrieveVcenterSession(vsphereVM)
 	if err != nil {
 		return reconcile.Result{}, errors.Wrap(err, "failed to create vSphere session")
 	}
+	defer authSession.Release()
 
 	// Create the patch helper.
 	patchHelper, err := patch.NewHelper(vsphereVM, r.Client)
@@ -280,6 +280,46 @@ func (r vmReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, reterr error)
 	return r.reconcileNormal(vmContext)
 }
 
+// retrieveVcenterSession gets or creates a session to the vSphere endpoint
+// of the VSphereVM with the credentials of the VSphereCluster the VM belongs
+// to.
+func (r vmReconciler) retrieveVcenterSession(vsphereVM *infrav1.VSphereVM) (*session.Session, error) {
+	vsphereCluster, err := r.getVSphereCluster(vsphereVM)
+	if err != nil {
+		return nil, err
+	}
+	username, password := r.GetCredentials()
+	params, err := identity.GetSessionParams(r, r.Client, vsphereCluster, vsphereVM, r.Namespace,
+		identity.Credentials{Username: username, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	return r.SessionProvider.GetOrCreate(r.Context, params)
+}
+
+// getVSphereCluster returns the VSphereCluster for the CAPI cluster the
+// VSphereVM belongs to. An error is returned if the VSphereVM is not
+// associated with a cluster or the VSphereCluster cannot be found, rather
+// than falling back to the controller manager's credentials, since the
+// VSphereCluster may reference the identity that is to be used instead.
+func (r vmReconciler) getVSphereCluster(vsphereVM *infrav1.VSphereVM) (*infrav1.VSphereCluster, error) {
+	cluster, err := clusterutilv1.GetClusterFromMetadata(r, r.Client, vsphereVM.ObjectMeta)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get cluster for VSphereVM %s/%s", vsphereVM.Namespace, vsphereVM.Name)
+	}
+	ref := cluster.Spec.InfrastructureRef
+	if ref == nil || ref.Kind != "VSphereCluster" {
+		return nil, errors.Errorf("cluster %s/%s of VSphereVM %s/%s does not reference a VSphereCluster",
+			cluster.Namespace, cluster.Name, vsphereVM.Namespace, vsphereVM.Name)
+	}
+	vsphereCluster := &infrav1.VSphereCluster{}
+	key := ctrlclient.ObjectKey{Namespace: cluster.Namespace, Name: ref.Name}
+	if err := r.Client.Get(r, key, vsphereCluster); err != nil {
+		return nil, errors.Wrapf(err, "failed to get VSphereCluster %s", key)
+	}
+	return vsphereCluster, nil
+}
+
 func (r vmReconciler) reconcileDelete(ctx *context.VMContext) (reconcile.Result, error) {
 	ctx.Logger.Info("Handling deleted VSphereVM")
 
@@ -293,6 +333,13 @@ func (r vmReconciler) reconcileDelete(ctx *context.VMContext) (reconcile.Result,
 		return reconcile.Result{}, errors.Wrapf(err, "failed to destroy VM")
 	}
 
+	// Check again whether the guest is shut down once it had a chance to,
+	// since shutting down the guest is not tracked by a task.
+	if conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition) == infrav1.ShuttingDownGuestReason {
+		ctx.Logger.Info("waiting for the guest to shut down")
+		return reconcile.Result{RequeueAfter: 15 * time.Second}, nil
+	}
+
 	// Requeue the operation until the VM is "notfound".
 	if vm.State != infrav1.VirtualMachineStateNotFound {
 		ctx.Logger.Info("vm state is not reconciled", "expected-vm-state", infrav1.VirtualMachineStateNotFound, "actual-vm-state", vm.State)
@@ -328,6 +375,18 @@ func (r vmReconciler) reconcileNormal(ctx *context.VMContext) (reconcile.Result,
 		return reconcile.Result{}, errors.Wrapf(err, "failed to reconcile VM")
 	}
 
+	// Update the VSphereVM's disk layout once the VM exists.
+	if vm.Disks != nil {
+		ctx.VSphereVM.Status.Disks = vm.Disks
+	}
+
+	// Check again for capacity to clone the VM once the in-flight clone
+	// operations had a chance to complete.
+	if conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition) == infrav1.WaitingForCapacityReason {
+		ctx.Logger.Info("waiting for capacity to clone the VM")
+		return reconcile.Result{RequeueAfter: 15 * time.Second}, nil
+	}
+
 	// Do not proceed until the backend VM is marked ready.
 	if vm.State != infrav1.VirtualMachineStateReady {
 		ctx.Logger.Info(
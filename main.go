@@ -37,6 +37,7 @@ import (
 	"sigs.k8s.io/cluster-api-provider-vsphere/controllers"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/manager"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/version"
 )
 
@@ -44,6 +45,7 @@ var (
 	setupLog = ctrllog.Log.WithName("entrypoint")
 
 	managerOpts             manager.Options
+	vsphereQPS              float64
 	defaultProfilerAddr     = os.Getenv("PROFILER_ADDR")
 	defaultSyncPeriod       = manager.DefaultSyncPeriod
 	defaultLeaderElectionID = manager.DefaultLeaderElectionID
@@ -117,9 +119,31 @@ func main() {
 		"/etc/capv/credentials.yaml",
 		"path to CAPV's credentials file",
 	)
+	flag.Float64Var(
+		&vsphereQPS,
+		"vsphere-qps",
+		session.DefaultQPS,
+		"The sustained number of requests per second sent to each vSphere server (set to a negative value to disable rate limiting).")
+	flag.IntVar(
+		&managerOpts.VSphereBurst,
+		"vsphere-burst",
+		session.DefaultBurst,
+		"The number of requests that may be sent to each vSphere server in excess of the QPS.")
+	flag.IntVar(
+		&managerOpts.MaxConcurrentClones,
+		"max-concurrent-clones",
+		session.DefaultMaxConcurrentClones,
+		"The maximum number of in-flight clone operations on each vSphere server (set to a negative value to remove the limit).")
+	flag.DurationVar(
+		&managerOpts.VMShutdownTimeout,
+		"vm-shutdown-timeout",
+		manager.DefaultVMShutdownTimeout,
+		"How long the guest of a VM is given to shut down before the VM is powered off and destroyed (set to a negative value to power off VMs without shutting down their guests).")
 
 	flag.Parse()
 
+	managerOpts.VSphereQPS = float32(vsphereQPS)
+
 	if managerOpts.WatchNamespace != "" {
 		setupLog.Info(
 			"Watching objects only in namespace for reconciliation",
@@ -193,6 +217,9 @@ func main() {
 			if err := controllers.AddHAProxyLoadBalancerControllerToManager(ctx, mgr); err != nil {
 				return err
 			}
+			if err := controllers.AddVSphereClusterIdentityControllerToManager(ctx, mgr); err != nil {
+				return err
+			}
 		}
 
 		return nil
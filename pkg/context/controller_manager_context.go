@@ -19,6 +19,8 @@ package context
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -27,6 +29,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
 // ControllerManagerContext is the context of the controller that owns the
@@ -69,17 +72,43 @@ type ControllerManagerContext struct {
 	// controller will receive concurrently.
 	MaxConcurrentReconciles int
 
-	// Username is the username for the account used to access remote vSphere
-	// endpoints.
-	Username string
+	// VMShutdownTimeout is how long the guest of a VM is given to shut down
+	// before the VM is powered off and destroyed, unless the VM sets its own
+	// timeout. A negative value powers VMs off without shutting down their
+	// guests.
+	VMShutdownTimeout time.Duration
 
-	// Password is the password for the account used to access remote vSphere
-	// endpoints.
-	Password string
+	// SessionProvider is used to get or create sessions to vSphere servers.
+	SessionProvider session.Provider
+
+	// credentials holds the username and password for the account used to
+	// access remote vSphere endpoints. It is replaced atomically when the
+	// credentials are reloaded.
+	credentials atomic.Value
 
 	genericEventCache sync.Map
 }
 
+type credentials struct {
+	username string
+	password string
+}
+
+// GetCredentials returns the username and password for the account used to
+// access remote vSphere endpoints.
+func (c *ControllerManagerContext) GetCredentials() (username, password string) {
+	if creds, ok := c.credentials.Load().(credentials); ok {
+		return creds.username, creds.password
+	}
+	return "", ""
+}
+
+// SetCredentials atomically replaces the username and password for the
+// account used to access remote vSphere endpoints.
+func (c *ControllerManagerContext) SetCredentials(username, password string) {
+	c.credentials.Store(credentials{username: username, password: password})
+}
+
 // String returns ControllerManagerName.
 func (c *ControllerManagerContext) String() string {
 	return c.Name
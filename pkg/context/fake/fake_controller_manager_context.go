@@ -29,6 +29,7 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
 // NewControllerManagerContext returns a fake ControllerManagerContext for unit
@@ -51,5 +52,6 @@ func NewControllerManagerContext(initObjects ...runtime.Object) *context.Control
 		LeaderElectionNamespace: LeaderElectionNamespace,
 		LeaderElectionID:        LeaderElectionID,
 		Recorder:                record.New(clientrecord.NewFakeRecorder(1024)),
+		SessionProvider:         session.NewManager(session.ManagerOptions{}),
 	}
 }
@@ -0,0 +1,160 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+const (
+	// UsernameKey is the key of the username in an identity Secret.
+	UsernameKey = "username"
+
+	// PasswordKey is the key of the password in an identity Secret.
+	PasswordKey = "password"
+)
+
+// Credentials are the username and password used to access a vSphere
+// endpoint.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// IsSecretIdentity returns true if the VSphereCluster references a Secret
+// directly rather than a VSphereClusterIdentity.
+func IsSecretIdentity(cluster *infrav1.VSphereCluster) bool {
+	return cluster.Spec.IdentityRef != nil && cluster.Spec.IdentityRef.Kind == infrav1.SecretKind
+}
+
+// GetCredentials returns the credentials referenced by the IdentityRef of
+// the provided VSphereCluster. Secrets referenced by a VSphereClusterIdentity
+// are read from the controllerNamespace, while Secrets referenced directly
+// are read from the namespace of the VSphereCluster.
+func GetCredentials(ctx context.Context, c client.Client, cluster *infrav1.VSphereCluster, controllerNamespace string) (*Credentials, error) {
+	ref := cluster.Spec.IdentityRef
+	if ref == nil {
+		return nil, errors.Errorf("vspherecluster %s/%s does not have an identityRef", cluster.Namespace, cluster.Name)
+	}
+
+	secretKey := client.ObjectKey{Namespace: cluster.Namespace, Name: ref.Name}
+	switch ref.Kind {
+	case infrav1.SecretKind:
+	case infrav1.VSphereClusterIdentityKind:
+		identity := &infrav1.VSphereClusterIdentity{}
+		if err := c.Get(ctx, client.ObjectKey{Name: ref.Name}, identity); err != nil {
+			return nil, errors.Wrapf(err, "failed to get vsphereclusteridentity %s", ref.Name)
+		}
+		if !identity.Status.Ready {
+			return nil, errors.Errorf("vsphereclusteridentity %s is not ready", identity.Name)
+		}
+		allowed, err := isAllowedNamespace(ctx, c, identity, cluster.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, errors.Errorf("namespace %s is not allowed to use vsphereclusteridentity %s", cluster.Namespace, identity.Name)
+		}
+		secretKey = client.ObjectKey{Namespace: controllerNamespace, Name: identity.Spec.SecretName}
+	default:
+		return nil, errors.Errorf("unsupported identity kind %q", ref.Kind)
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, secretKey, secret); err != nil {
+		return nil, errors.Wrapf(err, "failed to get identity secret %s", secretKey)
+	}
+	username, ok := secret.Data[UsernameKey]
+	if !ok || len(username) == 0 {
+		return nil, errors.Errorf("identity secret %s is missing key %q", secretKey, UsernameKey)
+	}
+	password, ok := secret.Data[PasswordKey]
+	if !ok || len(password) == 0 {
+		return nil, errors.Errorf("identity secret %s is missing key %q", secretKey, PasswordKey)
+	}
+
+	return &Credentials{
+		Username: string(username),
+		Password: string(password),
+	}, nil
+}
+
+// GetSessionParams returns the parameters of a session to the vSphere
+// endpoint of the VSphereVM. The credentials of the identity referenced by
+// the VSphereCluster the VM belongs to are used if there is one, otherwise
+// the controller manager's credentials are used. The server's certificate is
+// verified with the VSphereVM's trust settings.
+func GetSessionParams(
+	ctx context.Context,
+	c client.Client,
+	cluster *infrav1.VSphereCluster,
+	vm *infrav1.VSphereVM,
+	controllerNamespace string,
+	managerCredentials Credentials) (session.Params, error) {
+
+	params := session.Params{
+		Server:     vm.Spec.Server,
+		Datacenter: vm.Spec.Datacenter,
+		Username:   managerCredentials.Username,
+		Password:   managerCredentials.Password,
+		Source:     session.ManagerCredentials,
+	}
+	if cluster.Spec.IdentityRef != nil {
+		creds, err := GetCredentials(ctx, c, cluster, controllerNamespace)
+		if err != nil {
+			return session.Params{}, errors.Wrapf(err, "failed to get credentials for VSphereVM %s/%s", vm.Namespace, vm.Name)
+		}
+		params.Username, params.Password = creds.Username, creds.Password
+		params.Source = session.IdentityCredentials
+	}
+
+	trust, err := util.GetTrust(ctx, c, vm.Namespace, &vm.Spec.VirtualMachineCloneSpec)
+	if err != nil {
+		return session.Params{}, errors.Wrapf(err, "failed to get trust settings for VSphereVM %s/%s", vm.Namespace, vm.Name)
+	}
+	params.Trust = trust
+
+	return params, nil
+}
+
+func isAllowedNamespace(ctx context.Context, c client.Client, identity *infrav1.VSphereClusterIdentity, namespace string) (bool, error) {
+	if identity.Spec.AllowedNamespaces == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&identity.Spec.AllowedNamespaces.Selector)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid allowedNamespaces selector for vsphereclusteridentity %s", identity.Name)
+	}
+	if selector.Empty() {
+		return true, nil
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return false, errors.Wrapf(err, "failed to get namespace %s", namespace)
+	}
+	return selector.Matches(labels.Set(ns.GetLabels())), nil
+}
@@ -0,0 +1,192 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package identity
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const (
+	controllerNamespace = "capv-system"
+	clusterNamespace    = "tenant"
+)
+
+func TestGetCredentials(t *testing.T) {
+	secret := func(namespace string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "creds"},
+			Data: map[string][]byte{
+				UsernameKey: []byte("user"),
+				PasswordKey: []byte("pass"),
+			},
+		}
+	}
+	identity := func(ready bool, allowed *infrav1.AllowedNamespaces) *infrav1.VSphereClusterIdentity {
+		return &infrav1.VSphereClusterIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "tenant-identity"},
+			Spec: infrav1.VSphereClusterIdentitySpec{
+				SecretName:        "creds",
+				AllowedNamespaces: allowed,
+			},
+			Status: infrav1.VSphereClusterIdentityStatus{Ready: ready},
+		}
+	}
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterNamespace, Labels: map[string]string{"tenant": "a"}},
+	}
+	cluster := func(kind infrav1.VSphereIdentityKind, name string) *infrav1.VSphereCluster {
+		return &infrav1.VSphereCluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: clusterNamespace, Name: "cluster"},
+			Spec: infrav1.VSphereClusterSpec{
+				IdentityRef: &infrav1.VSphereIdentityReference{Kind: kind, Name: name},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		objects   []runtime.Object
+		cluster   *infrav1.VSphereCluster
+		expectErr bool
+	}{
+		{
+			name:    "secret in the cluster namespace",
+			objects: []runtime.Object{secret(clusterNamespace)},
+			cluster: cluster(infrav1.SecretKind, "creds"),
+		},
+		{
+			name:      "secret in the controller namespace is not used for a secret ref",
+			objects:   []runtime.Object{secret(controllerNamespace)},
+			cluster:   cluster(infrav1.SecretKind, "creds"),
+			expectErr: true,
+		},
+		{
+			name:    "identity allowing all namespaces",
+			objects: []runtime.Object{secret(controllerNamespace), identity(true, &infrav1.AllowedNamespaces{})},
+			cluster: cluster(infrav1.VSphereClusterIdentityKind, "tenant-identity"),
+		},
+		{
+			name: "identity with a matching namespace selector",
+			objects: []runtime.Object{secret(controllerNamespace), namespace, identity(true, &infrav1.AllowedNamespaces{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "a"}},
+			})},
+			cluster: cluster(infrav1.VSphereClusterIdentityKind, "tenant-identity"),
+		},
+		{
+			name: "identity with a non-matching namespace selector",
+			objects: []runtime.Object{secret(controllerNamespace), namespace, identity(true, &infrav1.AllowedNamespaces{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "b"}},
+			})},
+			cluster:   cluster(infrav1.VSphereClusterIdentityKind, "tenant-identity"),
+			expectErr: true,
+		},
+		{
+			name:      "identity without allowed namespaces",
+			objects:   []runtime.Object{secret(controllerNamespace), identity(true, nil)},
+			cluster:   cluster(infrav1.VSphereClusterIdentityKind, "tenant-identity"),
+			expectErr: true,
+		},
+		{
+			name:      "identity that is not ready",
+			objects:   []runtime.Object{secret(controllerNamespace), identity(false, &infrav1.AllowedNamespaces{})},
+			cluster:   cluster(infrav1.VSphereClusterIdentityKind, "tenant-identity"),
+			expectErr: true,
+		},
+		{
+			name:      "missing identity",
+			objects:   []runtime.Object{secret(controllerNamespace)},
+			cluster:   cluster(infrav1.VSphereClusterIdentityKind, "tenant-identity"),
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			c := fake.NewFakeClientWithScheme(scheme, tc.objects...)
+
+			creds, err := GetCredentials(context.Background(), c, tc.cluster, controllerNamespace)
+			if tc.expectErr {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(creds.Username).To(Equal("user"))
+			g.Expect(creds.Password).To(Equal("pass"))
+		})
+	}
+}
+
+func TestGetSessionParams(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	c := fake.NewFakeClientWithScheme(scheme, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: clusterNamespace, Name: "creds"},
+		Data: map[string][]byte{
+			UsernameKey: []byte("user"),
+			PasswordKey: []byte("pass"),
+		},
+	})
+	vm := &infrav1.VSphereVM{
+		ObjectMeta: metav1.ObjectMeta{Namespace: clusterNamespace, Name: "vm"},
+		Spec: infrav1.VSphereVMSpec{
+			VirtualMachineCloneSpec: infrav1.VirtualMachineCloneSpec{
+				Server:     "vcenter",
+				Datacenter: "dc",
+				Thumbprint: "thumbprint",
+			},
+		},
+	}
+	managerCredentials := Credentials{Username: "manager", Password: "manager-pass"}
+
+	// The controller manager's credentials are used without an identity.
+	cluster := &infrav1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{Namespace: clusterNamespace, Name: "cluster"}}
+	params, err := GetSessionParams(context.Background(), c, cluster, vm, controllerNamespace, managerCredentials)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(params.Server).To(Equal("vcenter"))
+	g.Expect(params.Datacenter).To(Equal("dc"))
+	g.Expect(params.Username).To(Equal("manager"))
+	g.Expect(params.Password).To(Equal("manager-pass"))
+	g.Expect(params.Trust.Thumbprint).To(Equal("thumbprint"))
+	g.Expect(params.Source).To(Equal(session.ManagerCredentials))
+
+	// The credentials of the identity are used when there is one.
+	cluster.Spec.IdentityRef = &infrav1.VSphereIdentityReference{Kind: infrav1.SecretKind, Name: "creds"}
+	params, err = GetSessionParams(context.Background(), c, cluster, vm, controllerNamespace, managerCredentials)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(params.Username).To(Equal("user"))
+	g.Expect(params.Password).To(Equal("pass"))
+	g.Expect(params.Source).To(Equal(session.IdentityCredentials))
+}
@@ -29,6 +29,14 @@ const (
 	// manager option.
 	DefaultSyncPeriod = time.Minute * 10
 
+	// DefaultCredentialsReloadInterval is the default value for the eponymous
+	// manager option.
+	DefaultCredentialsReloadInterval = time.Second * 10
+
+	// DefaultVMShutdownTimeout is the default value for the eponymous manager
+	// option.
+	DefaultVMShutdownTimeout = time.Minute * 5
+
 	// DefaultPodName is the default value for the eponymous manager option.
 	DefaultPodName = defaultPrefix + "controller-manager"
 
@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// credentialsWatcher periodically reads the credentials file and replaces the
+// credentials in the controller manager context when they change.
+//
+// The file is polled rather than watched with inotify because Secrets mounted
+// into a pod are updated by atomically swapping a symlink, which is not
+// reliably reported as an event on the file itself.
+type credentialsWatcher struct {
+	ctx      *context.ControllerManagerContext
+	logger   logr.Logger
+	path     string
+	interval time.Duration
+
+	// pod is the object the reload events are recorded against.
+	pod *corev1.ObjectReference
+}
+
+// Start implements the manager.Runnable interface.
+func (w *credentialsWatcher) Start(stop <-chan struct{}) error {
+	w.logger.Info("watching credentials file", "path", w.path, "interval", w.interval)
+	wait.Until(w.reload, w.interval, stop)
+	return nil
+}
+
+// NeedLeaderElection implements the manager.LeaderElectionRunnable interface.
+// Every replica must use the current credentials, not just the leader.
+func (w *credentialsWatcher) NeedLeaderElection() bool {
+	return false
+}
+
+// reload reads the credentials file and, if the credentials in it differ from
+// the ones in use, swaps them in and drops the sessions that were created
+// with the old credentials.
+func (w *credentialsWatcher) reload() {
+	credentials, err := readCredentialsFile(w.path)
+	if err != nil {
+		w.logger.Error(err, "failed to reload credentials", "path", w.path)
+		return
+	}
+	username, password := credentials["username"], credentials["password"]
+	if username == "" || password == "" {
+		w.logger.Info("ignoring credentials file with an empty username or password", "path", w.path)
+		return
+	}
+
+	oldUsername, oldPassword := w.ctx.GetCredentials()
+	if username == oldUsername && password == oldPassword {
+		return
+	}
+	w.ctx.SetCredentials(username, password)
+
+	deleted := w.ctx.SessionProvider.DeleteSessionsForUser(w.ctx, oldUsername)
+
+	w.logger.Info("reloaded credentials",
+		"path", w.path,
+		"username", username,
+		"previous-username", oldUsername,
+		"dropped-sessions", deleted)
+	w.ctx.Recorder.Eventf(w.pod, "CredentialsReloaded",
+		"reloaded vSphere credentials for %q from %s", username, w.path)
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestCredentialsWatcherReload(t *testing.T) {
+	g := NewWithT(t)
+
+	dir, err := ioutil.TempDir("", "capv-credentials")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "credentials.yaml")
+
+	ctx := fake.NewControllerManagerContext()
+	ctx.SetCredentials("user", "old")
+
+	w := &credentialsWatcher{
+		ctx:    ctx,
+		logger: ctx.Logger,
+		path:   path,
+		pod:    &corev1.ObjectReference{Kind: "Pod", Name: "capv"},
+	}
+
+	writeFile := func(data string) {
+		g.Expect(ioutil.WriteFile(path, []byte(data), 0600)).To(Succeed())
+	}
+	expectCredentials := func(username, password string) {
+		u, p := ctx.GetCredentials()
+		g.Expect(u).To(Equal(username))
+		g.Expect(p).To(Equal(password))
+	}
+
+	// A missing file keeps the current credentials.
+	w.reload()
+	expectCredentials("user", "old")
+
+	// A rotated password is picked up.
+	writeFile("username: user\npassword: new\n")
+	w.reload()
+	expectCredentials("user", "new")
+
+	// Incomplete credentials are ignored.
+	writeFile("username: other\n")
+	w.reload()
+	expectCredentials("user", "new")
+
+	// Malformed files are ignored.
+	writeFile("username: [")
+	w.reload()
+	expectCredentials("user", "new")
+}
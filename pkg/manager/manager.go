@@ -22,6 +22,7 @@ import (
 	"os"
 
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
@@ -35,6 +36,7 @@ import (
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/record"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
 // Manager is a CAPV controller manager.
@@ -84,17 +86,55 @@ func New(opts Options) (Manager, error) {
 	// Build the controller manager context.
 	controllerManagerContext := &context.ControllerManagerContext{
 		Context:                 goctx.Background(),
-		Namespace:               opts.WatchNamespace,
+		Namespace:               opts.PodNamespace,
+		WatchNamespace:          opts.WatchNamespace,
 		Name:                    opts.PodName,
 		LeaderElectionID:        opts.LeaderElectionID,
 		LeaderElectionNamespace: opts.LeaderElectionNamespace,
 		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+		VMShutdownTimeout:       opts.VMShutdownTimeout,
 		Client:                  mgr.GetClient(),
 		Logger:                  opts.Logger.WithName(opts.PodName),
 		Recorder:                record.New(mgr.GetEventRecorderFor(fmt.Sprintf("%s/%s", opts.PodNamespace, podName))),
 		Scheme:                  opts.Scheme,
-		Username:                opts.Username,
-		Password:                opts.Password,
+	}
+	controllerManagerContext.SetCredentials(opts.Username, opts.Password)
+
+	// Use the provided session provider or create a session manager that
+	// keeps sessions alive and logs out the ones that are no longer used.
+	controllerManagerContext.SessionProvider = opts.SessionProvider
+	if controllerManagerContext.SessionProvider == nil {
+		sessionManager := session.NewManager(session.ManagerOptions{
+			MaxSessions:         opts.MaxSessions,
+			IdleTimeout:         opts.SessionIdleTimeout,
+			KeepAliveInterval:   opts.SessionKeepAliveInterval,
+			QPS:                 opts.VSphereQPS,
+			Burst:               opts.VSphereBurst,
+			MaxConcurrentClones: opts.MaxConcurrentClones,
+			Logger:              controllerManagerContext.Logger.WithName("session-manager"),
+		})
+		if err := mgr.Add(sessionManager); err != nil {
+			return nil, errors.Wrap(err, "failed to add session manager to the manager")
+		}
+		controllerManagerContext.SessionProvider = sessionManager
+	}
+
+	// Reload the credentials when the credentials file changes.
+	if opts.watchCredentialsFile {
+		if err := mgr.Add(&credentialsWatcher{
+			ctx:      controllerManagerContext,
+			logger:   controllerManagerContext.Logger.WithName("credentials"),
+			path:     opts.CredentialsFile,
+			interval: opts.CredentialsReloadInterval,
+			pod: &corev1.ObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Namespace:  opts.PodNamespace,
+				Name:       podName,
+			},
+		}); err != nil {
+			return nil, errors.Wrap(err, "failed to add credentials watcher to the manager")
+		}
 	}
 
 	// Add the requested items to the manager.
@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -35,6 +36,7 @@ import (
 	// +kubebuilder:scaffold:imports
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
 // AddToManagerFunc is a function that can be optionally specified with
@@ -104,6 +106,60 @@ type Options struct {
 	// CredentialsFile is the file that contains credentials of CAPV
 	CredentialsFile string
 
+	// CredentialsReloadInterval is how often the credentials file is checked
+	// for changes. The file is only watched when the credentials were read
+	// from it, rather than set explicitly with Username and Password.
+	//
+	// Defaults to the eponymous constant in this package.
+	CredentialsReloadInterval time.Duration
+
+	// MaxSessions is the maximum number of cached vSphere sessions.
+	//
+	// Defaults to the eponymous constant in the session package.
+	MaxSessions int
+
+	// SessionIdleTimeout is how long a cached vSphere session may go unused
+	// before it is logged out.
+	//
+	// Defaults to the eponymous constant in the session package.
+	SessionIdleTimeout time.Duration
+
+	// SessionKeepAliveInterval is how often idle vSphere sessions are kept
+	// alive.
+	//
+	// Defaults to the eponymous constant in the session package.
+	SessionKeepAliveInterval time.Duration
+
+	// VSphereQPS is the sustained number of requests per second sent to each
+	// vSphere server. A negative value disables rate limiting.
+	//
+	// Defaults to the eponymous constant in the session package.
+	VSphereQPS float32
+
+	// VSphereBurst is the number of requests that may be sent to each vSphere
+	// server in excess of the VSphereQPS.
+	//
+	// Defaults to the eponymous constant in the session package.
+	VSphereBurst int
+
+	// MaxConcurrentClones is the maximum number of in-flight clone operations
+	// on each vSphere server. A negative value removes the limit.
+	//
+	// Defaults to the eponymous constant in the session package.
+	MaxConcurrentClones int
+
+	// VMShutdownTimeout is how long the guest of a VM is given to shut down
+	// before the VM is powered off and destroyed, unless the VM sets its own
+	// timeout. A negative value powers VMs off without shutting down their
+	// guests.
+	//
+	// Defaults to the eponymous constant in this package.
+	VMShutdownTimeout time.Duration
+
+	// SessionProvider is used to get or create vSphere sessions. If nil, a
+	// session.Manager configured with the above options is used.
+	SessionProvider session.Provider
+
 	Logger     logr.Logger
 	KubeConfig *rest.Config
 	Scheme     *runtime.Scheme
@@ -113,6 +169,10 @@ type Options struct {
 	// the manager's Options in order to explicitly decide what controllers
 	// and webhooks to add to the manager.
 	AddToManager AddToManagerFunc
+
+	// watchCredentialsFile is true when the credentials were read from the
+	// credentials file and should be reloaded when it changes.
+	watchCredentialsFile bool
 }
 
 func (o *Options) defaults() {
@@ -140,6 +200,15 @@ func (o *Options) defaults() {
 		credentials := o.getCredentials()
 		o.Username = credentials["username"]
 		o.Password = credentials["password"]
+		o.watchCredentialsFile = o.CredentialsFile != ""
+	}
+
+	if o.CredentialsReloadInterval == 0 {
+		o.CredentialsReloadInterval = DefaultCredentialsReloadInterval
+	}
+
+	if o.VMShutdownTimeout == 0 {
+		o.VMShutdownTimeout = DefaultVMShutdownTimeout
 	}
 
 	if ns, ok := os.LookupEnv("POD_NAMESPACE"); ok {
@@ -154,17 +223,24 @@ func (o *Options) defaults() {
 }
 
 func (o *Options) getCredentials() map[string]string {
-	file, err := ioutil.ReadFile(o.CredentialsFile)
+	credentials, err := readCredentialsFile(o.CredentialsFile)
 	if err != nil {
-		o.Logger.Error(err, "error opening credentials file")
+		o.Logger.Error(err, "error reading credentials file")
 		return map[string]string{}
 	}
+	return credentials
+}
+
+func readCredentialsFile(path string) (map[string]string, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening credentials file")
+	}
 
 	credentials := map[string]string{}
 	if err := yaml.Unmarshal(file, &credentials); err != nil {
-		o.Logger.Error(err, "error unmarshaling credentials to yaml")
-		return map[string]string{}
+		return nil, errors.Wrap(err, "error unmarshaling credentials to yaml")
 	}
 
-	return credentials
+	return credentials, nil
 }
@@ -0,0 +1,99 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// EnsureAntiAffinityRule ensures the compute cluster has a VM-VM
+// anti-affinity rule with the provided name whose members are exactly the
+// provided VMs, so DRS places each of them on a different host. Since a rule
+// needs at least two VMs, the rule is removed if there are fewer.
+func EnsureAntiAffinityRule(
+	ctx context.Context,
+	computeCluster *object.ClusterComputeResource,
+	name string,
+	vms []types.ManagedObjectReference) error {
+
+	config, err := computeCluster.Configuration(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get configuration of compute cluster %q", computeCluster.Reference().Value)
+	}
+
+	var existing *types.ClusterAntiAffinityRuleSpec
+	if rule := findRule(config.Rule, name); rule != nil {
+		var ok bool
+		if existing, ok = rule.(*types.ClusterAntiAffinityRuleSpec); !ok {
+			return errors.Errorf("rule %q of compute cluster %q is not a VM-VM anti-affinity rule", name, computeCluster.Reference().Value)
+		}
+	}
+
+	var ruleSpec types.ClusterRuleSpec
+	switch {
+	case len(vms) < 2 && existing == nil:
+		return nil
+	case len(vms) < 2:
+		ruleSpec = types.ClusterRuleSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{
+				Operation: types.ArrayUpdateOperationRemove,
+				RemoveKey: existing.Key,
+			},
+		}
+	case existing == nil:
+		ruleSpec = types.ClusterRuleSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterAntiAffinityRuleSpec{
+				ClusterRuleInfo: types.ClusterRuleInfo{
+					Name:    name,
+					Enabled: types.NewBool(true),
+				},
+				Vm: vms,
+			},
+		}
+	case !sameRefs(existing.Vm, vms) || existing.Enabled == nil || !*existing.Enabled:
+		rule := *existing
+		rule.Enabled = types.NewBool(true)
+		rule.Vm = vms
+		ruleSpec = types.ClusterRuleSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationEdit},
+			Info:            &rule,
+		}
+	default:
+		return nil
+	}
+
+	return reconfigure(ctx, computeCluster, &types.ClusterConfigSpecEx{
+		RulesSpec: []types.ClusterRuleSpec{ruleSpec},
+	})
+}
+
+func sameRefs(a, b []types.ManagedObjectReference) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ref := range a {
+		if !containsRef(b, ref) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestEnsureAntiAffinityRule(t *testing.T) {
+	model := simulator.VPX()
+	model.ClusterHost = 3
+	model.Machine = 3
+
+	model.Run(func(ctx context.Context, c *vim25.Client) error {
+		g := NewWithT(t)
+		finder := find.NewFinder(c)
+
+		datacenter, err := finder.DefaultDatacenter(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		finder.SetDatacenter(datacenter)
+
+		computeCluster, err := finder.ClusterComputeResource(ctx, "DC0_C0")
+		g.Expect(err).NotTo(HaveOccurred())
+		vms, err := finder.VirtualMachineList(ctx, "DC0_C0_RP0_VM*")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vms).To(HaveLen(3))
+		var refs []types.ManagedObjectReference
+		for _, vm := range vms {
+			computeClusterOfVM, err := GetComputeCluster(ctx, vm)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(computeClusterOfVM.Reference()).To(Equal(computeCluster.Reference()))
+			refs = append(refs, vm.Reference())
+		}
+
+		getRule := func() *types.ClusterAntiAffinityRuleSpec {
+			config, err := computeCluster.Configuration(ctx)
+			g.Expect(err).NotTo(HaveOccurred())
+			rule, _ := findRule(config.Rule, "control-plane").(*types.ClusterAntiAffinityRuleSpec)
+			return rule
+		}
+
+		// No rule is created for a single VM.
+		g.Expect(EnsureAntiAffinityRule(ctx, computeCluster, "control-plane", refs[:1])).To(Succeed())
+		g.Expect(getRule()).To(BeNil())
+
+		// VMs are added to and removed from the rule.
+		g.Expect(EnsureAntiAffinityRule(ctx, computeCluster, "control-plane", refs[:2])).To(Succeed())
+		g.Expect(getRule().Vm).To(ConsistOf(refs[0], refs[1]))
+		g.Expect(*getRule().Enabled).To(BeTrue())
+
+		g.Expect(EnsureAntiAffinityRule(ctx, computeCluster, "control-plane", refs)).To(Succeed())
+		g.Expect(getRule().Vm).To(ConsistOf(refs[0], refs[1], refs[2]))
+
+		g.Expect(EnsureAntiAffinityRule(ctx, computeCluster, "control-plane", refs[1:])).To(Succeed())
+		g.Expect(getRule().Vm).To(ConsistOf(refs[1], refs[2]))
+
+		// The rule is removed once fewer than two VMs remain.
+		g.Expect(EnsureAntiAffinityRule(ctx, computeCluster, "control-plane", refs[2:])).To(Succeed())
+		g.Expect(getRule()).To(BeNil())
+		g.Expect(EnsureAntiAffinityRule(ctx, computeCluster, "control-plane", nil)).To(Succeed())
+		return nil
+	})
+}
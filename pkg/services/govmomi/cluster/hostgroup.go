@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cluster manages the DRS configuration of vSphere compute clusters.
+package cluster
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// VMGroupName returns the name of the VM group whose VMs are placed on the
+// hosts of the host group.
+func VMGroupName(hostGroup string) string {
+	return hostGroup + "-vms"
+}
+
+// VMHostRuleName returns the name of the VM/Host affinity rule that places
+// the VMs of the host group's VM group on the hosts of the host group.
+func VMHostRuleName(hostGroup string) string {
+	return hostGroup + "-vms-affinity"
+}
+
+// EnsureHostGroupAffinity ensures the VM is a member of the VM group of the
+// host group, creating the VM group and its VM/Host affinity rule if they do
+// not exist. The host group must exist in the VM's compute cluster.
+//
+// The rule is not mandatory, so vSphere HA may still restart the VM on other
+// hosts of the compute cluster if the hosts of the host group fail.
+func EnsureHostGroupAffinity(ctx context.Context, vm *object.VirtualMachine, hostGroup string) error {
+	computeCluster, err := GetComputeCluster(ctx, vm)
+	if err != nil {
+		return err
+	}
+	if computeCluster == nil {
+		return errors.Errorf("vm %q is not in a compute cluster", vm.Reference().Value)
+	}
+	config, err := computeCluster.Configuration(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get configuration of compute cluster %q", computeCluster.Reference().Value)
+	}
+
+	vmRef := vm.Reference()
+	vmGroupName := VMGroupName(hostGroup)
+	hasHostGroup := false
+	var vmGroup *types.ClusterVmGroup
+	for _, group := range config.Group {
+		switch group := group.(type) {
+		case *types.ClusterHostGroup:
+			if group.Name == hostGroup {
+				hasHostGroup = true
+			}
+		case *types.ClusterVmGroup:
+			if group.Name == vmGroupName {
+				vmGroup = group
+			}
+		}
+	}
+	if !hasHostGroup {
+		return errors.Errorf("host group %q does not exist in compute cluster %q", hostGroup, computeCluster.Reference().Value)
+	}
+
+	spec := &types.ClusterConfigSpecEx{}
+	switch {
+	case vmGroup == nil:
+		spec.GroupSpec = append(spec.GroupSpec, types.ClusterGroupSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterVmGroup{
+				ClusterGroupInfo: types.ClusterGroupInfo{Name: vmGroupName},
+				Vm:               []types.ManagedObjectReference{vmRef},
+			},
+		})
+	case !containsRef(vmGroup.Vm, vmRef):
+		group := *vmGroup
+		group.Vm = append(append([]types.ManagedObjectReference{}, vmGroup.Vm...), vmRef)
+		spec.GroupSpec = append(spec.GroupSpec, types.ClusterGroupSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationEdit},
+			Info:            &group,
+		})
+	}
+
+	ruleName := VMHostRuleName(hostGroup)
+	if findRule(config.Rule, ruleName) == nil {
+		spec.RulesSpec = append(spec.RulesSpec, types.ClusterRuleSpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+			Info: &types.ClusterVmHostRuleInfo{
+				ClusterRuleInfo: types.ClusterRuleInfo{
+					Name:      ruleName,
+					Enabled:   types.NewBool(true),
+					Mandatory: types.NewBool(false),
+				},
+				VmGroupName:         vmGroupName,
+				AffineHostGroupName: hostGroup,
+			},
+		})
+	}
+
+	if len(spec.GroupSpec) == 0 && len(spec.RulesSpec) == 0 {
+		return nil
+	}
+	return reconfigure(ctx, computeCluster, spec)
+}
+
+// GetComputeCluster returns the compute cluster that owns the VM's resource
+// pool. Nil is returned if the VM runs on a standalone host.
+func GetComputeCluster(ctx context.Context, vm *object.VirtualMachine) (*object.ClusterComputeResource, error) {
+	pool, err := vm.ResourcePool(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get resource pool of vm %q", vm.Reference().Value)
+	}
+	owner, err := pool.Owner(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get owner of resource pool %q", pool.Reference().Value)
+	}
+	computeCluster, _ := owner.(*object.ClusterComputeResource)
+	return computeCluster, nil
+}
+
+func reconfigure(ctx context.Context, computeCluster *object.ClusterComputeResource, spec *types.ClusterConfigSpecEx) error {
+	task, err := computeCluster.Reconfigure(ctx, spec, true)
+	if err != nil {
+		return errors.Wrapf(err, "unable to reconfigure compute cluster %q", computeCluster.Reference().Value)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "unable to reconfigure compute cluster %q", computeCluster.Reference().Value)
+	}
+	return nil
+}
+
+func findRule(rules []types.BaseClusterRuleInfo, name string) types.BaseClusterRuleInfo {
+	for _, rule := range rules {
+		if rule.GetClusterRuleInfo().Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+func containsRef(refs []types.ManagedObjectReference, ref types.ManagedObjectReference) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
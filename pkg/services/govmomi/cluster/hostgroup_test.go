@@ -0,0 +1,91 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cluster
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestEnsureHostGroupAffinity(t *testing.T) {
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		g := NewWithT(t)
+		finder := find.NewFinder(c)
+
+		datacenter, err := finder.DefaultDatacenter(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		finder.SetDatacenter(datacenter)
+
+		computeCluster, err := finder.ClusterComputeResource(ctx, "DC0_C0")
+		g.Expect(err).NotTo(HaveOccurred())
+		hosts, err := finder.HostSystemList(ctx, "DC0_C0/*")
+		g.Expect(err).NotTo(HaveOccurred())
+		vms, err := finder.VirtualMachineList(ctx, "DC0_C0_RP0_VM*")
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(vms).To(HaveLen(2))
+
+		// The host group must exist.
+		err = EnsureHostGroupAffinity(ctx, vms[0], "hosts")
+		g.Expect(err).To(MatchError(ContainSubstring(`host group "hosts" does not exist`)))
+
+		g.Expect(reconfigure(ctx, computeCluster, &types.ClusterConfigSpecEx{
+			GroupSpec: []types.ClusterGroupSpec{{
+				ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationAdd},
+				Info: &types.ClusterHostGroup{
+					ClusterGroupInfo: types.ClusterGroupInfo{Name: "hosts"},
+					Host:             []types.ManagedObjectReference{hosts[0].Reference()},
+				},
+			}},
+		})).To(Succeed())
+
+		// The VMs are added to the VM group, which is created along with the
+		// rule, and the operation is idempotent.
+		for _, vm := range append(vms, vms...) {
+			g.Expect(EnsureHostGroupAffinity(ctx, vm, "hosts")).To(Succeed())
+		}
+
+		config, err := computeCluster.Configuration(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		var vmGroup *types.ClusterVmGroup
+		for _, group := range config.Group {
+			if group, ok := group.(*types.ClusterVmGroup); ok && group.Name == VMGroupName("hosts") {
+				vmGroup = group
+			}
+		}
+		g.Expect(vmGroup).NotTo(BeNil())
+		g.Expect(vmGroup.Vm).To(ConsistOf(vms[0].Reference(), vms[1].Reference()))
+
+		rule, ok := findRule(config.Rule, VMHostRuleName("hosts")).(*types.ClusterVmHostRuleInfo)
+		g.Expect(ok).To(BeTrue())
+		g.Expect(rule.VmGroupName).To(Equal(VMGroupName("hosts")))
+		g.Expect(rule.AffineHostGroupName).To(Equal("hosts"))
+		g.Expect(*rule.Mandatory).To(BeFalse())
+
+		// VMs outside of a compute cluster are rejected.
+		standalone, err := finder.VirtualMachine(ctx, "DC0_H0_VM0")
+		g.Expect(err).NotTo(HaveOccurred())
+		err = EnsureHostGroupAffinity(ctx, standalone, "hosts")
+		g.Expect(err).To(MatchError(ContainSubstring("is not in a compute cluster")))
+	})
+}
@@ -26,4 +26,10 @@ const (
 	guestInfoKeyMetadataEnc = "guestinfo.metadata.encoding"
 	guestInfoKeyUserdata    = "guestinfo.userdata"
 	guestInfoKeyUserdataEnc = "guestinfo.userdata.encoding"
+	guestInfoKeyIgnition    = "guestinfo.ignition.config.data"
+	guestInfoKeyIgnitionEnc = "guestinfo.ignition.config.data.encoding"
+
+	// guestInfoKeyDiskExpanded names the disks that were last grown and
+	// their new sizes, so that the guest can grow their partitions.
+	guestInfoKeyDiskExpanded = "guestinfo.disk.expanded"
 )
@@ -21,9 +21,11 @@ import (
 	"testing"
 
 	"github.com/vmware/govmomi/object"
+	_ "github.com/vmware/govmomi/pbm/simulator"
 	"github.com/vmware/govmomi/simulator"
 	"github.com/vmware/govmomi/vim25/types"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
@@ -38,6 +40,7 @@ func TestCreate(t *testing.T) {
 		t.Fatal(err)
 	}
 	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
 
 	s := model.Service.NewServer()
 	defer s.Close()
@@ -46,10 +49,13 @@ func TestCreate(t *testing.T) {
 	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
 	vmContext.VSphereVM.Spec.Server = s.URL.Host
 
-	authSession, err := session.GetOrCreate(
+	authSession, err := vmContext.SessionProvider.GetOrCreate(
 		vmContext,
-		vmContext.VSphereVM.Spec.Server, "",
-		s.URL.User.Username(), pass, "")
+		session.Params{
+			Server:   vmContext.VSphereVM.Spec.Server,
+			Username: s.URL.User.Username(),
+			Password: pass,
+		})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -57,15 +63,35 @@ func TestCreate(t *testing.T) {
 
 	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
 	vmContext.VSphereVM.Spec.Template = vm.Name
+	vmContext.VSphereVM.Spec.AdditionalDisks = []infrav1.AdditionalDiskSpec{{SizeGiB: 10}}
+	vmContext.VSphereVM.Spec.StoragePolicyName = "vSAN Default Storage Policy"
+	vmContext.VSphereVM.Spec.ProvisioningType = infrav1.ThickProvisioning
 
 	disk := object.VirtualDeviceList(vm.Config.Hardware.Device).SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
 	disk.CapacityInKB = int64(vmContext.VSphereVM.Spec.DiskGiB) * 1024 * 1024
 
-	if err := createVM(vmContext, []byte("")); err != nil {
+	if err := createVM(vmContext, []byte(""), ""); err != nil {
 		t.Fatal(err)
 	}
 
 	if model.Machine+1 != model.Count().Machine {
 		t.Error("failed to clone vm")
 	}
+	if vmContext.VSphereVM.Status.Datastore == "" {
+		t.Error("expected the datastore of the clone to be recorded in status")
+	}
+
+	task := object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: vmContext.VSphereVM.Status.TaskRef})
+	info, err := task.WaitForResult(vmContext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := object.NewVirtualMachine(authSession.Client.Client, info.Result.(types.ManagedObjectReference))
+	devices, err := clone.Device(vmContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disks := devices.SelectByType((*types.VirtualDisk)(nil)); len(disks) != 2 {
+		t.Errorf("expected the clone to have 2 disks, got %d", len(disks))
+	}
 }
@@ -17,12 +17,226 @@ limitations under the License.
 package esxi
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
 
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
 )
 
-// Clone kicks off a clone operation on ESXi to create a new virtual machine.
-func Clone(ctx *context.VMContext, bootstrapData []byte) error {
-	return errors.New("temporarily disabled esxi support")
+// Clone creates a new virtual machine on a standalone ESXi host, which cannot
+// clone virtual machines. Instead, the disks of the template are copied into
+// the new virtual machine's directory on the datastore, and the virtual
+// machine is created with the copied disks, the controllers of the disks and
+// the same customisation that is applied to a clone on vCenter.
+//
+// The disks are copied before Clone returns, so only the creation of the
+// virtual machine is tracked by the VSphereVM's TaskRef. The disk of the
+// virtual machine is grown to the VSphereVM's DiskGiB once it is created.
+func Clone(ctx *context.VMContext, bootstrapData []byte, format infrav1.BootstrapFormat) error {
+	ctx = &context.VMContext{
+		ControllerContext: ctx.ControllerContext,
+		VSphereVM:         ctx.VSphereVM,
+		Session:           ctx.Session,
+		Logger:            ctx.Logger.WithName("esxi"),
+		PatchHelper:       ctx.PatchHelper,
+	}
+	ctx.Logger.Info("starting clone process")
+
+	if err := validateSpec(ctx.VSphereVM.Spec.VirtualMachineCloneSpec); err != nil {
+		return err
+	}
+
+	extraConfig, err := vcenter.BootstrapExtraConfig(ctx, bootstrapData, format)
+	if err != nil {
+		return err
+	}
+
+	tpl, err := template.FindTemplate(ctx, ctx.VSphereVM.Spec.Template)
+	if err != nil {
+		return err
+	}
+	var tplObj mo.VirtualMachine
+	if err := tpl.Properties(ctx, tpl.Reference(), []string{"config"}, &tplObj); err != nil {
+		return errors.Wrapf(err, "error getting config of template %s", ctx.VSphereVM.Spec.Template)
+	}
+	if tplObj.Config == nil {
+		return errors.Errorf("template %s has no config", ctx.VSphereVM.Spec.Template)
+	}
+
+	datacenter, err := ctx.Session.Finder.DatacenterOrDefault(ctx, ctx.VSphereVM.Spec.Datacenter)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datacenter for %q", ctx)
+	}
+	folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get folder for %q", ctx)
+	}
+	pool, err := ctx.Session.Finder.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get resource pool for %q", ctx)
+	}
+	datastore, err := ctx.Session.Finder.DatastoreOrDefault(ctx, ctx.VSphereVM.Spec.Datastore)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datastore for %q", ctx)
+	}
+
+	vmDir := datastore.Path(ctx.VSphereVM.Name)
+	devices, err := copyDisks(ctx, datacenter, datastore, vmDir, tplObj.Config.Hardware.Device)
+	if err != nil {
+		return err
+	}
+
+	configSpec, err := vcenter.ConfigSpec(ctx, devices, extraConfig, datastore)
+	if err != nil {
+		return err
+	}
+	configSpec.Name = ctx.VSphereVM.Name
+	configSpec.GuestId = tplObj.Config.GuestId
+	configSpec.Files = &types.VirtualMachineFileInfo{
+		VmPathName: datastore.Path(fmt.Sprintf("%s/%s.vmx", ctx.VSphereVM.Name, ctx.VSphereVM.Name)),
+	}
+	if configSpec.Firmware == "" {
+		configSpec.Firmware = tplObj.Config.Firmware
+	}
+	deviceSpecs := make([]types.BaseVirtualDeviceConfigSpec, 0, len(devices)+len(configSpec.DeviceChange))
+	for _, device := range devices {
+		deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+			Device:    device,
+			Operation: types.VirtualDeviceConfigSpecOperationAdd,
+		})
+	}
+	configSpec.DeviceChange = append(deviceSpecs, configSpec.DeviceChange...)
+
+	ctx.VSphereVM.Status.CloneMode = infrav1.FullClone
+	ctx.VSphereVM.Status.Datastore = datastore.Name()
+
+	ctx.Logger.Info("creating machine", "namespace", ctx.VSphereVM.Namespace, "name", ctx.VSphereVM.Name, "datastore", datastore.Name())
+	task, err := folder.CreateVM(ctx, *configSpec, pool, nil)
+	if err != nil {
+		return errors.Wrapf(err, "error triggering create op for machine %s", ctx)
+	}
+
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+
+	// patch the vsphereVM early to ensure that the task is
+	// reflected in the status right away, this avoid situations
+	// of concurrent clones
+	if err := ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+	}
+	return nil
+}
+
+// validateSpec returns an error if the clone spec uses a feature that
+// requires vCenter.
+func validateSpec(spec infrav1.VirtualMachineCloneSpec) error {
+	var feature string
+	switch {
+	case spec.ContentLibrary != "":
+		feature = "content libraries"
+	case spec.CloneMode == infrav1.InstantClone:
+		feature = "the instantClone clone mode"
+	case spec.BootstrapTransport != "" && spec.BootstrapTransport != infrav1.GuestInfoBootstrapTransport:
+		feature = fmt.Sprintf("the %s bootstrap transport", spec.BootstrapTransport)
+	case spec.StoragePolicyName != "":
+		feature = "storage policies"
+	case spec.VTPM:
+		feature = "virtual TPMs"
+	default:
+		return nil
+	}
+	return errors.Errorf("standalone ESXi hosts do not support %s", feature)
+}
+
+// copyDisks copies the disks of the template into the VM's directory on the
+// datastore, and returns the devices that add the copied disks and their
+// controllers to the VM.
+func copyDisks(
+	ctx *context.VMContext,
+	datacenter *object.Datacenter,
+	datastore *object.Datastore,
+	vmDir string,
+	tplDevices object.VirtualDeviceList) (object.VirtualDeviceList, error) {
+
+	disks := tplDevices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, errors.Errorf("template %s has no disks", ctx.VSphereVM.Spec.Template)
+	}
+
+	fileManager := object.NewFileManager(ctx.Session.Client.Client)
+	if err := fileManager.MakeDirectory(ctx, vmDir, datacenter, true); err != nil {
+		// The directory is left behind by a previous attempt to create the
+		// VM, whose disks are copied again.
+		if !isFileAlreadyExists(err) {
+			return nil, errors.Wrapf(err, "unable to create directory %s for %q", vmDir, ctx)
+		}
+	}
+
+	diskManager := object.NewVirtualDiskManager(ctx.Session.Client.Client)
+	var devices object.VirtualDeviceList
+	controllerKeys := map[int32]int32{}
+	for i, device := range disks {
+		disk := device.(*types.VirtualDisk)
+		backing, ok := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+		if !ok {
+			return nil, errors.Errorf("disk %d of template %s has an unsupported backing %T", i, ctx.VSphereVM.Spec.Template, disk.Backing)
+		}
+
+		// Add the controller of the disk, with a new key, before the disk.
+		controllerKey, ok := controllerKeys[disk.ControllerKey]
+		if !ok {
+			controller, ok := tplDevices.FindByKey(disk.ControllerKey).(types.BaseVirtualController)
+			if !ok {
+				return nil, errors.Errorf("unable to find controller of disk %d of template %s", i, ctx.VSphereVM.Spec.Template)
+			}
+			controllerKey = devices.NewKey()
+			controller.GetVirtualController().Key = controllerKey
+			controller.GetVirtualController().Device = nil
+			controllerKeys[disk.ControllerKey] = controllerKey
+			devices = append(devices, controller.(types.BaseVirtualDevice))
+		}
+
+		fileName := fmt.Sprintf("%s/%s.vmdk", vmDir, ctx.VSphereVM.Name)
+		if i > 0 {
+			fileName = fmt.Sprintf("%s/%s_%d.vmdk", vmDir, ctx.VSphereVM.Name, i)
+		}
+		ctx.Logger.Info("copying template disk", "source", backing.FileName, "destination", fileName)
+		task, err := diskManager.CopyVirtualDisk(ctx, backing.FileName, datacenter, fileName, datacenter, nil, true)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error triggering copy op for disk %s", backing.FileName)
+		}
+		if err := task.Wait(ctx); err != nil {
+			return nil, errors.Wrapf(err, "unable to copy disk %s to %s", backing.FileName, fileName)
+		}
+
+		disk.Key = devices.NewKey()
+		disk.ControllerKey = controllerKey
+		disk.Backing = &types.VirtualDiskFlatVer2BackingInfo{
+			VirtualDeviceFileBackingInfo: types.VirtualDeviceFileBackingInfo{
+				FileName:  fileName,
+				Datastore: types.NewReference(datastore.Reference()),
+			},
+			DiskMode:        backing.DiskMode,
+			ThinProvisioned: backing.ThinProvisioned,
+			EagerlyScrub:    backing.EagerlyScrub,
+		}
+		devices = append(devices, disk)
+	}
+	return devices, nil
+}
+
+func isFileAlreadyExists(err error) bool {
+	if soap.IsSoapFault(err) {
+		_, ok := soap.ToSoapFault(err).VimFault().(types.FileAlreadyExists)
+		return ok
+	}
+	return false
 }
@@ -0,0 +1,126 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package esxi
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestClone(t *testing.T) {
+	model := simulator.ESX()
+	defer model.Remove()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	defer s.Close()
+	pass, _ := s.URL.User.Password()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Server = s.URL.Host
+	// The datacenter of a standalone ESXi host is found by default.
+	vmContext.VSphereVM.Spec.Datacenter = ""
+	authSession, err := vmContext.SessionProvider.GetOrCreate(
+		vmContext,
+		session.Params{
+			Server:   vmContext.VSphereVM.Spec.Server,
+			Username: s.URL.User.Username(),
+			Password: pass,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authSession.IsVC() {
+		t.Fatal("expected a session with a standalone ESXi host")
+	}
+	vmContext.Session = authSession
+
+	tpl := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	vmContext.VSphereVM.Spec.Template = tpl.Name
+	vmContext.VSphereVM.Spec.NumCPUs = 4
+	vmContext.VSphereVM.Spec.MemoryMiB = 4096
+	vmContext.VSphereVM.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{{NetworkName: "VM Network"}}
+
+	if err := Clone(vmContext, []byte("#cloud-config"), infrav1.CloudConfigBootstrapFormat); err != nil {
+		t.Fatal(err)
+	}
+	if vmContext.VSphereVM.Status.CloneMode != infrav1.FullClone {
+		t.Errorf("expected the clone mode to be %q, got %q", infrav1.FullClone, vmContext.VSphereVM.Status.CloneMode)
+	}
+
+	task := object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: vmContext.VSphereVM.Status.TaskRef})
+	info, err := task.WaitForResult(vmContext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := object.NewVirtualMachine(authSession.Client.Client, info.Result.(types.ManagedObjectReference))
+	var obj mo.VirtualMachine
+	if err := vm.Properties(vmContext, vm.Reference(), []string{"config"}, &obj); err != nil {
+		t.Fatal(err)
+	}
+
+	if obj.Config.Hardware.NumCPU != 4 || obj.Config.Hardware.MemoryMB != 4096 {
+		t.Errorf("expected 4 CPUs and 4096 MiB of memory, got %d CPUs and %d MiB", obj.Config.Hardware.NumCPU, obj.Config.Hardware.MemoryMB)
+	}
+	if obj.Config.InstanceUuid != string(vmContext.VSphereVM.UID) {
+		t.Errorf("expected the instance UUID to be %q, got %q", vmContext.VSphereVM.UID, obj.Config.InstanceUuid)
+	}
+
+	devices := object.VirtualDeviceList(obj.Config.Hardware.Device)
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) != 1 {
+		t.Fatalf("expected 1 disk, got %d", len(disks))
+	}
+	fileName := disks[0].(*types.VirtualDisk).Backing.(*types.VirtualDiskFlatVer2BackingInfo).FileName
+	if expected := "[LocalDS_0] " + vmContext.VSphereVM.Name + "/" + vmContext.VSphereVM.Name + ".vmdk"; fileName != expected {
+		t.Errorf("expected the disk to be backed by %q, got %q", expected, fileName)
+	}
+	if nics := devices.SelectByType((*types.VirtualEthernetCard)(nil)); len(nics) != 1 {
+		t.Errorf("expected 1 NIC, got %d", len(nics))
+	}
+
+	var userData string
+	for _, option := range obj.Config.ExtraConfig {
+		if value := option.GetOptionValue(); value.Key == "guestinfo.userdata" {
+			userData = value.Value.(string)
+		}
+	}
+	if userData == "" {
+		t.Error("expected the user data to be set in the extra config")
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	if err := validateSpec(infrav1.VirtualMachineCloneSpec{Template: "ubuntu"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	err := validateSpec(infrav1.VirtualMachineCloneSpec{BootstrapTransport: infrav1.NoCloudBootstrapTransport})
+	if err == nil || err.Error() != "standalone ESXi hosts do not support the noCloud bootstrap transport" {
+		t.Errorf("expected an error for the noCloud bootstrap transport, got %v", err)
+	}
+}
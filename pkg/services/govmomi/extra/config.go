@@ -70,6 +70,22 @@ func (e *Config) SetCloudInitMetadata(data []byte) error {
 	return nil
 }
 
+// SetIgnitionUserData sets the Ignition config at the key
+// "guestinfo.ignition.config.data" as a base64-encoded string.
+func (e *Config) SetIgnitionUserData(data []byte) error {
+	*e = append(*e,
+		&types.OptionValue{
+			Key:   "guestinfo.ignition.config.data",
+			Value: e.encode(data),
+		},
+		&types.OptionValue{
+			Key:   "guestinfo.ignition.config.data.encoding",
+			Value: "base64",
+		},
+	)
+	return nil
+}
+
 // encode first attempts to decode the data as many times as necessary
 // to ensure it is plain-text before returning the result as a base64
 // encoded string
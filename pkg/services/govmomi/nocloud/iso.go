@@ -0,0 +1,259 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nocloud
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	sectorSize = 2048
+
+	// The layout of the image is fixed, since it only has a root directory:
+	// the system area is followed by the primary volume descriptor, the
+	// volume descriptor set terminator, the little and big endian path
+	// tables, the root directory and finally the contents of the files.
+	primaryVolumeDescriptorSector = 16
+	terminatorSector              = 17
+	lPathTableSector              = 18
+	mPathTableSector              = 19
+	rootDirectorySector           = 20
+	firstFileSector               = 21
+
+	pathTableSize = 10
+
+	dirFlag = 0x02
+
+	// The POSIX file modes recorded by the Rock Ridge extensions.
+	dirMode  = 0040555
+	fileMode = 0100444
+
+	rockRidgeID         = "RRIP_1991A"
+	rockRidgeDescriptor = "THE ROCK RIDGE INTERCHANGE PROTOCOL PROVIDES SUPPORT FOR POSIX FILE SYSTEM SEMANTICS"
+	rockRidgeSource     = "PLEASE CONTACT DISC PUBLISHER FOR SPECIFICATION SOURCE."
+)
+
+// file is a file in the root directory of an ISO 9660 image.
+type file struct {
+	name string
+	data []byte
+}
+
+// newISO returns an ISO 9660 image with the volume identifier that contains
+// the files in its root directory.
+//
+// The names of the files are recorded with the Rock Ridge extensions, since
+// the names of ISO 9660 files may only contain upper case letters, digits and
+// underscores. Readers that do not support Rock Ridge see the names with
+// other characters replaced by underscores.
+func newISO(volumeID string, files []file, now time.Time) ([]byte, error) {
+	type entry struct {
+		file
+		id     string
+		extent uint32
+	}
+
+	entries := make([]entry, len(files))
+	for i, f := range files {
+		entries[i] = entry{file: f, id: fileIdentifier(f.name)}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].id < entries[j].id })
+
+	sectors := uint32(firstFileSector)
+	for i := range entries {
+		entries[i].extent = sectors
+		sectors += (uint32(len(entries[i].data)) + sectorSize - 1) / sectorSize
+	}
+
+	// The root directory is recorded in a single sector.
+	rootDir := make([]byte, 0, sectorSize)
+	rootDir = append(rootDir, dirRecord([]byte{0}, rootDirectorySector, sectorSize, dirFlag, now,
+		concat(susp("SP", []byte{0xBE, 0xEF, 0}), posixAttributes(dirMode, 2), extensionsReference()))...)
+	rootDir = append(rootDir, dirRecord([]byte{1}, rootDirectorySector, sectorSize, dirFlag, now,
+		posixAttributes(dirMode, 2))...)
+	for _, e := range entries {
+		rootDir = append(rootDir, dirRecord([]byte(e.id), e.extent, uint32(len(e.data)), 0, now,
+			concat(posixAttributes(fileMode, 1), alternateName(e.name)))...)
+	}
+	if len(rootDir) > sectorSize {
+		return nil, errors.Errorf("too many files for an ISO image: %d", len(files))
+	}
+
+	image := make([]byte, int(sectors)*sectorSize)
+	pvd := image[primaryVolumeDescriptorSector*sectorSize:]
+	pvd[0] = 1
+	copy(pvd[1:6], "CD001")
+	pvd[6] = 1
+	copy(pvd[8:40], padString("", 32))
+	copy(pvd[40:72], padString(volumeID, 32))
+	putBothEndian32(pvd[80:], sectors)
+	putBothEndian16(pvd[120:], 1)
+	putBothEndian16(pvd[124:], 1)
+	putBothEndian16(pvd[128:], sectorSize)
+	putBothEndian32(pvd[132:], pathTableSize)
+	binary.LittleEndian.PutUint32(pvd[140:], lPathTableSector)
+	binary.BigEndian.PutUint32(pvd[148:], mPathTableSector)
+	copy(pvd[156:190], dirRecord([]byte{0}, rootDirectorySector, sectorSize, dirFlag, now, nil))
+	copy(pvd[190:813], padString("", 623))
+	copy(pvd[813:830], volumeTime(now))
+	copy(pvd[830:847], volumeTime(now))
+	copy(pvd[847:864], volumeTime(time.Time{}))
+	copy(pvd[864:881], volumeTime(time.Time{}))
+	pvd[881] = 1
+
+	terminator := image[terminatorSector*sectorSize:]
+	terminator[0] = 255
+	copy(terminator[1:6], "CD001")
+	terminator[6] = 1
+
+	// The path tables only record the root directory.
+	lPathTable := image[lPathTableSector*sectorSize:]
+	lPathTable[0] = 1
+	binary.LittleEndian.PutUint32(lPathTable[2:], rootDirectorySector)
+	binary.LittleEndian.PutUint16(lPathTable[6:], 1)
+	mPathTable := image[mPathTableSector*sectorSize:]
+	mPathTable[0] = 1
+	binary.BigEndian.PutUint32(mPathTable[2:], rootDirectorySector)
+	binary.BigEndian.PutUint16(mPathTable[6:], 1)
+
+	copy(image[rootDirectorySector*sectorSize:], rootDir)
+	for _, e := range entries {
+		copy(image[e.extent*sectorSize:], e.data)
+	}
+
+	return image, nil
+}
+
+// fileIdentifier returns the ISO 9660 identifier of the file, which is its
+// upper case name with any characters other than letters, digits and
+// underscores replaced by underscores.
+func fileIdentifier(name string) string {
+	base, ext := name, ""
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		base, ext = name[:i], name[i+1:]
+	}
+	mangle := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+				return r
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			default:
+				return '_'
+			}
+		}, s)
+	}
+	return mangle(base) + "." + mangle(ext) + ";1"
+}
+
+// dirRecord returns a directory record with the system use entries.
+func dirRecord(id []byte, extent, size uint32, flags byte, t time.Time, systemUse []byte) []byte {
+	offset := 33 + len(id)
+	if offset%2 == 1 {
+		offset++
+	}
+	length := offset + len(systemUse)
+	if length%2 == 1 {
+		length++
+	}
+
+	r := make([]byte, length)
+	r[0] = byte(length)
+	putBothEndian32(r[2:], extent)
+	putBothEndian32(r[10:], size)
+	t = t.UTC()
+	r[18] = byte(t.Year() - 1900)
+	r[19] = byte(t.Month())
+	r[20] = byte(t.Day())
+	r[21] = byte(t.Hour())
+	r[22] = byte(t.Minute())
+	r[23] = byte(t.Second())
+	r[25] = flags
+	putBothEndian16(r[28:], 1)
+	r[32] = byte(len(id))
+	copy(r[33:], id)
+	copy(r[offset:], systemUse)
+	return r
+}
+
+// susp returns a System Use Sharing Protocol entry.
+func susp(signature string, data []byte) []byte {
+	return concat([]byte{signature[0], signature[1], byte(4 + len(data)), 1}, data)
+}
+
+// extensionsReference returns the entry that identifies the Rock Ridge
+// extensions.
+func extensionsReference() []byte {
+	return susp("ER", concat(
+		[]byte{byte(len(rockRidgeID)), byte(len(rockRidgeDescriptor)), byte(len(rockRidgeSource)), 1},
+		[]byte(rockRidgeID), []byte(rockRidgeDescriptor), []byte(rockRidgeSource)))
+}
+
+// posixAttributes returns the Rock Ridge entry that records the POSIX file
+// mode and number of links of a file, which is owned by root.
+func posixAttributes(mode, links uint32) []byte {
+	data := make([]byte, 32)
+	putBothEndian32(data, mode)
+	putBothEndian32(data[8:], links)
+	return susp("PX", data)
+}
+
+// alternateName returns the Rock Ridge entry that records the name of a
+// file.
+func alternateName(name string) []byte {
+	return susp("NM", concat([]byte{0}, []byte(name)))
+}
+
+// volumeTime returns the time in the format of the volume descriptor, or
+// the format of an unspecified time if the time is zero.
+func volumeTime(t time.Time) []byte {
+	if t.IsZero() {
+		return append([]byte(strings.Repeat("0", 16)), 0)
+	}
+	t = t.UTC()
+	return append([]byte(fmt.Sprintf("%04d%02d%02d%02d%02d%02d%02d",
+		t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond()/10000000)), 0)
+}
+
+func padString(s string, n int) []byte {
+	return []byte(fmt.Sprintf("%-*s", n, s))
+}
+
+func putBothEndian16(b []byte, v uint16) {
+	binary.LittleEndian.PutUint16(b, v)
+	binary.BigEndian.PutUint16(b[2:], v)
+}
+
+func putBothEndian32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
+
+func concat(slices ...[]byte) []byte {
+	var b []byte
+	for _, s := range slices {
+		b = append(b, s...)
+	}
+	return b
+}
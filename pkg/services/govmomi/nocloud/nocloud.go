@@ -0,0 +1,185 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package nocloud provides VMs with their bootstrap data and metadata on
+// ISOs read by the NoCloud datasource of cloud-init, for guests without the
+// VMware guestinfo datasource.
+package nocloud
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/yaml"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const (
+	// VolumeID is the volume identifier by which cloud-init finds the ISO.
+	VolumeID = "cidata"
+
+	// Folder is the datastore folder to which the ISOs are uploaded.
+	Folder = "cidata"
+)
+
+// NewISO returns a NoCloud ISO with the user data and the metadata rendered
+// by util.GetMachineMetadata. The network configuration in the metadata is
+// also written to the ISO's network-config file, since that is the only
+// file from which the NoCloud datasource reads network configuration.
+func NewISO(userData, metadata []byte) ([]byte, error) {
+	var parsed struct {
+		Network interface{} `json:"network,omitempty"`
+	}
+	if err := yaml.Unmarshal(metadata, &parsed); err != nil {
+		return nil, errors.Wrap(err, "unable to parse metadata")
+	}
+	files := []file{
+		{name: "user-data", data: userData},
+		{name: "meta-data", data: metadata},
+	}
+	if parsed.Network != nil {
+		networkConfig, err := yaml.Marshal(parsed.Network)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to render network config")
+		}
+		files = append(files, file{name: "network-config", data: networkConfig})
+	}
+	return newISO(VolumeID, files, time.Now())
+}
+
+// Path returns the datastore path of the VSphereVM's ISO on the datastore.
+func Path(vm *infrav1.VSphereVM, datastore string) string {
+	return (&object.DatastorePath{
+		Datastore: datastore,
+		Path:      path.Join(Folder, fmt.Sprintf("%s-%s.iso", vm.Name, vm.UID)),
+	}).String()
+}
+
+// Upload uploads the ISO to the datastore path, replacing any existing file.
+func Upload(ctx context.Context, s *session.Session, isoPath string, iso []byte) error {
+	var p object.DatastorePath
+	if !p.FromString(isoPath) {
+		return errors.Errorf("invalid datastore path %q", isoPath)
+	}
+	datastore, err := s.Finder.Datastore(ctx, p.Datastore)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find datastore %q", p.Datastore)
+	}
+	datacenter, err := s.Finder.DefaultDatacenter(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to find datacenter")
+	}
+
+	dir := (&object.DatastorePath{Datastore: p.Datastore, Path: path.Dir(p.Path)}).String()
+	fileManager := object.NewFileManager(s.Client.Client)
+	if err := fileManager.MakeDirectory(ctx, dir, datacenter, true); err != nil {
+		if !soap.IsSoapFault(err) {
+			return errors.Wrapf(err, "unable to create directory %s", dir)
+		}
+		if _, ok := soap.ToSoapFault(err).VimFault().(types.FileAlreadyExists); !ok {
+			return errors.Wrapf(err, "unable to create directory %s", dir)
+		}
+	}
+
+	upload := soap.DefaultUpload
+	upload.ContentLength = int64(len(iso))
+	if err := datastore.Upload(ctx, bytes.NewReader(iso), p.Path, &upload); err != nil {
+		return errors.Wrapf(err, "unable to upload %s", isoPath)
+	}
+	return nil
+}
+
+// Delete deletes the ISO at the datastore path. It is not an error if the
+// ISO does not exist.
+func Delete(ctx context.Context, s *session.Session, isoPath string) error {
+	datacenter, err := s.Finder.DefaultDatacenter(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to find datacenter")
+	}
+	task, err := object.NewFileManager(s.Client.Client).DeleteDatastoreFile(ctx, isoPath, datacenter)
+	if err != nil {
+		return errors.Wrapf(err, "unable to delete %s", isoPath)
+	}
+	if err := task.Wait(ctx); err != nil {
+		if f, ok := err.(types.HasFault); ok {
+			if _, ok := f.Fault().(*types.FileNotFound); ok {
+				return nil
+			}
+		}
+		return errors.Wrapf(err, "unable to delete %s", isoPath)
+	}
+	return nil
+}
+
+// AttachSpec returns the spec that inserts the ISO at the datastore path
+// into the first CD-ROM of a VM with the provided devices, or that adds a
+// CD-ROM for the ISO if the VM has none.
+func AttachSpec(devices object.VirtualDeviceList, isoPath string) (types.BaseVirtualDeviceConfigSpec, error) {
+	operation := types.VirtualDeviceConfigSpecOperationEdit
+	cdrom, err := devices.FindCdrom("")
+	if err != nil {
+		controller, err := devices.FindIDEController("")
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to add a CD-ROM for the NoCloud ISO")
+		}
+		if cdrom, err = devices.CreateCdrom(controller); err != nil {
+			return nil, errors.Wrap(err, "unable to add a CD-ROM for the NoCloud ISO")
+		}
+		operation = types.VirtualDeviceConfigSpecOperationAdd
+	}
+
+	cdrom = devices.InsertIso(cdrom, isoPath)
+	cdrom.Connectable = &types.VirtualDeviceConnectInfo{
+		Connected:      true,
+		StartConnected: true,
+	}
+	return &types.VirtualDeviceConfigSpec{
+		Device:    cdrom,
+		Operation: operation,
+	}, nil
+}
+
+// DetachSpec returns the spec that ejects the ISO at the datastore path from
+// the CD-ROM of a VM with the provided devices, or nil if the ISO is not
+// inserted into any of its CD-ROMs.
+func DetachSpec(devices object.VirtualDeviceList, isoPath string) types.BaseVirtualDeviceConfigSpec {
+	for _, device := range devices.SelectByType((*types.VirtualCdrom)(nil)) {
+		cdrom := device.(*types.VirtualCdrom)
+		backing, ok := cdrom.Backing.(*types.VirtualCdromIsoBackingInfo)
+		if !ok || backing.FileName != isoPath {
+			continue
+		}
+
+		// The CD-ROM is left without media, since devices may not be
+		// removed from an IDE controller while the VM is powered on.
+		cdrom.Backing = &types.VirtualCdromRemotePassthroughBackingInfo{}
+		cdrom.Connectable = &types.VirtualDeviceConnectInfo{}
+		return &types.VirtualDeviceConfigSpec{
+			Device:    cdrom,
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package nocloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	apitypes "k8s.io/apimachinery/pkg/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const testMetadata = `instance-id: "vm"
+local-hostname: "vm"
+network:
+  version: 2
+  ethernets:
+    id0:
+      match:
+        macaddress: "00:50:56:00:00:01"
+      dhcp4: true
+`
+
+// readISO returns the volume identifier of the image and the contents of the
+// files in its root directory by their Rock Ridge names.
+func readISO(t *testing.T, iso []byte) (string, map[string]string) {
+	pvd := iso[primaryVolumeDescriptorSector*sectorSize:]
+	if string(pvd[1:6]) != "CD001" {
+		t.Fatalf("Expected a primary volume descriptor, got %q", pvd[1:6])
+	}
+	volumeID := strings.TrimRight(string(pvd[40:72]), " ")
+	rootExtent := binary.LittleEndian.Uint32(pvd[156+2:])
+	rootDir := iso[rootExtent*sectorSize : (rootExtent+1)*sectorSize]
+
+	files := map[string]string{}
+	for offset := 0; offset < len(rootDir) && rootDir[offset] > 0; offset += int(rootDir[offset]) {
+		record := rootDir[offset : offset+int(rootDir[offset])]
+		if record[25]&dirFlag != 0 {
+			continue
+		}
+		idLength := int(record[32])
+		systemUse := record[33+idLength+(idLength+1)%2:]
+		var name string
+		for len(systemUse) >= 4 && systemUse[2] >= 4 {
+			entry := systemUse[:systemUse[2]]
+			if string(entry[:2]) == "NM" {
+				name = string(entry[5:])
+			}
+			systemUse = systemUse[len(entry):]
+		}
+		if name == "" {
+			t.Fatalf("Expected a Rock Ridge name for %q", record[33:33+idLength])
+		}
+		extent := binary.LittleEndian.Uint32(record[2:])
+		size := binary.LittleEndian.Uint32(record[10:])
+		files[name] = string(iso[extent*sectorSize : extent*sectorSize+size])
+	}
+	return volumeID, files
+}
+
+func TestNewISO(t *testing.T) {
+	userData := "#cloud-config\n" + strings.Repeat("#\n", sectorSize)
+	iso, err := NewISO([]byte(userData), []byte(testMetadata))
+	if err != nil {
+		t.Fatalf("Failed to create ISO: %v", err)
+	}
+	if len(iso)%sectorSize != 0 {
+		t.Errorf("Expected the ISO to consist of sectors, got %d bytes", len(iso))
+	}
+
+	volumeID, files := readISO(t, iso)
+	if volumeID != VolumeID {
+		t.Errorf("Expected volume identifier %q, got %q", VolumeID, volumeID)
+	}
+	if len(files) != 3 {
+		t.Errorf("Expected 3 files, got %d", len(files))
+	}
+	if files["user-data"] != userData {
+		t.Errorf("Expected user data %q, got %q", userData, files["user-data"])
+	}
+	if files["meta-data"] != testMetadata {
+		t.Errorf("Expected metadata %q, got %q", testMetadata, files["meta-data"])
+	}
+	if networkConfig := files["network-config"]; !strings.HasPrefix(networkConfig, "ethernets:\n") ||
+		!strings.Contains(networkConfig, `macaddress: "00:50:56:00:00:01"`) ||
+		!strings.HasSuffix(networkConfig, "version: 2\n") {
+		t.Errorf("Expected the network config of the metadata, got %q", networkConfig)
+	}
+
+	// The network config is only written if the metadata has one.
+	iso, err = NewISO(nil, []byte(`instance-id: "vm"`))
+	if err != nil {
+		t.Fatalf("Failed to create ISO: %v", err)
+	}
+	if _, files := readISO(t, iso); len(files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(files))
+	}
+}
+
+func TestFileIdentifier(t *testing.T) {
+	for name, expected := range map[string]string{
+		"user-data":      "USER_DATA.;1",
+		"network-config": "NETWORK_CONFIG.;1",
+		"vendor.data":    "VENDOR.DATA;1",
+	} {
+		if id := fileIdentifier(name); id != expected {
+			t.Errorf("Expected identifier %q for %q, got %q", expected, name, id)
+		}
+	}
+}
+
+func TestISOLifecycle(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	server := model.Service.NewServer()
+	defer server.Close()
+	pass, _ := server.URL.User.Password()
+	s, err := session.NewManager(session.ManagerOptions{}).GetOrCreate(context.TODO(), session.Params{
+		Server:   server.URL.Host,
+		Username: server.URL.User.Username(),
+		Password: pass,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datastore, err := s.Finder.DefaultDatastore(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vsphereVM := &infrav1.VSphereVM{}
+	vsphereVM.Name = "vm"
+	vsphereVM.UID = apitypes.UID("8dbb2b76-fb1a-4b27-8e4e-84f1e0d0d6c2")
+	isoPath := Path(vsphereVM, datastore.Name())
+	if expected := "[" + datastore.Name() + "] cidata/vm-8dbb2b76-fb1a-4b27-8e4e-84f1e0d0d6c2.iso"; isoPath != expected {
+		t.Errorf("Expected path %q, got %q", expected, isoPath)
+	}
+
+	// Uploading the ISO creates its folder, and uploading it again replaces
+	// it.
+	for _, userData := range []string{"#cloud-config", "#cloud-config\nruncmd: []"} {
+		iso, err := NewISO([]byte(userData), []byte(testMetadata))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Upload(context.TODO(), s, isoPath, iso); err != nil {
+			t.Fatalf("Failed to upload ISO: %v", err)
+		}
+		var p object.DatastorePath
+		p.FromString(isoPath)
+		r, _, err := datastore.Download(context.TODO(), p.Path, &soap.DefaultDownload)
+		if err != nil {
+			t.Fatalf("Failed to download ISO: %v", err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(r); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		if _, files := readISO(t, buf.Bytes()); files["user-data"] != userData {
+			t.Errorf("Expected uploaded user data %q, got %q", userData, files["user-data"])
+		}
+	}
+
+	// The ISO is inserted into the VM's CD-ROM, or into a new CD-ROM if the
+	// VM has none.
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	devices, err := object.NewVirtualMachine(s.Client.Client, vm.Reference()).Device(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var withoutCdrom object.VirtualDeviceList
+	for _, device := range devices {
+		if _, ok := device.(*types.VirtualCdrom); !ok {
+			withoutCdrom = append(withoutCdrom, device)
+		}
+	}
+	if len(withoutCdrom) == len(devices) {
+		t.Fatal("Expected the VM to have a CD-ROM")
+	}
+	for _, tc := range []struct {
+		devices   object.VirtualDeviceList
+		operation types.VirtualDeviceConfigSpecOperation
+	}{
+		{devices: devices, operation: types.VirtualDeviceConfigSpecOperationEdit},
+		{devices: withoutCdrom, operation: types.VirtualDeviceConfigSpecOperationAdd},
+	} {
+		spec, err := AttachSpec(tc.devices, isoPath)
+		if err != nil {
+			t.Fatalf("Failed to get attach spec: %v", err)
+		}
+		deviceSpec := spec.GetVirtualDeviceConfigSpec()
+		if deviceSpec.Operation != tc.operation {
+			t.Errorf("Expected operation %q, got %q", tc.operation, deviceSpec.Operation)
+		}
+		backing, ok := deviceSpec.Device.GetVirtualDevice().Backing.(*types.VirtualCdromIsoBackingInfo)
+		if !ok || backing.FileName != isoPath {
+			t.Errorf("Expected CD-ROM backed by %q, got %#v", isoPath, deviceSpec.Device.GetVirtualDevice().Backing)
+		}
+
+		// The ISO is ejected from the CD-ROM into which it was inserted.
+		if spec := DetachSpec(object.VirtualDeviceList{deviceSpec.Device}, isoPath); spec == nil {
+			t.Error("Expected a detach spec")
+		} else if _, ok := spec.GetVirtualDeviceConfigSpec().Device.GetVirtualDevice().Backing.(*types.VirtualCdromIsoBackingInfo); ok {
+			t.Error("Expected the ISO to be ejected")
+		}
+		if spec := DetachSpec(tc.devices, isoPath); spec != nil {
+			t.Error("Expected no detach spec for a VM without the ISO")
+		}
+	}
+
+	// Deleting the ISO is idempotent.
+	for i := 0; i < 2; i++ {
+		if err := Delete(context.TODO(), s, isoPath); err != nil {
+			t.Fatalf("Failed to delete ISO: %v", err)
+		}
+	}
+}
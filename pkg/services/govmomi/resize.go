@@ -0,0 +1,217 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
+)
+
+// reconcileResize ensures the VM of a VSphereVM with in-place resize enabled
+// has the number of CPUs and the memory size of its spec. The guest of a
+// powered on VM whose CPUs or memory cannot be hot-added is shut down first,
+// the same way as before the VM is destroyed, and the VM is only powered off
+// if the guest does not shut down in time. The VM is resized once it is
+// powered off, and it is powered on again by reconcilePowerState.
+func (vms *VMService) reconcileResize(ctx *virtualMachineContext) (bool, error) {
+	if !ctx.VSphereVM.Spec.InPlaceResize {
+		conditions.Delete(ctx.VSphereVM, infrav1.VMResizedCondition)
+		return true, nil
+	}
+
+	var (
+		obj mo.VirtualMachine
+
+		props = []string{"config.hardware", "config.cpuHotAddEnabled", "config.memoryHotAddEnabled", "runtime.powerState"}
+	)
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, props, &obj); err != nil {
+		return false, errors.Wrapf(err, "unable to fetch props %v for vm %s", props, ctx)
+	}
+
+	configSpec, powerOff := getResizeSpec(ctx.VSphereVM.Spec.VirtualMachineCloneSpec, obj)
+	if powerOff {
+		if vms.shutdownGuest(ctx, infrav1.VMResizedCondition, infrav1.ShuttingDownGuestForResizeReason) {
+			return false, nil
+		}
+		ctx.Logger.Info("powering off to resize")
+		task, err := ctx.Obj.PowerOff(ctx)
+		if err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return false, errors.Wrapf(err, "failed to trigger power off op for vm %s", ctx)
+		}
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.PoweringOffForResizeReason, clusterv1.ConditionSeverityInfo, "")
+		ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+		return false, nil
+	}
+	// The guest is no longer shut down for a resize, so that a later shutdown
+	// of the guest is given its full timeout.
+	ctx.VSphereVM.Status.ShutdownStartTime = nil
+
+	if configSpec == nil {
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.VMResizedCondition)
+		return true, nil
+	}
+
+	ctx.Logger.Info("resizing", "numCPUs", configSpec.NumCPUs, "memoryMiB", configSpec.MemoryMB)
+	task, err := ctx.Obj.Reconfigure(ctx, *configSpec)
+	if err != nil {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizeFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return false, errors.Wrapf(err, "unable to resize vm %s", ctx)
+	}
+	conditions.MarkFalse(ctx.VSphereVM, infrav1.VMResizedCondition, infrav1.ResizingReason, clusterv1.ConditionSeverityInfo, "")
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return false, nil
+}
+
+// getResizeSpec returns the config spec that resizes the VM to the number of
+// CPUs and the memory size of the clone spec, or nil if the VM already has
+// them. The returned bool is true if the VM is powered on and must be
+// powered off to be resized, since its CPUs or memory cannot be hot-added.
+//
+// Unless the clone spec sets the number of cores per socket, a powered on
+// VM keeps its number of cores per socket so that CPUs may be hot-added.
+func getResizeSpec(spec infrav1.VirtualMachineCloneSpec, vm mo.VirtualMachine) (*types.VirtualMachineConfigSpec, bool) {
+	numCPUs, numCoresPerSocket, memoryMiB := vcenter.Size(spec)
+	hardware := vm.Config.Hardware
+	resizeCPUs := numCPUs != hardware.NumCPU
+	resizeCores := spec.NumCoresPerSocket != 0 && numCoresPerSocket != hardware.NumCoresPerSocket
+	resizeMemory := memoryMiB != int64(hardware.MemoryMB)
+	if !resizeCPUs && !resizeCores && !resizeMemory {
+		return nil, false
+	}
+
+	if vm.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOff {
+		return &types.VirtualMachineConfigSpec{
+			NumCPUs:           numCPUs,
+			NumCoresPerSocket: numCoresPerSocket,
+			MemoryMB:          memoryMiB,
+		}, false
+	}
+
+	// CPUs and memory may only be added to a powered on VM, and only if the
+	// VM enables hot-add. CPUs are added in whole sockets.
+	coresPerSocket := hardware.NumCoresPerSocket
+	if coresPerSocket == 0 {
+		coresPerSocket = 1
+	}
+	if resizeCores ||
+		resizeCPUs && (!isEnabled(vm.Config.CpuHotAddEnabled) || numCPUs < hardware.NumCPU || numCPUs%coresPerSocket != 0) ||
+		resizeMemory && (!isEnabled(vm.Config.MemoryHotAddEnabled) || memoryMiB < int64(hardware.MemoryMB)) {
+		return nil, true
+	}
+
+	configSpec := &types.VirtualMachineConfigSpec{}
+	if resizeCPUs {
+		configSpec.NumCPUs = numCPUs
+	}
+	if resizeMemory {
+		configSpec.MemoryMB = memoryMiB
+	}
+	return configSpec, false
+}
+
+func isEnabled(b *bool) bool {
+	return b != nil && *b
+}
+
+// reconcileDiskSize grows the disks of a VM whose spec requests a full clone
+// to the sizes of the VSphereVM's DiskGiB and additional disks, which is the
+// rule by which the webhooks allow the sizes to be increased. The grown disks
+// are named by the VM's guestinfo.disk.expanded extra config key, so that the
+// guest can grow their partitions and file systems.
+func (vms *VMService) reconcileDiskSize(ctx *virtualMachineContext) (bool, error) {
+	if !ctx.VSphereVM.Spec.RequestsFullClone() {
+		return true, nil
+	}
+
+	devices, err := ctx.Obj.Device(ctx)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to get devices for vm %s", ctx)
+	}
+	deviceSpecs, err := getDiskExpansionSpecs(ctx.VSphereVM.Spec.VirtualMachineCloneSpec, devices)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to get disk expansion specs for vm %s", ctx)
+	}
+	if len(deviceSpecs) == 0 {
+		return true, nil
+	}
+
+	expanded := make([]string, 0, len(deviceSpecs))
+	for _, deviceSpec := range deviceSpecs {
+		disk := deviceSpec.GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk)
+		expanded = append(expanded, fmt.Sprintf("%s=%dGiB", devices.Name(disk), disk.CapacityInKB/1024/1024))
+	}
+	ctx.Logger.Info("growing disks", "disks", expanded)
+	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		DeviceChange: deviceSpecs,
+		ExtraConfig: []types.BaseOptionValue{
+			&types.OptionValue{Key: guestInfoKeyDiskExpanded, Value: strings.Join(expanded, ",")},
+		},
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to grow disks of vm %s", ctx)
+	}
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	return false, nil
+}
+
+// getDiskExpansionSpecs returns the specs that grow the VM's disk selected by
+// the clone spec's DiskIndex and its additional disks to the sizes of the
+// clone spec. Since the additional disks are added when the VM is cloned,
+// they are the VM's last disks, in order. Disks are never shrunk, and a zero
+// DiskGiB leaves the disk as is.
+func getDiskExpansionSpecs(spec infrav1.VirtualMachineCloneSpec, devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	diskIndex := int(spec.DiskIndex)
+	if diskIndex < 0 || diskIndex >= len(disks) {
+		return nil, errors.Errorf("invalid disk index %d, the vm has %d disks", diskIndex, len(disks))
+	}
+	firstAdditionalDisk := len(disks) - len(spec.AdditionalDisks)
+	if firstAdditionalDisk < 0 {
+		return nil, errors.Errorf("the vm has %d disks, fewer than its %d additional disks", len(disks), len(spec.AdditionalDisks))
+	}
+
+	sizes := map[int]int32{diskIndex: spec.DiskGiB}
+	for i, additionalDisk := range spec.AdditionalDisks {
+		sizes[firstAdditionalDisk+i] = additionalDisk.SizeGiB
+	}
+
+	var deviceSpecs []types.BaseVirtualDeviceConfigSpec
+	for i, device := range disks {
+		disk := device.(*types.VirtualDisk)
+		capacityKB := int64(sizes[i]) * 1024 * 1024
+		if capacityKB <= disk.CapacityInKB {
+			continue
+		}
+		disk.CapacityInKB = capacityKB
+		deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    disk,
+		})
+	}
+	return deviceSpecs, nil
+}
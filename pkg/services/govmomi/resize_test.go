@@ -0,0 +1,266 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"crypto/tls"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestReconcileResize(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	defer model.Remove()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+
+	s := model.Service.NewServer()
+	defer s.Close()
+	pass, _ := s.URL.User.Password()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VMShutdownTimeout = time.Minute
+	vmContext.VSphereVM.Spec.Server = s.URL.Host
+	vmContext.VSphereVM.Spec.InPlaceResize = true
+	vmContext.VSphereVM.Spec.NumCPUs = 4
+	authSession, err := vmContext.SessionProvider.GetOrCreate(
+		vmContext,
+		session.Params{
+			Server:   vmContext.VSphereVM.Spec.Server,
+			Username: s.URL.User.Username(),
+			Password: pass,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vmContext.Session = authSession
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	if vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOn || isEnabled(vm.Config.CpuHotAddEnabled) {
+		t.Fatal("Expected a powered on VM without CPU hot-add")
+	}
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Ref:       vm.Reference(),
+		Obj:       object.NewVirtualMachine(authSession.Client.Client, vm.Reference()),
+	}
+
+	// The guest is shut down rather than the VM powered off.
+	if resized, err := (&VMService{}).reconcileResize(ctx); err != nil || resized {
+		t.Fatalf("Expected the VM to not be resized yet, got %t, %v", resized, err)
+	}
+	if ctx.VSphereVM.Status.ShutdownStartTime == nil || ctx.VSphereVM.Status.TaskRef != "" {
+		t.Fatal("Expected the guest to be shut down without a power off task")
+	}
+	if reason := conditions.GetReason(ctx.VSphereVM, infrav1.VMResizedCondition); reason != infrav1.ShuttingDownGuestForResizeReason {
+		t.Fatalf("Expected reason %q, got %q", infrav1.ShuttingDownGuestForResizeReason, reason)
+	}
+	if vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOff {
+		t.Fatalf("Expected the VM to be powered off, got %q", vm.Runtime.PowerState)
+	}
+
+	// The VM is resized once the guest is shut down.
+	if resized, err := (&VMService{}).reconcileResize(ctx); err != nil || resized {
+		t.Fatalf("Expected the VM to be resizing, got %t, %v", resized, err)
+	}
+	if ctx.VSphereVM.Status.ShutdownStartTime != nil || ctx.VSphereVM.Status.TaskRef == "" {
+		t.Fatal("Expected the VM to be reconfigured")
+	}
+	if reason := conditions.GetReason(ctx.VSphereVM, infrav1.VMResizedCondition); reason != infrav1.ResizingReason {
+		t.Fatalf("Expected reason %q, got %q", infrav1.ResizingReason, reason)
+	}
+	waitForTask(t, &ctx.VMContext)
+	if vm.Config.Hardware.NumCPU != 4 {
+		t.Errorf("Expected the VM to have 4 CPUs, got %d", vm.Config.Hardware.NumCPU)
+	}
+}
+
+func TestGetResizeSpec(t *testing.T) {
+	newVM := func(powerState types.VirtualMachinePowerState, hotAdd bool) mo.VirtualMachine {
+		return mo.VirtualMachine{
+			Config: &types.VirtualMachineConfigInfo{
+				Hardware: types.VirtualHardware{
+					NumCPU:            2,
+					NumCoresPerSocket: 2,
+					MemoryMB:          4096,
+				},
+				CpuHotAddEnabled:    types.NewBool(hotAdd),
+				MemoryHotAddEnabled: types.NewBool(hotAdd),
+			},
+			Runtime: types.VirtualMachineRuntimeInfo{PowerState: powerState},
+		}
+	}
+
+	testCases := []struct {
+		name             string
+		spec             infrav1.VirtualMachineCloneSpec
+		vm               mo.VirtualMachine
+		expectConfigSpec *types.VirtualMachineConfigSpec
+		expectPowerOff   bool
+	}{
+		{
+			name: "VM already has the size",
+			spec: infrav1.VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 4096},
+			vm:   newVM(types.VirtualMachinePowerStatePoweredOn, false),
+		},
+		{
+			name:             "Powered off VM",
+			spec:             infrav1.VirtualMachineCloneSpec{NumCPUs: 3, MemoryMiB: 2048},
+			vm:               newVM(types.VirtualMachinePowerStatePoweredOff, false),
+			expectConfigSpec: &types.VirtualMachineConfigSpec{NumCPUs: 3, NumCoresPerSocket: 3, MemoryMB: 2048},
+		},
+		{
+			name:             "Hot-add CPU sockets and memory",
+			spec:             infrav1.VirtualMachineCloneSpec{NumCPUs: 4, MemoryMiB: 8192},
+			vm:               newVM(types.VirtualMachinePowerStatePoweredOn, true),
+			expectConfigSpec: &types.VirtualMachineConfigSpec{NumCPUs: 4, MemoryMB: 8192},
+		},
+		{
+			name:           "Hot-add disabled",
+			spec:           infrav1.VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 8192},
+			vm:             newVM(types.VirtualMachinePowerStatePoweredOn, false),
+			expectPowerOff: true,
+		},
+		{
+			name:           "Hot-add less than a socket",
+			spec:           infrav1.VirtualMachineCloneSpec{NumCPUs: 3, MemoryMiB: 4096},
+			vm:             newVM(types.VirtualMachinePowerStatePoweredOn, true),
+			expectPowerOff: true,
+		},
+		{
+			name:           "Remove memory",
+			spec:           infrav1.VirtualMachineCloneSpec{NumCPUs: 2, MemoryMiB: 2048},
+			vm:             newVM(types.VirtualMachinePowerStatePoweredOn, true),
+			expectPowerOff: true,
+		},
+		{
+			name:           "Change cores per socket",
+			spec:           infrav1.VirtualMachineCloneSpec{NumCPUs: 2, NumCoresPerSocket: 1, MemoryMiB: 4096},
+			vm:             newVM(types.VirtualMachinePowerStatePoweredOn, true),
+			expectPowerOff: true,
+		},
+	}
+
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			configSpec, powerOff := getResizeSpec(tc.spec, tc.vm)
+			if powerOff != tc.expectPowerOff {
+				t.Fatalf("Expected power off to be %t, got %t", tc.expectPowerOff, powerOff)
+			}
+			if !reflect.DeepEqual(configSpec, tc.expectConfigSpec) {
+				t.Fatalf("Expected config spec %+v, got %+v", tc.expectConfigSpec, configSpec)
+			}
+		})
+	}
+}
+
+func TestGetDiskExpansionSpecs(t *testing.T) {
+	newDisks := func(sizesGiB ...int64) object.VirtualDeviceList {
+		var devices object.VirtualDeviceList
+		for i, size := range sizesGiB {
+			devices = append(devices, &types.VirtualDisk{
+				VirtualDevice: types.VirtualDevice{Key: int32(2000 + i)},
+				CapacityInKB:  size * 1024 * 1024,
+			})
+		}
+		return devices
+	}
+
+	testCases := []struct {
+		name        string
+		spec        infrav1.VirtualMachineCloneSpec
+		devices     object.VirtualDeviceList
+		expectSizes map[int32]int64
+		expectError bool
+	}{
+		{
+			name:    "Disks already have their sizes",
+			spec:    infrav1.VirtualMachineCloneSpec{DiskGiB: 20, AdditionalDisks: []infrav1.AdditionalDiskSpec{{SizeGiB: 10}}},
+			devices: newDisks(20, 10),
+		},
+		{
+			name:        "Grow the disk and an additional disk",
+			spec:        infrav1.VirtualMachineCloneSpec{DiskGiB: 40, AdditionalDisks: []infrav1.AdditionalDiskSpec{{SizeGiB: 10}, {SizeGiB: 50}}},
+			devices:     newDisks(20, 10, 10),
+			expectSizes: map[int32]int64{2000: 40, 2002: 50},
+		},
+		{
+			name:        "Grow the disk selected by the disk index",
+			spec:        infrav1.VirtualMachineCloneSpec{DiskGiB: 40, DiskIndex: 1},
+			devices:     newDisks(20, 20),
+			expectSizes: map[int32]int64{2001: 40},
+		},
+		{
+			name:    "Disks are not shrunk",
+			spec:    infrav1.VirtualMachineCloneSpec{DiskGiB: 10},
+			devices: newDisks(20),
+		},
+		{
+			name:        "VM without the additional disks",
+			spec:        infrav1.VirtualMachineCloneSpec{AdditionalDisks: []infrav1.AdditionalDiskSpec{{SizeGiB: 10}, {SizeGiB: 10}}},
+			devices:     newDisks(20),
+			expectError: true,
+		},
+	}
+
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			deviceSpecs, err := getDiskExpansionSpecs(tc.spec, tc.devices)
+			if tc.expectError {
+				if err == nil {
+					t.Fatal("Expected an error from getDiskExpansionSpecs")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error from getDiskExpansionSpecs: %v", err)
+			}
+			sizes := map[int32]int64{}
+			for _, deviceSpec := range deviceSpecs {
+				spec := deviceSpec.GetVirtualDeviceConfigSpec()
+				if spec.Operation != types.VirtualDeviceConfigSpecOperationEdit {
+					t.Fatalf("Expected an edit operation, got %q", spec.Operation)
+				}
+				disk := spec.Device.(*types.VirtualDisk)
+				sizes[disk.Key] = disk.CapacityInKB / 1024 / 1024
+			}
+			if len(sizes) == 0 {
+				sizes = nil
+			}
+			if !reflect.DeepEqual(sizes, tc.expectSizes) {
+				t.Fatalf("Expected disk sizes %v, got %v", tc.expectSizes, sizes)
+			}
+		})
+	}
+}
@@ -19,6 +19,9 @@ package govmomi
 import (
 	"encoding/base64"
 	"fmt"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -27,6 +30,7 @@ import (
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
@@ -35,8 +39,14 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/cluster"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/net"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/nocloud"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/storagepolicy"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/tags"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vapp"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vcenter"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
@@ -62,6 +72,10 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		return vm, err
 	}
 
+	// Without an in-flight task the VM no longer needs the clone slot it may
+	// have reserved on the vSphere server.
+	ctx.Session.ReleaseClone(string(ctx.VSphereVM.UID))
+
 	// This deferred function will trigger a reconcile event for the
 	// VSphereVM resource once its associated task completes. If
 	// there is no task for the VSphereVM resource then no reconcile
@@ -82,28 +96,54 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 			return vm, err
 		}
 
-		// Otherwise, this is a new machine and the  the VM should be created.
+		// Otherwise, this is a new machine and the  the VM should be created,
+		// provided there is capacity for another clone on the vSphere server.
+		if !ctx.Session.TryAcquireClone(string(ctx.VSphereVM.UID)) {
+			ctx.Logger.Info("waiting for in-flight clone operations to complete")
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.WaitingForCapacityReason, clusterv1.ConditionSeverityInfo,
+				"too many in-flight clone operations on the vSphere server")
+			return vm, nil
+		}
+
 		// NOTE: We are setting this condition only in case it does not exists so we avoid to get flickering LastConditionTime
 		// in case of cloning errors or powering on errors.
-		if !conditions.Has(ctx.VSphereVM, infrav1.VMProvisionedCondition) {
+		if !conditions.Has(ctx.VSphereVM, infrav1.VMProvisionedCondition) ||
+			conditions.GetReason(ctx.VSphereVM, infrav1.VMProvisionedCondition) == infrav1.WaitingForCapacityReason {
 			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningReason, clusterv1.ConditionSeverityInfo, "")
 		}
 
 		// Get the bootstrap data.
-		bootstrapData, err := vms.getBootstrapData(ctx)
+		bootstrapData, format, err := vms.getBootstrapData(ctx)
 		if err != nil {
+			ctx.Session.ReleaseClone(string(ctx.VSphereVM.UID))
 			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 			return vm, err
 		}
 
 		// Create the VM.
-		err = createVM(ctx, bootstrapData)
+		err = createVM(ctx, bootstrapData, format)
 		if err != nil {
+			ctx.Session.ReleaseClone(string(ctx.VSphereVM.UID))
 			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
 		}
 		return vm, nil
 	}
 
+	// A VM deployed from a Content Library item is customized before it is
+	// updated or powered on, which is retried until the customization
+	// succeeds.
+	if ctx.VSphereVM.Status.PendingCustomization {
+		bootstrapData, format, err := vms.getBootstrapData(ctx)
+		if err != nil {
+			return vm, err
+		}
+		if err := vcenter.CustomizeLibraryVM(ctx, vmRef, bootstrapData, format); err != nil {
+			conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.CloningFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return vm, err
+		}
+		return vm, nil
+	}
+
 	//
 	// At this point we know the VM exists, so it needs to be updated.
 	//
@@ -122,6 +162,28 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		return vm, err
 	}
 
+	if err := vms.reconcileDiskStatus(vmCtx); err != nil {
+		return vm, err
+	}
+
+	vms.reconcileStoragePolicy(vmCtx)
+
+	if err := vms.reconcileHostGroup(vmCtx); err != nil {
+		return vm, err
+	}
+
+	if err := vms.reconcileTags(vmCtx); err != nil {
+		return vm, err
+	}
+
+	if ok, err := vms.reconcileDiskSize(vmCtx); err != nil || !ok {
+		return vm, err
+	}
+
+	if ok, err := vms.reconcileResize(vmCtx); err != nil || !ok {
+		return vm, err
+	}
+
 	if ok, err := vms.reconcileMetadata(vmCtx); err != nil || !ok {
 		return vm, err
 	}
@@ -130,6 +192,10 @@ func (vms *VMService) ReconcileVM(ctx *context.VMContext) (vm infrav1.VirtualMac
 		return vm, err
 	}
 
+	if err := vms.reconcileBootstrapISO(vmCtx); err != nil {
+		return vm, err
+	}
+
 	vm.State = infrav1.VirtualMachineStateReady
 	return vm, nil
 }
@@ -148,6 +214,10 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 		return vm, err
 	}
 
+	// Without an in-flight task the VM no longer needs the clone slot it may
+	// have reserved on the vSphere server.
+	ctx.Session.ReleaseClone(string(ctx.VSphereVM.UID))
+
 	// This deferred function will trigger a reconcile event for the
 	// VSphereVM resource once its associated task completes. If
 	// there is no task for the VSphereVM resource then no reconcile
@@ -160,6 +230,9 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 		// If the VM's MoRef could not be found then the VM no longer exists. This
 		// is the desired state.
 		if isNotFound(err) || isFolderNotFound(err) {
+			if err := vms.deleteBootstrapISO(ctx); err != nil {
+				return vm, err
+			}
 			vm.State = infrav1.VirtualMachineStateNotFound
 			return vm, nil
 		}
@@ -178,12 +251,16 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 		State:     &vm,
 	}
 
-	// Power off the VM.
+	// Shut down the guest, or power off the VM if the guest does not shut
+	// down in time.
 	powerState, err := vms.getPowerState(vmCtx)
 	if err != nil {
 		return vm, err
 	}
 	if powerState == infrav1.VirtualMachinePowerStatePoweredOn {
+		if vms.shutdownGuest(vmCtx, infrav1.VMProvisionedCondition, infrav1.ShuttingDownGuestReason) {
+			return vm, nil
+		}
 		task, err := vmCtx.Obj.PowerOff(ctx)
 		if err != nil {
 			return vm, err
@@ -205,6 +282,46 @@ func (vms *VMService) DestroyVM(ctx *context.VMContext) (infrav1.VirtualMachine,
 	return vm, nil
 }
 
+// shutdownGuest asks the guest of a powered on VM to shut down through VMware
+// Tools before the VM is destroyed or resized, and returns true while the
+// guest is given time to shut down, during which the condition is marked
+// false with the reason. The time the guest was asked to shut down is
+// recorded in the VSphereVM's status, since shutting down a guest is not a
+// task. It returns false, so that the VM is powered off, once the shutdown
+// timeout has elapsed, if the timeout is not positive, or if the guest cannot
+// be shut down, e.g. because VMware Tools is not running.
+func (vms *VMService) shutdownGuest(ctx *virtualMachineContext, conditionType clusterv1.ConditionType, reason string) bool {
+	timeout := ctx.VMShutdownTimeout
+	if ctx.VSphereVM.Spec.ShutdownTimeout != nil {
+		timeout = ctx.VSphereVM.Spec.ShutdownTimeout.Duration
+	}
+	if timeout <= 0 {
+		return false
+	}
+
+	if start := ctx.VSphereVM.Status.ShutdownStartTime; start != nil {
+		if time.Since(start.Time) < timeout {
+			ctx.Logger.Info("wait for guest to shut down", "timeout", timeout)
+			conditions.MarkFalse(ctx.VSphereVM, conditionType, reason, clusterv1.ConditionSeverityInfo, "")
+			return true
+		}
+		msg := fmt.Sprintf("guest did not shut down within %s, powering off the VM", timeout)
+		ctx.Logger.Info(msg)
+		ctx.Recorder.Warn(ctx.VSphereVM, "ShutdownTimeout", msg)
+		return false
+	}
+
+	ctx.Logger.Info("shutting down guest", "timeout", timeout)
+	if err := ctx.Obj.ShutdownGuest(ctx); err != nil {
+		ctx.Logger.Error(err, "failed to shut down guest, powering off the VM")
+		return false
+	}
+	now := metav1.Now()
+	ctx.VSphereVM.Status.ShutdownStartTime = &now
+	conditions.MarkFalse(ctx.VSphereVM, conditionType, reason, clusterv1.ConditionSeverityInfo, "")
+	return true
+}
+
 func (vms *VMService) reconcileNetworkStatus(ctx *virtualMachineContext) error {
 	netStatus, err := vms.getNetworkStatus(ctx)
 	if err != nil {
@@ -214,8 +331,86 @@ func (vms *VMService) reconcileNetworkStatus(ctx *virtualMachineContext) error {
 	return nil
 }
 
+func (vms *VMService) reconcileDiskStatus(ctx *virtualMachineContext) error {
+	diskStatus, err := vms.getDiskStatus(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.State.Disks = diskStatus
+	return nil
+}
+
+// reconcileStoragePolicy reports whether the VM's datastores are compatible
+// with its storage policy. A VM that is not compliant is still reconciled.
+func (vms *VMService) reconcileStoragePolicy(ctx *virtualMachineContext) {
+	policyName := ctx.VSphereVM.Spec.StoragePolicyName
+	if policyName == "" {
+		conditions.Delete(ctx.VSphereVM, infrav1.StoragePolicyCompliantCondition)
+		return
+	}
+
+	incompatible, err := vms.getIncompatibleDatastores(ctx, policyName)
+	if err != nil {
+		ctx.Logger.Error(err, "failed to check storage policy compliance", "storage-policy", policyName)
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.StoragePolicyCompliantCondition, infrav1.StoragePolicyCheckFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return
+	}
+	if len(incompatible) > 0 {
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.StoragePolicyCompliantCondition, infrav1.StoragePolicyNotCompliantReason, clusterv1.ConditionSeverityWarning,
+			"datastores %s are not compatible with storage policy %q", strings.Join(incompatible, ", "), policyName)
+		return
+	}
+	conditions.MarkTrue(ctx.VSphereVM, infrav1.StoragePolicyCompliantCondition)
+}
+
+// reconcileHostGroup ensures the VM is placed on the hosts of its host group
+// by a VM/Host affinity rule. The VM is added to the rule's VM group before it
+// is powered on, so DRS does not have to migrate it afterwards.
+func (vms *VMService) reconcileHostGroup(ctx *virtualMachineContext) error {
+	hostGroup := ctx.VSphereVM.Spec.HostGroup
+	if hostGroup == "" {
+		return nil
+	}
+	if err := cluster.EnsureHostGroupAffinity(ctx, ctx.Obj, hostGroup); err != nil {
+		return errors.Wrapf(err, "unable to add vm %s to the VM group of host group %q", ctx, hostGroup)
+	}
+	return nil
+}
+
+// reconcileTags ensures the VM has its tags and custom attributes. Since the
+// tags are managed with the vSphere Automation API, which takes several
+// requests per VM, they are only attached once the VM's tags differ from the
+// ones recorded in its status. Custom attributes may be changed outside of
+// CAPV, so they are reconciled on every pass. VMs on standalone ESXi hosts
+// have neither, since both are managed by vCenter.
+func (vms *VMService) reconcileTags(ctx *virtualMachineContext) error {
+	if !ctx.Session.IsVC() {
+		return nil
+	}
+	if vmTags := tags.ForVM(ctx.VSphereVM); !reflect.DeepEqual(vmTags, ctx.VSphereVM.Status.Tags) {
+		if err := tags.Reconcile(ctx, ctx.Session, ctx.Ref, vmTags); err != nil {
+			return errors.Wrapf(err, "unable to reconcile tags of vm %s", ctx)
+		}
+		ctx.VSphereVM.Status.Tags = vmTags
+	}
+	if err := tags.ReconcileCustomAttributes(ctx, ctx.Session, ctx.Ref, ctx.VSphereVM.Spec.CustomAttributes); err != nil {
+		return errors.Wrapf(err, "unable to reconcile custom attributes of vm %s", ctx)
+	}
+	return nil
+}
+
 func (vms *VMService) reconcileMetadata(ctx *virtualMachineContext) (bool, error) {
-	existingMetadata, err := vms.getMetadata(ctx)
+	// VMs bootstrapped with Ignition have no cloud-init metadata, since
+	// their network configuration is part of their Ignition config.
+	existingIgnitionConfig, err := vms.getMetadata(ctx, guestInfoKeyIgnition)
+	if err != nil {
+		return false, err
+	}
+	if existingIgnitionConfig != "" {
+		return vms.reconcileIgnitionConfig(ctx, existingIgnitionConfig)
+	}
+
+	existingMetadata, err := vms.getMetadata(ctx, guestInfoKeyMetadata)
 	if err != nil {
 		return false, err
 	}
@@ -230,6 +425,15 @@ func (vms *VMService) reconcileMetadata(ctx *virtualMachineContext) (bool, error
 		return true, nil
 	}
 
+	// The metadata of VMs that use the NoCloud or vApp bootstrap transports
+	// is still recorded in their extra config, so that it is only updated on
+	// their ISO or in their vApp properties when it changes.
+	if ctx.VSphereVM.Status.BootstrapISO != "" {
+		if err := vms.updateBootstrapISO(ctx, newMetadata); err != nil {
+			return false, errors.Wrapf(err, "unable to update bootstrap ISO of vm %s", ctx)
+		}
+	}
+
 	ctx.Logger.Info("updating metadata")
 	taskRef, err := vms.setMetadata(ctx, newMetadata)
 	if err != nil {
@@ -241,6 +445,124 @@ func (vms *VMService) reconcileMetadata(ctx *virtualMachineContext) (bool, error
 	return false, nil
 }
 
+// reconcileIgnitionConfig ensures the Ignition config of a VM bootstrapped
+// with Ignition includes its network configuration. Since Ignition only runs
+// when the VM first boots, the config is only updated before the VM is
+// powered on.
+func (vms *VMService) reconcileIgnitionConfig(ctx *virtualMachineContext, existingConfig string) (bool, error) {
+	powerState, err := vms.getPowerState(ctx)
+	if err != nil {
+		return false, err
+	}
+	if powerState != infrav1.VirtualMachinePowerStatePoweredOff {
+		return true, nil
+	}
+
+	bootstrapData, _, err := vms.getBootstrapData(&ctx.VMContext)
+	if err != nil {
+		return false, err
+	}
+	newConfig, err := util.GetMachineIgnitionConfig(ctx.VSphereVM.Name, *ctx.VSphereVM, bootstrapData, ctx.State.Network...)
+	if err != nil {
+		return false, err
+	}
+
+	// If the config is the same then return early.
+	if string(newConfig) == existingConfig {
+		return true, nil
+	}
+
+	ctx.Logger.Info("updating ignition config")
+	var extraConfig extra.Config
+	if err := extraConfig.SetIgnitionUserData(newConfig); err != nil {
+		return false, errors.Wrapf(err, "unable to set ignition config on vm %s", ctx)
+	}
+	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+		ExtraConfig: extraConfig,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to set ignition config on vm %s", ctx)
+	}
+
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+	ctx.Logger.Info("wait for VM ignition config to be updated")
+	return false, nil
+}
+
+// updateBootstrapISO updates the metadata on the VM's NoCloud ISO. Since
+// cloud-init only reads the ISO when the VM first boots, the ISO is only
+// updated before the VM is powered on.
+func (vms *VMService) updateBootstrapISO(ctx *virtualMachineContext, metadata []byte) error {
+	powerState, err := vms.getPowerState(ctx)
+	if err != nil {
+		return err
+	}
+	if powerState != infrav1.VirtualMachinePowerStatePoweredOff {
+		return nil
+	}
+
+	bootstrapData, _, err := vms.getBootstrapData(&ctx.VMContext)
+	if err != nil {
+		return err
+	}
+	iso, err := nocloud.NewISO(bootstrapData, metadata)
+	if err != nil {
+		return err
+	}
+	ctx.Logger.Info("updating bootstrap ISO", "path", ctx.VSphereVM.Status.BootstrapISO)
+	return nocloud.Upload(ctx, ctx.Session, ctx.VSphereVM.Status.BootstrapISO, iso)
+}
+
+// reconcileBootstrapISO detaches and deletes the VM's NoCloud ISO once the VM
+// reports its IP addresses, by which time cloud-init has read the ISO.
+func (vms *VMService) reconcileBootstrapISO(ctx *virtualMachineContext) error {
+	isoPath := ctx.VSphereVM.Status.BootstrapISO
+	if isoPath == "" || !hasIPAddrs(ctx.State.Network) {
+		return nil
+	}
+
+	devices, err := ctx.Obj.Device(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get devices for vm %s", ctx)
+	}
+	if deviceSpec := nocloud.DetachSpec(devices, isoPath); deviceSpec != nil {
+		ctx.Logger.Info("detaching bootstrap ISO", "path", isoPath)
+		task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+			DeviceChange: []types.BaseVirtualDeviceConfigSpec{deviceSpec},
+		})
+		if err != nil {
+			return errors.Wrapf(err, "unable to detach bootstrap ISO from vm %s", ctx)
+		}
+		ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+		return nil
+	}
+
+	return vms.deleteBootstrapISO(&ctx.VMContext)
+}
+
+// deleteBootstrapISO deletes the VM's NoCloud ISO, if any.
+func (vms *VMService) deleteBootstrapISO(ctx *context.VMContext) error {
+	isoPath := ctx.VSphereVM.Status.BootstrapISO
+	if isoPath == "" {
+		return nil
+	}
+	ctx.Logger.Info("deleting bootstrap ISO", "path", isoPath)
+	if err := nocloud.Delete(ctx, ctx.Session, isoPath); err != nil {
+		return errors.Wrapf(err, "unable to delete bootstrap ISO of vm %s", ctx)
+	}
+	ctx.VSphereVM.Status.BootstrapISO = ""
+	return nil
+}
+
+func hasIPAddrs(networkStatus []infrav1.NetworkStatus) bool {
+	for _, status := range networkStatus {
+		if len(status.IPAddrs) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (vms *VMService) reconcilePowerState(ctx *virtualMachineContext) (bool, error) {
 	powerState, err := vms.getPowerState(ctx)
 	if err != nil {
@@ -295,7 +617,9 @@ func (vms *VMService) getPowerState(ctx *virtualMachineContext) (infrav1.Virtual
 	}
 }
 
-func (vms *VMService) getMetadata(ctx *virtualMachineContext) (string, error) {
+// getMetadata returns the decoded value of the VM's base64-encoded extra
+// config key, or an empty string if the VM does not have the key.
+func (vms *VMService) getMetadata(ctx *virtualMachineContext, key string) (string, error) {
 	var (
 		obj mo.VirtualMachine
 
@@ -319,7 +643,7 @@ func (vms *VMService) getMetadata(ctx *virtualMachineContext) (string, error) {
 			//             base64, it should be okay to not check.
 			// nolint
 			switch optVal.Key {
-			case guestInfoKeyMetadata:
+			case key:
 				if v, ok := optVal.Value.(string); ok {
 					metadataBase64 = v
 				}
@@ -345,9 +669,20 @@ func (vms *VMService) setMetadata(ctx *virtualMachineContext, metadata []byte) (
 		return "", errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
 	}
 
-	task, err := ctx.Obj.Reconfigure(ctx, types.VirtualMachineConfigSpec{
+	spec := types.VirtualMachineConfigSpec{
 		ExtraConfig: extraConfig,
-	})
+	}
+	if ctx.VSphereVM.Spec.BootstrapTransport == infrav1.VAppBootstrapTransport {
+		vAppConfig, err := vms.getVAppConfigSpec(ctx, metadata)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to set vApp properties on vm %s", ctx)
+		}
+		if vAppConfig != nil {
+			spec.VAppConfig = vAppConfig
+		}
+	}
+
+	task, err := ctx.Obj.Reconfigure(ctx, spec)
 	if err != nil {
 		return "", errors.Wrapf(err, "unable to set metadata on vm %s", ctx)
 	}
@@ -355,6 +690,35 @@ func (vms *VMService) setMetadata(ctx *virtualMachineContext, metadata []byte) (
 	return task.Reference().Value, nil
 }
 
+// getVAppConfigSpec returns the spec that updates the metadata in the vApp
+// properties of a VM that uses the vApp bootstrap transport, or nil if the
+// properties are up to date. Since the guest only reads the OVF environment
+// when the VM first boots, the properties are only updated before the VM is
+// powered on.
+func (vms *VMService) getVAppConfigSpec(ctx *virtualMachineContext, metadata []byte) (*types.VmConfigSpec, error) {
+	powerState, err := vms.getPowerState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if powerState != infrav1.VirtualMachinePowerStatePoweredOff {
+		return nil, nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := ctx.Obj.Properties(ctx, ctx.Ref, []string{"config.vAppConfig"}, &obj); err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch vApp properties for vm %s", ctx)
+	}
+	if obj.Config == nil || obj.Config.VAppConfig == nil {
+		return nil, nil
+	}
+
+	values, err := vapp.BootstrapProperties(nil, metadata)
+	if err != nil {
+		return nil, err
+	}
+	return vapp.ConfigSpec(obj.Config.VAppConfig.GetVmConfigInfo(), values, vapp.MetadataProperties...)
+}
+
 func (vms *VMService) getNetworkStatus(ctx *virtualMachineContext) ([]infrav1.NetworkStatus, error) {
 	allNetStatus, err := net.GetNetworkStatus(ctx, ctx.Session.Client.Client, ctx.Ref)
 	if err != nil {
@@ -373,10 +737,86 @@ func (vms *VMService) getNetworkStatus(ctx *virtualMachineContext) ([]infrav1.Ne
 	return apiNetStatus, nil
 }
 
-func (vms *VMService) getBootstrapData(ctx *context.VMContext) ([]byte, error) {
+func (vms *VMService) getDiskStatus(ctx *virtualMachineContext) ([]infrav1.DiskStatus, error) {
+	devices, err := ctx.Obj.Device(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get devices for vm %s", ctx)
+	}
+
+	apiDiskStatus := []infrav1.DiskStatus{}
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		disk := device.(*types.VirtualDisk)
+		status := infrav1.DiskStatus{
+			Label:   devices.Name(disk),
+			SizeGiB: int32(disk.CapacityInKB / 1024 / 1024),
+		}
+		if info := disk.DeviceInfo; info != nil && info.GetDescription().Label != "" {
+			status.Label = info.GetDescription().Label
+		}
+		if controller, ok := devices.FindByKey(disk.ControllerKey).(types.BaseVirtualController); ok {
+			status.ControllerNumber = controller.GetVirtualController().BusNumber
+		}
+		if disk.UnitNumber != nil {
+			status.UnitNumber = *disk.UnitNumber
+		}
+		if backing, ok := disk.Backing.(types.BaseVirtualDeviceFileBackingInfo); ok {
+			status.FileName = backing.GetVirtualDeviceFileBackingInfo().FileName
+			var path object.DatastorePath
+			if path.FromString(status.FileName) {
+				status.Datastore = path.Datastore
+			}
+		}
+		if backing, ok := disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo); ok {
+			switch {
+			case backing.ThinProvisioned != nil && *backing.ThinProvisioned:
+				status.ProvisioningType = infrav1.ThinProvisioning
+			case backing.EagerlyScrub != nil && *backing.EagerlyScrub:
+				status.ProvisioningType = infrav1.EagerZeroedThickProvisioning
+			default:
+				status.ProvisioningType = infrav1.ThickProvisioning
+			}
+		}
+		apiDiskStatus = append(apiDiskStatus, status)
+	}
+	return apiDiskStatus, nil
+}
+
+// getIncompatibleDatastores returns the names of the VM's datastores that are
+// not compatible with the storage policy.
+func (vms *VMService) getIncompatibleDatastores(ctx *virtualMachineContext, policyName string) ([]string, error) {
+	var (
+		obj mo.VirtualMachine
+
+		pc = property.DefaultCollector(ctx.Session.Client.Client)
+	)
+	if err := pc.RetrieveOne(ctx, ctx.Ref, []string{"datastore"}, &obj); err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch datastores for vm %s", ctx)
+	}
+
+	profileID, err := storagepolicy.ProfileID(ctx, policyName)
+	if err != nil {
+		return nil, err
+	}
+	incompatible, err := storagepolicy.IncompatibleDatastores(ctx, profileID, obj.Datastore)
+	if err != nil || len(incompatible) == 0 {
+		return nil, err
+	}
+
+	var datastores []mo.Datastore
+	if err := pc.Retrieve(ctx, incompatible, []string{"name"}, &datastores); err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch datastore names for vm %s", ctx)
+	}
+	names := make([]string, len(datastores))
+	for i := range datastores {
+		names[i] = datastores[i].Name
+	}
+	return names, nil
+}
+
+func (vms *VMService) getBootstrapData(ctx *context.VMContext) ([]byte, infrav1.BootstrapFormat, error) {
 	if ctx.VSphereVM.Spec.BootstrapRef == nil {
 		ctx.Logger.Info("VM has no bootstrap data")
-		return nil, nil
+		return nil, "", nil
 	}
 
 	secret := &corev1.Secret{}
@@ -385,13 +825,27 @@ func (vms *VMService) getBootstrapData(ctx *context.VMContext) ([]byte, error) {
 		Name:      ctx.VSphereVM.Spec.BootstrapRef.Name,
 	}
 	if err := ctx.Client.Get(ctx, secretKey, secret); err != nil {
-		return nil, errors.Wrapf(err, "failed to retrieve bootstrap data secret for %s", ctx)
+		return nil, "", errors.Wrapf(err, "failed to retrieve bootstrap data secret for %s", ctx)
 	}
 
 	value, ok := secret.Data["value"]
 	if !ok {
-		return nil, errors.New("error retrieving bootstrap data: secret value key is missing")
+		return nil, "", errors.New("error retrieving bootstrap data: secret value key is missing")
+	}
+
+	// The format of the bootstrap data is the VSphereVM's bootstrap format,
+	// if any, or the format recorded in the secret by the bootstrap provider.
+	format := ctx.VSphereVM.Spec.BootstrapFormat
+	if format == "" {
+		format = infrav1.BootstrapFormat(secret.Data["format"])
+	}
+	switch format {
+	case "":
+		format = infrav1.CloudConfigBootstrapFormat
+	case infrav1.CloudConfigBootstrapFormat, infrav1.IgnitionBootstrapFormat:
+	default:
+		return nil, "", errors.Errorf("error retrieving bootstrap data: unsupported format %q", format)
 	}
 
-	return value, nil
+	return value, format, nil
 }
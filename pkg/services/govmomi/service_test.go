@@ -0,0 +1,342 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package govmomi
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/library"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	vapitags "github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestDestroyVM(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+	model.Machine = 3
+
+	defer model.Remove()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+
+	s := model.Service.NewServer()
+	defer s.Close()
+	pass, _ := s.URL.User.Password()
+
+	newVMContext := func(t *testing.T, vm *simulator.VirtualMachine) *context.VMContext {
+		vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+		vmContext.VMShutdownTimeout = time.Minute
+		vmContext.VSphereVM.Name = vm.Name
+		vmContext.VSphereVM.Spec.Server = s.URL.Host
+		authSession, err := vmContext.SessionProvider.GetOrCreate(
+			vmContext,
+			session.Params{
+				Server:   vmContext.VSphereVM.Spec.Server,
+				Username: s.URL.User.Username(),
+				Password: pass,
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		vmContext.Session = authSession
+		return vmContext
+	}
+	vms := simulator.Map.All("VirtualMachine")
+
+	t.Run("Shut down the guest", func(t *testing.T) {
+		vm := vms[0].(*simulator.VirtualMachine)
+		vmContext := newVMContext(t, vm)
+
+		if _, err := (&VMService{}).DestroyVM(vmContext); err != nil {
+			t.Fatal(err)
+		}
+		if vmContext.VSphereVM.Status.ShutdownStartTime == nil || vmContext.VSphereVM.Status.TaskRef != "" {
+			t.Fatal("Expected the guest to be shut down without a power off task")
+		}
+		if reason := conditions.GetReason(vmContext.VSphereVM, infrav1.VMProvisionedCondition); reason != infrav1.ShuttingDownGuestReason {
+			t.Fatalf("Expected reason %q, got %q", infrav1.ShuttingDownGuestReason, reason)
+		}
+		if vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOff {
+			t.Fatalf("Expected the VM to be powered off, got %q", vm.Runtime.PowerState)
+		}
+
+		// The VM is destroyed once the guest is shut down.
+		if _, err := (&VMService{}).DestroyVM(vmContext); err != nil {
+			t.Fatal(err)
+		}
+		waitForTask(t, vmContext)
+		if simulator.Map.Get(vm.Reference()) != nil {
+			t.Fatal("Expected the VM to be destroyed")
+		}
+	})
+
+	t.Run("Power off the VM once the shutdown timeout has elapsed", func(t *testing.T) {
+		vm := vms[1].(*simulator.VirtualMachine)
+		vmContext := newVMContext(t, vm)
+		start := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+		vmContext.VSphereVM.Status.ShutdownStartTime = &start
+
+		if _, err := (&VMService{}).DestroyVM(vmContext); err != nil {
+			t.Fatal(err)
+		}
+		if vmContext.VSphereVM.Status.TaskRef == "" {
+			t.Fatal("Expected the VM to be powered off")
+		}
+		waitForTask(t, vmContext)
+		if vm.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOff {
+			t.Fatalf("Expected the VM to be powered off, got %q", vm.Runtime.PowerState)
+		}
+	})
+
+	t.Run("Power off the VM with a zero shutdown timeout", func(t *testing.T) {
+		vm := vms[2].(*simulator.VirtualMachine)
+		vmContext := newVMContext(t, vm)
+		vmContext.VSphereVM.Spec.ShutdownTimeout = &metav1.Duration{}
+
+		if _, err := (&VMService{}).DestroyVM(vmContext); err != nil {
+			t.Fatal(err)
+		}
+		if vmContext.VSphereVM.Status.ShutdownStartTime != nil || vmContext.VSphereVM.Status.TaskRef == "" {
+			t.Fatal("Expected the VM to be powered off without shutting down the guest")
+		}
+	})
+}
+
+func TestReconcileVMCustomizesLibraryVM(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	defer model.Remove()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+
+	s := model.Service.NewServer()
+	defer s.Close()
+	pass, _ := s.URL.User.Password()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Server = s.URL.Host
+	authSession, err := vmContext.SessionProvider.GetOrCreate(
+		vmContext,
+		session.Params{
+			Server:   vmContext.VSphereVM.Spec.Server,
+			Username: s.URL.User.Username(),
+			Password: pass,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vmContext.Session = authSession
+
+	// Create a library with a VM template item.
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	restClient, err := authSession.RestClient(vmContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	libraryID, err := library.NewManager(restClient).CreateLibrary(vmContext, library.Library{
+		Name: "capv",
+		Type: "LOCAL",
+		Storage: []library.StorageBackings{{
+			DatastoreID: simulator.Map.Any("Datastore").Reference().Value,
+			Type:        "DATASTORE",
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vcenter.NewManager(restClient).CreateTemplate(vmContext, vcenter.Template{
+		Name:     "ubuntu",
+		Library:  libraryID,
+		SourceVM: vm.Reference().Value,
+		Placement: &vcenter.Placement{
+			Folder:       vm.Parent.Value,
+			ResourcePool: vm.ResourcePool.Value,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	vmContext.VSphereVM.Spec.ContentLibrary = libraryID
+	vmContext.VSphereVM.Spec.Template = "ubuntu"
+	vmContext.VSphereVM.Spec.NumCPUs = 4
+	vmContext.VSphereVM.Spec.DiskGiB = 1
+
+	// The simulator deploys VM templates as templates, which cannot be
+	// reconfigured, so the customization of the deployed VM fails.
+	if _, err := (&VMService{}).ReconcileVM(vmContext); err != nil {
+		t.Fatal(err)
+	}
+	if !vmContext.VSphereVM.Status.PendingCustomization || vmContext.VSphereVM.Status.TaskRef == "" {
+		t.Fatal("Expected the deployed VM to be pending customization")
+	}
+	task := object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: vmContext.VSphereVM.Status.TaskRef})
+	if err := task.Wait(vmContext); err == nil {
+		t.Fatal("Expected the customization of the deployed template to fail")
+	}
+	deployed, err := authSession.Finder.VirtualMachine(vmContext, vmContext.VSphereVM.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := authSession.Finder.DefaultResourcePool(vmContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := object.NewHostSystem(authSession.Client.Client, simulator.Map.Any("HostSystem").Reference())
+	if err := deployed.MarkAsVirtualMachine(vmContext, *pool, host); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next reconcile customizes the deployed VM rather than deploying
+	// another VM or powering on the VM as it was deployed.
+	machines := model.Count().Machine
+	if _, err := (&VMService{}).ReconcileVM(vmContext); err != nil {
+		t.Fatal(err)
+	}
+	if model.Count().Machine != machines {
+		t.Error("Expected the deployed VM to be reused")
+	}
+	if vmContext.VSphereVM.Status.TaskRef == "" {
+		t.Fatal("Expected the deployed VM to be customized")
+	}
+	waitForTask(t, vmContext)
+
+	var obj mo.VirtualMachine
+	if err := deployed.Properties(vmContext, deployed.Reference(), []string{"config", "runtime.powerState"}, &obj); err != nil {
+		t.Fatal(err)
+	}
+	if obj.Runtime.PowerState != types.VirtualMachinePowerStatePoweredOff {
+		t.Errorf("Expected the VM to be powered off until it is customized, got %q", obj.Runtime.PowerState)
+	}
+	if obj.Config.InstanceUuid != string(vmContext.VSphereVM.UID) || obj.Config.Hardware.NumCPU != 4 {
+		t.Errorf("Expected the VM to be customized, got instance UUID %q and %d CPUs", obj.Config.InstanceUuid, obj.Config.Hardware.NumCPU)
+	}
+
+	// The VM is no longer pending customization once it is customized.
+	if _, err := (&VMService{}).ReconcileVM(vmContext); err != nil {
+		t.Fatal(err)
+	}
+	if vmContext.VSphereVM.Status.PendingCustomization {
+		t.Error("Expected the VM to no longer be pending customization")
+	}
+}
+
+func TestReconcileTags(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0 // ClusterHost only
+
+	defer model.Remove()
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+
+	s := model.Service.NewServer()
+	defer s.Close()
+	pass, _ := s.URL.User.Password()
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Server = s.URL.Host
+	vmContext.VSphereVM.Spec.Tags = []infrav1.TagSpec{{Category: "backup", Name: "daily"}}
+	authSession, err := vmContext.SessionProvider.GetOrCreate(
+		vmContext,
+		session.Params{
+			Server:   vmContext.VSphereVM.Spec.Server,
+			Username: s.URL.User.Username(),
+			Password: pass,
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vmContext.Session = authSession
+	restClient, err := authSession.RestClient(vmContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagManager := vapitags.NewManager(restClient)
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	ctx := &virtualMachineContext{
+		VMContext: *vmContext,
+		Ref:       vm.Reference(),
+		Obj:       object.NewVirtualMachine(authSession.Client.Client, vm.Reference()),
+	}
+	attachedTags := func() int {
+		attached, err := tagManager.GetAttachedTags(ctx, ctx.Ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(attached)
+	}
+
+	if err := (&VMService{}).reconcileTags(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.VSphereVM.Status.Tags) != 3 || attachedTags() != 3 {
+		t.Fatalf("Expected the namespace, role and backup tags to be attached and recorded, got %v", ctx.VSphereVM.Status.Tags)
+	}
+
+	// The tags are not attached again while they match the recorded ones.
+	attached, err := tagManager.GetAttachedTags(ctx, ctx.Ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tagManager.DetachTag(ctx, attached[0].ID, ctx.Ref); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&VMService{}).reconcileTags(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if attachedTags() != 2 {
+		t.Error("Expected the tags to not be reconciled while they match the recorded tags")
+	}
+
+	// The tags are reconciled once they change.
+	ctx.VSphereVM.Spec.Tags = append(ctx.VSphereVM.Spec.Tags, infrav1.TagSpec{Category: "backup", Name: "weekly"})
+	if err := (&VMService{}).reconcileTags(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(ctx.VSphereVM.Status.Tags) != 4 || attachedTags() != 4 {
+		t.Errorf("Expected the changed tags to be attached and recorded, got %v", ctx.VSphereVM.Status.Tags)
+	}
+}
+
+func waitForTask(t *testing.T, ctx *context.VMContext) {
+	task := object.NewTask(ctx.Session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: ctx.VSphereVM.Status.TaskRef})
+	if err := task.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
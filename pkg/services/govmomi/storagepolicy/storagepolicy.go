@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepolicy
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/pbm"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+type policyContext interface {
+	context.Context
+	GetLogger() logr.Logger
+	GetSession() *session.Session
+}
+
+// ProfileID returns the ID of the storage policy with the provided name.
+func ProfileID(ctx policyContext, policyName string) (string, error) {
+	client, err := pbm.NewClient(ctx, ctx.GetSession().Client.Client)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to create storage policy client")
+	}
+	profileID, err := client.ProfileIDByName(ctx, policyName)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to find storage policy %q", policyName)
+	}
+	return profileID, nil
+}
+
+// SelectDatastore returns the datastore that is compatible with the storage
+// policy and has the most free space, among the datastores available to the
+// hosts of the compute resource that owns the resource pool.
+func SelectDatastore(ctx policyContext, profileID string, pool *object.ResourcePool) (*object.Datastore, error) {
+	refs, err := placementDatastores(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	compatible, err := compatibleDatastores(ctx, profileID, refs)
+	if err != nil {
+		return nil, err
+	}
+	if len(compatible) == 0 {
+		return nil, errors.Errorf("no datastore is compatible with storage policy %q", profileID)
+	}
+
+	var summaries []mo.Datastore
+	pc := property.DefaultCollector(ctx.GetSession().Client.Client)
+	if err := pc.Retrieve(ctx, compatible, []string{"summary"}, &summaries); err != nil {
+		return nil, errors.Wrap(err, "unable to get datastore summaries")
+	}
+	var selected *mo.Datastore
+	for i := range summaries {
+		summary := summaries[i].Summary
+		if !summary.Accessible {
+			continue
+		}
+		if selected == nil || summary.FreeSpace > selected.Summary.FreeSpace {
+			selected = &summaries[i]
+		}
+	}
+	if selected == nil {
+		return nil, errors.Errorf("no accessible datastore is compatible with storage policy %q", profileID)
+	}
+
+	ctx.GetLogger().V(4).Info("selected datastore for storage policy",
+		"profile-id", profileID, "datastore", selected.Summary.Name)
+	datastore := object.NewDatastore(ctx.GetSession().Client.Client, selected.Reference())
+	datastore.InventoryPath = selected.Summary.Name
+	return datastore, nil
+}
+
+// placementDatastores returns the datastores available to the hosts of the
+// compute resource that owns the resource pool.
+func placementDatastores(ctx policyContext, pool *object.ResourcePool) ([]types.ManagedObjectReference, error) {
+	pc := property.DefaultCollector(ctx.GetSession().Client.Client)
+
+	var rp mo.ResourcePool
+	if err := pc.RetrieveOne(ctx, pool.Reference(), []string{"owner"}, &rp); err != nil {
+		return nil, errors.Wrapf(err, "unable to get owner of resource pool %q", pool.InventoryPath)
+	}
+	var cr mo.ComputeResource
+	if err := pc.RetrieveOne(ctx, rp.Owner, []string{"datastore"}, &cr); err != nil {
+		return nil, errors.Wrapf(err, "unable to get datastores of compute resource %q", rp.Owner.Value)
+	}
+	return cr.Datastore, nil
+}
+
+// IncompatibleDatastores returns the datastores that are not compatible with
+// the storage policy.
+func IncompatibleDatastores(ctx policyContext, profileID string, datastores []types.ManagedObjectReference) ([]types.ManagedObjectReference, error) {
+	compatible, err := compatibleDatastores(ctx, profileID, datastores)
+	if err != nil {
+		return nil, err
+	}
+	isCompatible := map[types.ManagedObjectReference]bool{}
+	for _, ref := range compatible {
+		isCompatible[ref] = true
+	}
+	var incompatible []types.ManagedObjectReference
+	for _, ref := range datastores {
+		if !isCompatible[ref] {
+			incompatible = append(incompatible, ref)
+		}
+	}
+	return incompatible, nil
+}
+
+func compatibleDatastores(ctx policyContext, profileID string, datastores []types.ManagedObjectReference) ([]types.ManagedObjectReference, error) {
+	if len(datastores) == 0 {
+		return nil, nil
+	}
+	client, err := pbm.NewClient(ctx, ctx.GetSession().Client.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create storage policy client")
+	}
+
+	hubs := make([]pbmtypes.PbmPlacementHub, len(datastores))
+	for i, ref := range datastores {
+		hubs[i] = pbmtypes.PbmPlacementHub{HubType: ref.Type, HubId: ref.Value}
+	}
+	requirements := []pbmtypes.BasePbmPlacementRequirement{
+		&pbmtypes.PbmPlacementCapabilityProfileRequirement{
+			ProfileId: pbmtypes.PbmProfileId{UniqueId: profileID},
+		},
+	}
+	result, err := client.CheckRequirements(ctx, hubs, nil, requirements)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to check the requirements of storage policy %q", profileID)
+	}
+
+	// Only return the datastores that were asked about, since the result may
+	// include others.
+	requested := map[string]bool{}
+	for _, ref := range datastores {
+		requested[ref.Value] = true
+	}
+	var compatible []types.ManagedObjectReference
+	for _, hub := range result.CompatibleDatastores() {
+		if requested[hub.HubId] {
+			compatible = append(compatible, types.ManagedObjectReference{Type: hub.HubType, Value: hub.HubId})
+		}
+	}
+	return compatible, nil
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepolicy
+
+import (
+	"crypto/tls"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	_ "github.com/vmware/govmomi/pbm/simulator"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+const vsanDefaultStoragePolicy = "vSAN Default Storage Policy"
+
+func TestStoragePolicy(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0
+	model.Datastore = 2
+	g.Expect(model.Create()).To(Succeed())
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	defer server.Close()
+
+	ctx := newVMContext(t, server)
+
+	profileID, err := ProfileID(ctx, vsanDefaultStoragePolicy)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(profileID).NotTo(BeEmpty())
+
+	_, err = ProfileID(ctx, "missing")
+	g.Expect(err).To(MatchError(ContainSubstring(`unable to find storage policy "missing"`)))
+
+	pool, err := ctx.Session.Finder.DefaultResourcePool(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	datastore, err := SelectDatastore(ctx, profileID, pool)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(datastore.Name()).To(HavePrefix("LocalDS_"))
+
+	datastores := []types.ManagedObjectReference{datastore.Reference()}
+	incompatible, err := IncompatibleDatastores(ctx, profileID, datastores)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(incompatible).To(BeEmpty())
+
+	// A datastore that does not exist is not compatible.
+	datastores = append(datastores, types.ManagedObjectReference{Type: "Datastore", Value: "missing"})
+	incompatible, err = IncompatibleDatastores(ctx, profileID, datastores)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(incompatible).To(ConsistOf(datastores[1]))
+}
+
+func TestSelectDatastore(t *testing.T) {
+	g := NewWithT(t)
+
+	model := simulator.VPX()
+	model.Host = 0
+	model.Cluster = 2
+	g.Expect(model.Create()).To(Succeed())
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	defer server.Close()
+
+	ctx := newVMContext(t, server)
+	profileID, err := ProfileID(ctx, vsanDefaultStoragePolicy)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Add a datastore with the most free space to a host of the second
+	// cluster only.
+	hosts, err := ctx.Session.Finder.HostSystemList(ctx, "/DC0/host/DC0_C1/*")
+	g.Expect(err).NotTo(HaveOccurred())
+	dss, err := hosts[0].ConfigManager().DatastoreSystem(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	ds, err := dss.CreateLocalDatastore(ctx, "DC0_C1_DS", t.TempDir())
+	g.Expect(err).NotTo(HaveOccurred())
+	simulator.Map.Get(ds.Reference()).(*simulator.Datastore).Summary.FreeSpace = 1 << 50
+
+	for cluster, expected := range map[string]string{
+		"DC0_C0": "LocalDS_0",
+		"DC0_C1": "DC0_C1_DS",
+	} {
+		pool, err := ctx.Session.Finder.ResourcePool(ctx, "/DC0/host/"+cluster+"/Resources")
+		g.Expect(err).NotTo(HaveOccurred())
+		datastore, err := SelectDatastore(ctx, profileID, pool)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(datastore.Name()).To(Equal(expected), cluster)
+	}
+}
+
+func newVMContext(t *testing.T, server *simulator.Server) *context.VMContext {
+	ctx := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	password, _ := server.URL.User.Password()
+	s, err := ctx.SessionProvider.GetOrCreate(ctx, session.Params{
+		Server:   server.URL.Host,
+		Username: server.URL.User.Username(),
+		Password: password,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Session = s
+	return ctx
+}
@@ -0,0 +1,251 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tags attaches vSphere tags and sets custom attributes on VMs.
+package tags
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	vapitags "github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// The categories of the tags that are attached to every VM.
+const (
+	ClusterCategory   = "capv-cluster"
+	NamespaceCategory = "capv-namespace"
+	RoleCategory      = "capv-role"
+)
+
+// The names of the tags in the RoleCategory.
+const (
+	ControlPlaneRole = "control-plane"
+	WorkerRole       = "worker"
+)
+
+const (
+	virtualMachineType = "VirtualMachine"
+
+	singleCardinality   = "SINGLE"
+	multipleCardinality = "MULTIPLE"
+)
+
+// ForVM returns the tags of the VSphereVM, which are the tags of its cluster,
+// namespace and role followed by the tags in its spec.
+func ForVM(vm *infrav1.VSphereVM) []infrav1.TagSpec {
+	var tags []infrav1.TagSpec
+	if clusterName := vm.Labels[clusterv1.ClusterLabelName]; clusterName != "" {
+		tags = append(tags, infrav1.TagSpec{Category: ClusterCategory, Name: clusterName})
+	}
+	if vm.Namespace != "" {
+		tags = append(tags, infrav1.TagSpec{Category: NamespaceCategory, Name: vm.Namespace})
+	}
+	role := WorkerRole
+	if util.IsControlPlaneMachine(vm) {
+		role = ControlPlaneRole
+	}
+	tags = append(tags, infrav1.TagSpec{Category: RoleCategory, Name: role})
+	return append(tags, vm.Spec.Tags...)
+}
+
+// Reconcile ensures the tags are attached to the VM. Missing tags and
+// categories are created. Since a VM may only have one tag in a category
+// with a single cardinality, the VM's other tags in such a category are
+// detached.
+//
+// The categories of the tags that are attached to every VM have a single
+// cardinality, while any other category that is created has a multiple
+// cardinality.
+func Reconcile(ctx context.Context, s *session.Session, vm mo.Reference, tags []infrav1.TagSpec) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	restClient, err := s.RestClient(ctx)
+	if err != nil {
+		return err
+	}
+	manager := vapitags.NewManager(restClient)
+
+	attached, err := manager.GetAttachedTags(ctx, vm)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get tags of vm %s", vm.Reference().Value)
+	}
+	list, err := manager.GetCategories(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get tag categories")
+	}
+	categories := make(map[string]vapitags.Category, len(list))
+	for _, category := range list {
+		categories[category.Name] = category
+	}
+
+	for _, tag := range tags {
+		category, ok := categories[tag.Category]
+		if !ok {
+			created, err := createCategory(ctx, manager, tag.Category)
+			if err != nil {
+				return err
+			}
+			category = *created
+			categories[category.Name] = category
+		}
+
+		if isAttached(attached, category.ID, tag.Name) {
+			continue
+		}
+		if category.Cardinality == singleCardinality {
+			var remaining []vapitags.Tag
+			for _, other := range attached {
+				if other.CategoryID != category.ID {
+					remaining = append(remaining, other)
+					continue
+				}
+				if err := manager.DetachTag(ctx, other.ID, vm); err != nil {
+					return errors.Wrapf(err, "unable to detach tag %q in category %q from vm %s", other.Name, category.Name, vm.Reference().Value)
+				}
+			}
+			attached = remaining
+		}
+
+		tagID, err := getOrCreateTag(ctx, manager, category, tag.Name)
+		if err != nil {
+			return err
+		}
+		if err := manager.AttachTag(ctx, tagID, vm); err != nil {
+			return errors.Wrapf(err, "unable to attach tag %q in category %q to vm %s", tag.Name, category.Name, vm.Reference().Value)
+		}
+		attached = append(attached, vapitags.Tag{ID: tagID, Name: tag.Name, CategoryID: category.ID})
+	}
+	return nil
+}
+
+func isAttached(attached []vapitags.Tag, categoryID, name string) bool {
+	for _, tag := range attached {
+		if tag.CategoryID == categoryID && tag.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func createCategory(ctx context.Context, manager *vapitags.Manager, name string) (*vapitags.Category, error) {
+	category := &vapitags.Category{
+		Name:            name,
+		Cardinality:     multipleCardinality,
+		AssociableTypes: []string{virtualMachineType},
+	}
+	switch name {
+	case ClusterCategory, NamespaceCategory, RoleCategory:
+		category.Cardinality = singleCardinality
+	}
+	id, err := manager.CreateCategory(ctx, category)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create tag category %q", name)
+	}
+	category.ID = id
+	return category, nil
+}
+
+func getOrCreateTag(ctx context.Context, manager *vapitags.Manager, category vapitags.Category, name string) (string, error) {
+	tags, err := manager.GetTagsForCategory(ctx, category.ID)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get tags in category %q", category.Name)
+	}
+	for _, tag := range tags {
+		if tag.Name == name {
+			return tag.ID, nil
+		}
+	}
+	id, err := manager.CreateTag(ctx, &vapitags.Tag{Name: name, CategoryID: category.ID})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create tag %q in category %q", name, category.Name)
+	}
+	return id, nil
+}
+
+// ReconcileCustomAttributes ensures the custom attributes of the VM have the
+// values. Missing custom attributes are defined for VMs.
+func ReconcileCustomAttributes(ctx context.Context, s *session.Session, vm mo.Reference, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var obj mo.VirtualMachine
+	if err := property.DefaultCollector(s.Client.Client).RetrieveOne(ctx, vm.Reference(), []string{"customValue"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to get custom attributes of vm %s", vm.Reference().Value)
+	}
+	current := map[int32]string{}
+	for _, value := range obj.CustomValue {
+		if stringValue, ok := value.(*types.CustomFieldStringValue); ok {
+			current[stringValue.Key] = stringValue.Value
+		}
+	}
+
+	manager, err := object.GetCustomFieldsManager(s.Client.Client)
+	if err != nil {
+		return errors.Wrap(err, "unable to get custom attribute definitions")
+	}
+	fields, err := manager.Field(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to get custom attribute definitions")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key, ok := findField(fields, name)
+		if !ok {
+			field, err := manager.Add(ctx, name, virtualMachineType, nil, nil)
+			if err != nil {
+				return errors.Wrapf(err, "unable to define custom attribute %q", name)
+			}
+			key = field.Key
+		}
+		if value, ok := current[key]; ok && value == values[name] {
+			continue
+		}
+		if err := manager.Set(ctx, vm.Reference(), key, values[name]); err != nil {
+			return errors.Wrapf(err, "unable to set custom attribute %q on vm %s", name, vm.Reference().Value)
+		}
+	}
+	return nil
+}
+
+// findField returns the key of the custom attribute with the name that
+// applies to VMs.
+func findField(fields object.CustomFieldDefList, name string) (int32, bool) {
+	for _, field := range fields {
+		if field.Name == name && (field.ManagedObjectType == "" || field.ManagedObjectType == virtualMachineType) {
+			return field.Key, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tags
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	vapitags "github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestForVM(t *testing.T) {
+	g := NewWithT(t)
+
+	vm := &infrav1.VSphereVM{}
+	vm.Namespace = "default"
+	vm.Labels = map[string]string{
+		clusterv1.ClusterLabelName:             "cluster1",
+		clusterv1.MachineControlPlaneLabelName: "",
+	}
+	vm.Spec.Tags = []infrav1.TagSpec{{Category: "backup", Name: "daily"}}
+	g.Expect(ForVM(vm)).To(Equal([]infrav1.TagSpec{
+		{Category: ClusterCategory, Name: "cluster1"},
+		{Category: NamespaceCategory, Name: "default"},
+		{Category: RoleCategory, Name: ControlPlaneRole},
+		{Category: "backup", Name: "daily"},
+	}))
+
+	delete(vm.Labels, clusterv1.MachineControlPlaneLabelName)
+	g.Expect(ForVM(vm)).To(ContainElement(infrav1.TagSpec{Category: RoleCategory, Name: WorkerRole}))
+}
+
+func TestReconcile(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	s := newSession(t)
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine).Reference()
+	restClient, err := s.RestClient(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	manager := vapitags.NewManager(restClient)
+	attachedTags := func() map[string]string {
+		attached, err := manager.GetAttachedTags(ctx, vm)
+		g.Expect(err).NotTo(HaveOccurred())
+		tags := map[string]string{}
+		for _, tag := range attached {
+			category, err := manager.GetCategory(ctx, tag.CategoryID)
+			g.Expect(err).NotTo(HaveOccurred())
+			tags[category.Name+"/"+tag.Name] = category.Cardinality
+		}
+		return tags
+	}
+
+	// The missing categories and tags are created.
+	desired := []infrav1.TagSpec{
+		{Category: RoleCategory, Name: WorkerRole},
+		{Category: "backup", Name: "daily"},
+		{Category: "backup", Name: "weekly"},
+	}
+	g.Expect(Reconcile(ctx, s, vm, desired)).To(Succeed())
+	g.Expect(attachedTags()).To(Equal(map[string]string{
+		RoleCategory + "/" + WorkerRole: singleCardinality,
+		"backup/daily":                  multipleCardinality,
+		"backup/weekly":                 multipleCardinality,
+	}))
+
+	// Detached tags are attached again, and the tag in a category with a
+	// single cardinality is replaced.
+	attached, err := manager.GetAttachedTags(ctx, vm)
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, tag := range attached {
+		if tag.Name == "daily" {
+			g.Expect(manager.DetachTag(ctx, tag.ID, vm)).To(Succeed())
+		}
+	}
+	desired[0].Name = ControlPlaneRole
+	g.Expect(Reconcile(ctx, s, vm, desired)).To(Succeed())
+	g.Expect(attachedTags()).To(Equal(map[string]string{
+		RoleCategory + "/" + ControlPlaneRole: singleCardinality,
+		"backup/daily":                        multipleCardinality,
+		"backup/weekly":                       multipleCardinality,
+	}))
+
+	// Reconciling the tags again does not change them.
+	g.Expect(Reconcile(ctx, s, vm, desired)).To(Succeed())
+	g.Expect(attachedTags()).To(HaveLen(3))
+}
+
+func TestReconcileCustomAttributes(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	s := newSession(t)
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine).Reference()
+	manager, err := object.GetCustomFieldsManager(s.Client.Client)
+	g.Expect(err).NotTo(HaveOccurred())
+	owner, err := manager.Add(ctx, "owner", "VirtualMachine", nil, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(manager.Set(ctx, vm, owner.Key, "team-a")).To(Succeed())
+
+	customAttributes := func() map[string]string {
+		var obj mo.VirtualMachine
+		g.Expect(object.NewVirtualMachine(s.Client.Client, vm).Properties(ctx, vm, []string{"customValue"}, &obj)).To(Succeed())
+		fields, err := manager.Field(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		values := map[string]string{}
+		for _, value := range obj.CustomValue {
+			values[fields.ByKey(value.GetCustomFieldValue().Key).Name] = value.(*types.CustomFieldStringValue).Value
+		}
+		return values
+	}
+
+	// The existing custom attribute is updated and the missing one defined.
+	values := map[string]string{"owner": "team-b", "cost-center": "1234"}
+	g.Expect(ReconcileCustomAttributes(ctx, s, vm, values)).To(Succeed())
+	g.Expect(customAttributes()).To(Equal(values))
+	g.Expect(ReconcileCustomAttributes(ctx, s, vm, values)).To(Succeed())
+	g.Expect(customAttributes()).To(Equal(values))
+}
+
+func newSession(t *testing.T) *session.Session {
+	model := simulator.VPX()
+	model.Host = 0
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(model.Remove)
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(server.Close)
+
+	pass, _ := server.URL.User.Password()
+	s, err := session.NewManager(session.ManagerOptions{}).GetOrCreate(context.Background(), session.Params{
+		Server:   server.URL.Host,
+		Username: server.URL.User.Username(),
+		Password: pass,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// NotFoundError is returned by Resolve when a TemplateSelector does not
+// select exactly one template.
+type NotFoundError struct {
+	msg string
+}
+
+func (e *NotFoundError) Error() string {
+	return e.msg
+}
+
+// Resolve returns the inventory path of the template in the session's
+// datacenter that is selected by the TemplateSelector for the Kubernetes
+// version. A *NotFoundError is returned if no template, or more than one
+// template, is selected.
+func Resolve(ctx context.Context, s *session.Session, selector *infrav1.TemplateSelector, version string) (string, error) {
+	labels := make(map[string]string, len(selector.MatchLabels)+1)
+	for k, v := range selector.MatchLabels {
+		labels[k] = v
+	}
+	versionKey := selector.VersionKey
+	if versionKey == "" {
+		versionKey = infrav1.DefaultTemplateVersionKey
+	}
+	if version != "" {
+		labels[versionKey] = version
+	}
+
+	templates, err := getTemplates(ctx, s)
+	if err != nil {
+		return "", err
+	}
+	templateLabels, err := getLabels(ctx, s, templates)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, tpl := range templates {
+		if !matchLabels(templateLabels[tpl.Reference()], labels, versionKey) {
+			continue
+		}
+		e, err := s.Finder.Element(ctx, tpl.Reference())
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to get inventory path of template %q", tpl.Name)
+		}
+		matches = append(matches, e.Path)
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return "", &NotFoundError{msg: "no template matches " + formatLabels(labels)}
+	default:
+		sort.Strings(matches)
+		return "", &NotFoundError{msg: "templates " + strings.Join(matches, ", ") + " all match " + formatLabels(labels)}
+	}
+}
+
+// getTemplates returns the templates in the session's datacenter along with
+// their custom attributes.
+func getTemplates(ctx context.Context, s *session.Session) ([]mo.VirtualMachine, error) {
+	folder, err := s.Finder.DefaultFolder(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get virtual machine folder")
+	}
+	v, err := view.NewManager(s.Client.Client).CreateContainerView(ctx, folder.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create virtual machine view")
+	}
+	defer func() {
+		_ = v.Destroy(ctx)
+	}()
+
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name", "config.template", "customValue"}, &vms); err != nil {
+		return nil, errors.Wrap(err, "unable to list virtual machines")
+	}
+	var templates []mo.VirtualMachine
+	for _, vm := range vms {
+		if vm.Config != nil && vm.Config.Template {
+			templates = append(templates, vm)
+		}
+	}
+	return templates, nil
+}
+
+// getLabels returns the labels of each template, which are the names of the
+// categories of its tags mapped to the names of the tags, and the names of
+// its custom attributes mapped to their values.
+func getLabels(ctx context.Context, s *session.Session, templates []mo.VirtualMachine) (map[types.ManagedObjectReference]map[string]string, error) {
+	labels := map[types.ManagedObjectReference]map[string]string{}
+	if len(templates) == 0 {
+		return labels, nil
+	}
+
+	// Custom attributes.
+	var fields object.CustomFieldDefList
+	for _, tpl := range templates {
+		labels[tpl.Reference()] = map[string]string{}
+		if len(tpl.CustomValue) == 0 {
+			continue
+		}
+		if fields == nil {
+			fieldsManager, err := object.GetCustomFieldsManager(s.Client.Client)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to get custom attribute definitions")
+			}
+			if fields, err = fieldsManager.Field(ctx); err != nil {
+				return nil, errors.Wrap(err, "unable to get custom attribute definitions")
+			}
+		}
+		for _, value := range tpl.CustomValue {
+			stringValue, ok := value.(*types.CustomFieldStringValue)
+			if !ok {
+				continue
+			}
+			if field := fields.ByKey(stringValue.Key); field != nil {
+				labels[tpl.Reference()][field.Name] = stringValue.Value
+			}
+		}
+	}
+
+	// Tags.
+	restClient, err := s.RestClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tagManager := tags.NewManager(restClient)
+	objects := make([]mo.Reference, 0, len(templates))
+	for _, tpl := range templates {
+		objects = append(objects, tpl.Reference())
+	}
+	attachedTags, err := tagManager.GetAttachedTagsOnObjects(ctx, objects)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get template tags")
+	}
+	categories := map[string]string{}
+	for _, attached := range attachedTags {
+		for _, tag := range attached.Tags {
+			category, ok := categories[tag.CategoryID]
+			if !ok {
+				c, err := tagManager.GetCategory(ctx, tag.CategoryID)
+				if err != nil {
+					return nil, errors.Wrapf(err, "unable to get category of tag %q", tag.Name)
+				}
+				category = c.Name
+				categories[tag.CategoryID] = category
+			}
+			if l, ok := labels[attached.ObjectID.Reference()]; ok {
+				l[category] = tag.Name
+			}
+		}
+	}
+
+	return labels, nil
+}
+
+// matchLabels returns whether the template has all of the labels. The
+// leading "v" of the versions is ignored.
+func matchLabels(templateLabels, labels map[string]string, versionKey string) bool {
+	for k, v := range labels {
+		tv, ok := templateLabels[k]
+		if !ok {
+			return false
+		}
+		if k == versionKey {
+			tv, v = strings.TrimPrefix(tv, "v"), strings.TrimPrefix(v, "v")
+		}
+		if tv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
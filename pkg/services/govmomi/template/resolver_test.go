@@ -0,0 +1,120 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package template
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vapi/tags"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestResolve(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+
+	model := simulator.VPX()
+	model.Host = 0
+	model.Machine = 4
+	g.Expect(model.Create()).To(Succeed())
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	defer server.Close()
+
+	pass, _ := server.URL.User.Password()
+	s, err := session.NewManager(session.ManagerOptions{}).GetOrCreate(ctx, session.Params{
+		Server:   server.URL.Host,
+		Username: server.URL.User.Username(),
+		Password: pass,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	vms, err := s.Finder.VirtualMachineList(ctx, "DC0_C0_RP0_VM*")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(vms).To(HaveLen(4))
+	for _, vm := range vms[:3] {
+		task, err := vm.PowerOff(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(task.Wait(ctx)).To(Succeed())
+		g.Expect(vm.MarkAsTemplate(ctx)).To(Succeed())
+	}
+
+	// The first two templates are tagged, the third has custom attributes
+	// and the last VM is not a template.
+	restClient, err := s.RestClient(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	tagManager := tags.NewManager(restClient)
+	categories := map[string]string{}
+	for _, category := range []string{"os", "k8s-version"} {
+		categories[category], err = tagManager.CreateCategory(ctx, &tags.Category{Name: category, Cardinality: "SINGLE"})
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	tagIDs := map[string]string{}
+	attachTag := func(vm *object.VirtualMachine, category, tag string) {
+		tagID, ok := tagIDs[category+"/"+tag]
+		if !ok {
+			tagID, err = tagManager.CreateTag(ctx, &tags.Tag{Name: tag, CategoryID: categories[category]})
+			g.Expect(err).NotTo(HaveOccurred())
+			tagIDs[category+"/"+tag] = tagID
+		}
+		g.Expect(tagManager.AttachTag(ctx, tagID, vm.Reference())).To(Succeed())
+	}
+	attachTag(vms[0], "os", "ubuntu-2004")
+	attachTag(vms[0], "k8s-version", "v1.18.2")
+	attachTag(vms[1], "os", "ubuntu-2004")
+	attachTag(vms[1], "k8s-version", "v1.19.1")
+	attachTag(vms[3], "os", "ubuntu-2004")
+	attachTag(vms[3], "k8s-version", "v1.20.0")
+
+	fieldsManager, err := object.GetCustomFieldsManager(s.Client.Client)
+	g.Expect(err).NotTo(HaveOccurred())
+	for name, value := range map[string]string{"os": "photon-3", "kubernetes": "1.19.1"} {
+		field, err := fieldsManager.Add(ctx, name, "VirtualMachine", nil, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(fieldsManager.Set(ctx, vms[2].Reference(), field.Key, value)).To(Succeed())
+	}
+
+	resolve := func(selector infrav1.TemplateSelector, version string) (string, error) {
+		return Resolve(ctx, s, &selector, version)
+	}
+
+	// Templates are selected by tags.
+	g.Expect(resolve(infrav1.TemplateSelector{MatchLabels: map[string]string{"os": "ubuntu-2004"}}, "v1.19.1")).To(Equal(vms[1].InventoryPath))
+	g.Expect(resolve(infrav1.TemplateSelector{MatchLabels: map[string]string{"os": "ubuntu-2004"}}, "1.18.2")).To(Equal(vms[0].InventoryPath))
+
+	// Templates are selected by custom attributes.
+	g.Expect(resolve(infrav1.TemplateSelector{MatchLabels: map[string]string{"os": "photon-3"}, VersionKey: "kubernetes"}, "v1.19.1")).To(Equal(vms[2].InventoryPath))
+
+	// VMs that are not templates are not selected.
+	_, err = resolve(infrav1.TemplateSelector{MatchLabels: map[string]string{"os": "ubuntu-2004"}}, "v1.20.0")
+	g.Expect(err).To(BeAssignableToTypeOf(&NotFoundError{}))
+	g.Expect(err.Error()).To(Equal("no template matches k8s-version=v1.20.0,os=ubuntu-2004"))
+
+	// A selector that matches several templates is ambiguous.
+	_, err = resolve(infrav1.TemplateSelector{MatchLabels: map[string]string{"os": "ubuntu-2004"}}, "")
+	g.Expect(err).To(BeAssignableToTypeOf(&NotFoundError{}))
+}
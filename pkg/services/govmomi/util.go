@@ -118,6 +118,7 @@ func reconcileInFlightTask(ctx *context.VMContext) (bool, error) {
 	// If no task was found then make sure to clear the VSphereVM
 	// resource's Status.TaskRef field.
 	if task == nil {
+		ctx.Session.FinishTask(ctx.VSphereVM.Status.TaskRef)
 		ctx.VSphereVM.Status.TaskRef = ""
 		return false, nil
 	}
@@ -134,7 +135,12 @@ func reconcileInFlightTask(ctx *context.VMContext) (bool, error) {
 		return true, nil
 	case types.TaskInfoStateSuccess:
 		logger.Info("task is a success", "description-id", task.Info.DescriptionId)
+		ctx.Session.FinishTask(ctx.VSphereVM.Status.TaskRef)
 		ctx.VSphereVM.Status.TaskRef = ""
+
+		// The only task of a VM that is pending customization is the
+		// reconfiguration that customizes it.
+		ctx.VSphereVM.Status.PendingCustomization = false
 		return false, nil
 	case types.TaskInfoStateError:
 		logger.Info("task failed", "description-id", task.Info.DescriptionId)
@@ -147,6 +153,7 @@ func reconcileInFlightTask(ctx *context.VMContext) (bool, error) {
 			description = task.Info.Description.Message
 		}
 		conditions.MarkFalse(ctx.VSphereVM, infrav1.VMProvisionedCondition, infrav1.TaskFailure, clusterv1.ConditionSeverityInfo, description)
+		ctx.Session.FinishTask(ctx.VSphereVM.Status.TaskRef)
 		ctx.VSphereVM.Status.TaskRef = ""
 		return false, nil
 	default:
@@ -253,8 +260,11 @@ func reconcileVSphereVMOnFuncCompletion(
 	obj := ctx.VSphereVM.DeepCopy()
 	gvk := obj.GetObjectKind().GroupVersionKind()
 
-	// Wait on the function to complete in a background goroutine.
+	// Wait on the function to complete in a background goroutine, which
+	// holds the session until it is done.
+	ctx.Session.Retain()
 	go func() {
+		defer ctx.Session.Release()
 		loggerKeysAndValues, err := waitFn()
 		if err != nil {
 			ctx.Logger.Error(err, "failed to wait on func")
@@ -281,8 +291,10 @@ func reconcileVSphereVMOnChannel(
 	gvk := obj.GetObjectKind().GroupVersionKind()
 
 	// Send a generic event for every set of logger keys/values received
-	// on the channel.
+	// on the channel. The goroutine holds the session until it is done.
+	ctx.Session.Retain()
 	go func() {
+		defer ctx.Session.Release()
 		chanOfLoggerKeysAndValues, chanErrs, err := waitFn()
 		if err != nil {
 			ctx.Logger.Error(err, "failed to wait on func")
@@ -0,0 +1,135 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vapp sets the values of the vApp properties of VMs, which are
+// provided to their guests in the OVF environment.
+package vapp
+
+import (
+	"encoding/base64"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/yaml"
+)
+
+// The IDs of the vApp properties read by the OVF datasource of cloud-init.
+const (
+	UserDataProperty      = "user-data"
+	InstanceIDProperty    = "instance-id"
+	HostnameProperty      = "local-hostname"
+	NetworkConfigProperty = "network-config"
+)
+
+// MetadataProperties are the IDs of the vApp properties that provide the
+// metadata. Since cloud-init does not require them, they are only set if the
+// VM has them.
+var MetadataProperties = []string{InstanceIDProperty, HostnameProperty, NetworkConfigProperty}
+
+// guestInfoTransport is the OVF environment transport through which VMware
+// Tools provides the OVF environment to the guest.
+const guestInfoTransport = "com.vmware.guestInfo"
+
+// BootstrapProperties returns the values of the vApp properties that provide
+// the bootstrap data, if any, and the metadata rendered by
+// util.GetMachineMetadata. The user data and network configuration are
+// base64-encoded.
+func BootstrapProperties(bootstrapData, metadata []byte) (map[string]string, error) {
+	var parsed struct {
+		InstanceID    string      `json:"instance-id,omitempty"`
+		LocalHostname string      `json:"local-hostname,omitempty"`
+		Network       interface{} `json:"network,omitempty"`
+	}
+	if err := yaml.Unmarshal(metadata, &parsed); err != nil {
+		return nil, errors.Wrap(err, "unable to parse metadata")
+	}
+
+	values := map[string]string{
+		InstanceIDProperty: parsed.InstanceID,
+		HostnameProperty:   parsed.LocalHostname,
+	}
+	if parsed.Network != nil {
+		networkConfig, err := yaml.Marshal(parsed.Network)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to render network config")
+		}
+		values[NetworkConfigProperty] = base64.StdEncoding.EncodeToString(networkConfig)
+	}
+	if len(bootstrapData) > 0 {
+		values[UserDataProperty] = base64.StdEncoding.EncodeToString(bootstrapData)
+	}
+	return values, nil
+}
+
+// ConfigSpec returns the spec that sets the values of the vApp properties of
+// a VM with the provided vApp config, or nil if the properties already have
+// the values. It is an error if the VM does not have one of the properties,
+// unless the property is optional.
+//
+// The spec also provides the OVF environment to the guest through VMware
+// Tools if the VM has no OVF environment transport, since the guest would
+// otherwise not see the values.
+func ConfigSpec(info *types.VmConfigInfo, values map[string]string, optional ...string) (*types.VmConfigSpec, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	properties := map[string]types.VAppPropertyInfo{}
+	if info != nil {
+		for _, property := range info.Property {
+			properties[property.Id] = property
+		}
+	}
+	isOptional := map[string]bool{}
+	for _, id := range optional {
+		isOptional[id] = true
+	}
+
+	ids := make([]string, 0, len(values))
+	for id := range values {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var propertySpecs []types.VAppPropertySpec
+	for _, id := range ids {
+		property, ok := properties[id]
+		if !ok {
+			if isOptional[id] {
+				continue
+			}
+			return nil, errors.Errorf("the VM has no vApp property %q", id)
+		}
+		if property.Value == values[id] {
+			continue
+		}
+		property.Value = values[id]
+		propertySpecs = append(propertySpecs, types.VAppPropertySpec{
+			ArrayUpdateSpec: types.ArrayUpdateSpec{Operation: types.ArrayUpdateOperationEdit},
+			Info:            &property,
+		})
+	}
+	if len(propertySpecs) == 0 {
+		return nil, nil
+	}
+
+	spec := &types.VmConfigSpec{Property: propertySpecs}
+	if len(info.OvfEnvironmentTransport) == 0 {
+		spec.OvfEnvironmentTransport = []string{guestInfoTransport}
+	}
+	return spec, nil
+}
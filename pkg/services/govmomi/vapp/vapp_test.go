@@ -0,0 +1,108 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vapp
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+const testMetadata = `instance-id: "vm"
+local-hostname: "vm"
+network:
+  version: 2
+  ethernets:
+    id0:
+      dhcp4: true
+`
+
+func TestBootstrapProperties(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	values, err := BootstrapProperties([]byte("#cloud-config"), []byte(testMetadata))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(values).To(gomega.HaveLen(4))
+	g.Expect(values[InstanceIDProperty]).To(gomega.Equal("vm"))
+	g.Expect(values[HostnameProperty]).To(gomega.Equal("vm"))
+	g.Expect(values[UserDataProperty]).To(gomega.Equal(base64.StdEncoding.EncodeToString([]byte("#cloud-config"))))
+	networkConfig, err := base64.StdEncoding.DecodeString(values[NetworkConfigProperty])
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(string(networkConfig)).To(gomega.Equal("ethernets:\n  id0:\n    dhcp4: true\nversion: 2\n"))
+
+	// The user data is only set if there is bootstrap data.
+	values, err = BootstrapProperties(nil, []byte(testMetadata))
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(values).NotTo(gomega.HaveKey(UserDataProperty))
+}
+
+func TestConfigSpec(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	info := &types.VmConfigInfo{
+		Property: []types.VAppPropertyInfo{
+			{Key: 0, Id: "hostname", Value: "old"},
+			{Key: 1, Id: UserDataProperty},
+			{Key: 2, Id: InstanceIDProperty, Value: "vm"},
+		},
+	}
+
+	t.Run("sets the changed properties", func(t *testing.T) {
+		spec, err := ConfigSpec(info, map[string]string{
+			"hostname":         "new",
+			UserDataProperty:   "dXNlci1kYXRh",
+			InstanceIDProperty: "vm",
+		})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(spec).NotTo(gomega.BeNil())
+		g.Expect(spec.Property).To(gomega.HaveLen(2))
+		for _, property := range spec.Property {
+			g.Expect(property.Operation).To(gomega.Equal(types.ArrayUpdateOperationEdit))
+		}
+		g.Expect(spec.Property[0].Info.Key).To(gomega.Equal(int32(0)))
+		g.Expect(spec.Property[0].Info.Value).To(gomega.Equal("new"))
+		g.Expect(spec.Property[1].Info.Key).To(gomega.Equal(int32(1)))
+		g.Expect(spec.Property[1].Info.Value).To(gomega.Equal("dXNlci1kYXRh"))
+		g.Expect(spec.OvfEnvironmentTransport).To(gomega.ConsistOf("com.vmware.guestInfo"))
+		g.Expect(info.Property[0].Value).To(gomega.Equal("old"))
+	})
+
+	t.Run("keeps the OVF environment transport", func(t *testing.T) {
+		info := &types.VmConfigInfo{
+			Property:                info.Property,
+			OvfEnvironmentTransport: []string{"iso"},
+		}
+		spec, err := ConfigSpec(info, map[string]string{"hostname": "new"})
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(spec.OvfEnvironmentTransport).To(gomega.BeEmpty())
+	})
+
+	t.Run("returns nil if the properties are up to date", func(t *testing.T) {
+		spec, err := ConfigSpec(info, map[string]string{"hostname": "old", NetworkConfigProperty: "bmV0d29yaw=="}, MetadataProperties...)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+		g.Expect(spec).To(gomega.BeNil())
+	})
+
+	t.Run("requires properties that are not optional", func(t *testing.T) {
+		_, err := ConfigSpec(info, map[string]string{NetworkConfigProperty: "bmV0d29yaw=="})
+		g.Expect(err).To(gomega.HaveOccurred())
+		_, err = ConfigSpec(nil, map[string]string{"hostname": "new"})
+		g.Expect(err).To(gomega.HaveOccurred())
+	})
+}
@@ -17,15 +17,23 @@ limitations under the License.
 package vcenter
 
 import (
+	"fmt"
+
 	"github.com/pkg/errors"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/nocloud"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/storagepolicy"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/template"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/vapp"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
 )
 
 const (
@@ -35,7 +43,7 @@ const (
 
 // Clone kicks off a clone operation on vCenter to create a new virtual machine.
 // nolint:gocognit
-func Clone(ctx *context.VMContext, bootstrapData []byte) error {
+func Clone(ctx *context.VMContext, bootstrapData []byte, format infrav1.BootstrapFormat) error {
 	ctx = &context.VMContext{
 		ControllerContext: ctx.ControllerContext,
 		VSphereVM:         ctx.VSphereVM,
@@ -45,18 +53,13 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	}
 	ctx.Logger.Info("starting clone process")
 
-	var extraConfig extra.Config
-	if len(bootstrapData) > 0 {
-		ctx.Logger.Info("applied bootstrap data to VM clone spec")
-		if err := extraConfig.SetCloudInitUserData(bootstrapData); err != nil {
-			return err
-		}
+	extraConfig, err := BootstrapExtraConfig(ctx, bootstrapData, format)
+	if err != nil {
+		return err
 	}
-	if ctx.VSphereVM.Spec.CustomVMXKeys != nil {
-		ctx.Logger.Info("applied custom vmx keys o VM clone spec")
-		if err := extraConfig.SetCustomVMXKeys(ctx.VSphereVM.Spec.CustomVMXKeys); err != nil {
-			return err
-		}
+
+	if ctx.VSphereVM.Spec.ContentLibrary != "" {
+		return deployLibraryItem(ctx, extraConfig, bootstrapData)
 	}
 
 	tpl, err := template.FindTemplate(ctx, ctx.VSphereVM.Spec.Template)
@@ -64,14 +67,39 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 		return err
 	}
 
+	if ctx.VSphereVM.Spec.CloneMode == infrav1.InstantClone {
+		return instantClone(ctx, tpl, extraConfig, format)
+	}
+
+	folder, pool, storageProfileID, err := getPlacement(ctx)
+	if err != nil {
+		return err
+	}
+
+	datastore, err := getDatastore(ctx, tpl, folder, pool, storageProfileID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datastore for %q", ctx)
+	}
+	ctx.VSphereVM.Status.Datastore = datastore.Name()
+
 	// If a linked clone is requested then a MoRef for a snapshot must be
 	// found with which to perform the linked clone.
 	var snapshotRef *types.ManagedObjectReference
-	if ctx.VSphereVM.Spec.CloneMode == "" || ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone {
+	linkedClone := ctx.VSphereVM.Spec.CloneMode == "" || ctx.VSphereVM.Spec.CloneMode == infrav1.LinkedClone
+	if linkedClone {
 		ctx.Logger.Info("linked clone requested")
-		// If the name of a snapshot was not provided then find the template's
-		// current snapshot.
-		if snapshotName := ctx.VSphereVM.Spec.Snapshot; snapshotName == "" {
+		if ctx.VSphereVM.Spec.ManageSnapshot {
+			// The managed snapshot is of a base VM on the datastore, which is
+			// then cloned rather than the template. The VM is cloned once the
+			// base VM and its snapshot are created.
+			source, ref, ready, err := getManagedSnapshot(ctx, tpl, pool, datastore)
+			if err != nil || !ready {
+				return err
+			}
+			tpl, snapshotRef = source, ref
+		} else if snapshotName := ctx.VSphereVM.Spec.Snapshot; snapshotName == "" {
+			// If the name of a snapshot was not provided then find the
+			// template's current snapshot.
 			ctx.Logger.Info("searching for current snapshot")
 			var vm mo.VirtualMachine
 			if err := tpl.Properties(ctx, tpl.Reference(), []string{"snapshot"}, &vm); err != nil {
@@ -100,72 +128,46 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 		ctx.VSphereVM.Status.CloneMode = infrav1.LinkedClone
 		ctx.VSphereVM.Status.Snapshot = snapshotRef.Value
 		diskMoveType = linkCloneDiskMoveType
+		conditions.MarkTrue(ctx.VSphereVM, infrav1.LinkedCloneCondition)
+	} else if linkedClone {
+		msg := fmt.Sprintf("template %s has no snapshot from which to create a linked clone, the VM is a full clone", ctx.VSphereVM.Spec.Template)
+		if ctx.VSphereVM.Spec.Snapshot != "" {
+			msg = fmt.Sprintf("template %s has no snapshot %s from which to create a linked clone, the VM is a full clone", ctx.VSphereVM.Spec.Template, ctx.VSphereVM.Spec.Snapshot)
+		}
+		ctx.Logger.Info(msg)
+		ctx.Recorder.Warn(ctx.VSphereVM, infrav1.LinkedCloneFallbackReason, msg)
+		conditions.MarkFalse(ctx.VSphereVM, infrav1.LinkedCloneCondition, infrav1.LinkedCloneFallbackReason, clusterv1.ConditionSeverityWarning, msg)
 	}
 
-	folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	devices, err := tpl.Device(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "unable to get folder for %q", ctx)
+		return errors.Wrapf(err, "error getting devices for %q", ctx)
 	}
 
-	datastore, err := ctx.Session.Finder.DatastoreOrDefault(ctx, ctx.VSphereVM.Spec.Datastore)
+	// Only non-linked clones may expand the size of the template's disk.
+	configSpec, err := getConfigSpec(ctx, devices, extraConfig, datastore, storageProfileID, snapshotRef == nil)
 	if err != nil {
-		return errors.Wrapf(err, "unable to get datastore for %q", ctx)
+		return err
 	}
 
-	pool, err := ctx.Session.Finder.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+	vAppConfig, err := getVAppConfigSpec(ctx, tpl, bootstrapData)
 	if err != nil {
-		return errors.Wrapf(err, "unable to get resource pool for %q", ctx)
+		return errors.Wrapf(err, "error getting vApp config spec for %q", ctx)
 	}
-
-	devices, err := tpl.Device(ctx)
-	if err != nil {
-		return errors.Wrapf(err, "error getting devices for %q", ctx)
+	if vAppConfig != nil {
+		configSpec.VAppConfig = vAppConfig
 	}
 
-	// Create a new list of device specs for cloning the VM.
-	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
-
-	// Only non-linked clones may expand the size of the template's disk.
-	if snapshotRef == nil {
-		diskSpec, err := getDiskSpec(ctx, devices)
+	if ctx.VSphereVM.Spec.BootstrapTransport == infrav1.NoCloudBootstrapTransport {
+		isoSpec, err := getBootstrapISOSpec(ctx, devices, datastore, bootstrapData)
 		if err != nil {
-			return errors.Wrapf(err, "error getting disk spec for %q", ctx)
+			return errors.Wrapf(err, "error getting bootstrap ISO spec for %q", ctx)
 		}
-		deviceSpecs = append(deviceSpecs, diskSpec)
-	}
-
-	networkSpecs, err := getNetworkSpecs(ctx, devices)
-	if err != nil {
-		return errors.Wrapf(err, "error getting network specs for %q", ctx)
-	}
-	deviceSpecs = append(deviceSpecs, networkSpecs...)
-
-	numCPUs := ctx.VSphereVM.Spec.NumCPUs
-	if numCPUs < 2 {
-		numCPUs = 2
-	}
-	numCoresPerSocket := ctx.VSphereVM.Spec.NumCoresPerSocket
-	if numCoresPerSocket == 0 {
-		numCoresPerSocket = numCPUs
-	}
-	memMiB := ctx.VSphereVM.Spec.MemoryMiB
-	if memMiB == 0 {
-		memMiB = 2048
+		configSpec.DeviceChange = append(configSpec.DeviceChange, isoSpec)
 	}
 
 	spec := types.VirtualMachineCloneSpec{
-		Config: &types.VirtualMachineConfigSpec{
-			// Assign the clone's InstanceUUID the value of the Kubernetes Machine
-			// object's UID. This allows lookup of the cloned VM prior to knowing
-			// the VM's UUID.
-			InstanceUuid:      string(ctx.VSphereVM.UID),
-			Flags:             newVMFlagInfo(),
-			DeviceChange:      deviceSpecs,
-			ExtraConfig:       extraConfig,
-			NumCPUs:           numCPUs,
-			NumCoresPerSocket: numCoresPerSocket,
-			MemoryMB:          memMiB,
-		},
+		Config: configSpec,
 		Location: types.VirtualMachineRelocateSpec{
 			Datastore:    types.NewReference(datastore.Reference()),
 			DiskMoveType: string(diskMoveType),
@@ -180,6 +182,15 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 		Snapshot: snapshotRef,
 	}
 
+	if storageProfileID != "" {
+		spec.Location.Profile = getStorageProfileSpec(storageProfileID)
+	}
+
+	// Only non-linked clones may change the backing of the template's disks.
+	if snapshotRef == nil {
+		spec.Location.Disk = getDiskLocators(ctx, devices, datastore, storageProfileID)
+	}
+
 	ctx.Logger.Info("cloning machine", "namespace", ctx.VSphereVM.Namespace, "name", ctx.VSphereVM.Name, "cloneType", ctx.VSphereVM.Status.CloneMode)
 	task, err := tpl.Clone(ctx, folder, ctx.VSphereVM.Name, spec)
 	if err != nil {
@@ -197,6 +208,310 @@ func Clone(ctx *context.VMContext, bootstrapData []byte) error {
 	return nil
 }
 
+// BootstrapExtraConfig returns the extra config of a new VM, which holds the
+// bootstrap data of a VM that uses the guestinfo bootstrap transport as well
+// as the VM's custom VMX keys. VMs that use the NoCloud or vApp bootstrap
+// transports are provided with their bootstrap data on an ISO or as vApp
+// properties rather than as extra config.
+func BootstrapExtraConfig(ctx *context.VMContext, bootstrapData []byte, format infrav1.BootstrapFormat) (extra.Config, error) {
+	transport := ctx.VSphereVM.Spec.BootstrapTransport
+	guestInfo := transport == "" || transport == infrav1.GuestInfoBootstrapTransport
+	if !guestInfo && format == infrav1.IgnitionBootstrapFormat {
+		return nil, errors.Errorf("the %s bootstrap transport does not support %s bootstrap data", transport, format)
+	}
+
+	var extraConfig extra.Config
+	if len(bootstrapData) > 0 && guestInfo {
+		ctx.Logger.Info("applied bootstrap data to VM clone spec", "format", format)
+		switch format {
+		case infrav1.IgnitionBootstrapFormat:
+			if err := extraConfig.SetIgnitionUserData(bootstrapData); err != nil {
+				return nil, err
+			}
+		default:
+			if err := extraConfig.SetCloudInitUserData(bootstrapData); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ctx.VSphereVM.Spec.CustomVMXKeys != nil {
+		ctx.Logger.Info("applied custom vmx keys o VM clone spec")
+		if err := extraConfig.SetCustomVMXKeys(ctx.VSphereVM.Spec.CustomVMXKeys); err != nil {
+			return nil, err
+		}
+	}
+	return extraConfig, nil
+}
+
+// getPlacement returns the folder and resource pool in which the VM is
+// created, as well as the ID of its storage policy, if any.
+func getPlacement(ctx *context.VMContext) (*object.Folder, *object.ResourcePool, string, error) {
+	folder, err := ctx.Session.Finder.FolderOrDefault(ctx, ctx.VSphereVM.Spec.Folder)
+	if err != nil {
+		return nil, nil, "", errors.Wrapf(err, "unable to get folder for %q", ctx)
+	}
+
+	var storageProfileID string
+	if policyName := ctx.VSphereVM.Spec.StoragePolicyName; policyName != "" {
+		if storageProfileID, err = storagepolicy.ProfileID(ctx, policyName); err != nil {
+			return nil, nil, "", errors.Wrapf(err, "unable to get storage policy for %q", ctx)
+		}
+	}
+
+	pool, err := ctx.Session.Finder.ResourcePoolOrDefault(ctx, ctx.VSphereVM.Spec.ResourcePool)
+	if err != nil {
+		return nil, nil, "", errors.Wrapf(err, "unable to get resource pool for %q", ctx)
+	}
+	if err := validateReservations(ctx, pool); err != nil {
+		return nil, nil, "", err
+	}
+
+	return folder, pool, storageProfileID, nil
+}
+
+// validateReservations validates that the resource pool has enough unreserved
+// capacity for the VM's CPU and memory reservations, since vSphere would
+// otherwise only refuse to power on the VM once it has been created.
+func validateReservations(ctx *context.VMContext, pool *object.ResourcePool) error {
+	resources := ctx.VSphereVM.Spec.Resources
+	if resources == nil {
+		return nil
+	}
+	cpuMHz := getReservation(resources.CPU)
+	memMiB := getReservation(resources.Memory)
+	if cpuMHz == 0 && memMiB == 0 {
+		return nil
+	}
+
+	var obj mo.ResourcePool
+	if err := pool.Properties(ctx, pool.Reference(), []string{"runtime"}, &obj); err != nil {
+		return errors.Wrapf(err, "unable to get capacity of resource pool for %q", ctx)
+	}
+	if unreserved := obj.Runtime.Cpu.UnreservedForVm; cpuMHz > unreserved {
+		return errors.Errorf("CPU reservation of %d MHz for %q exceeds the %d MHz available in resource pool %s",
+			cpuMHz, ctx, unreserved, pool.InventoryPath)
+	}
+	if unreserved := obj.Runtime.Memory.UnreservedForVm / (1024 * 1024); memMiB > unreserved {
+		return errors.Errorf("memory reservation of %d MiB for %q exceeds the %d MiB available in resource pool %s",
+			memMiB, ctx, unreserved, pool.InventoryPath)
+	}
+	return nil
+}
+
+func getReservation(allocation *infrav1.ResourceAllocation) int64 {
+	if allocation == nil || allocation.Reservation == nil {
+		return 0
+	}
+	return *allocation.Reservation
+}
+
+// ConfigSpec returns the spec that customises the NICs, CPU, memory,
+// additional disks, firmware and extra config of a new VM with the provided
+// devices, none of which are NICs. Unlike the spec of a clone, the spec does
+// not resize the VM's disk, which is grown once the VM is created.
+func ConfigSpec(
+	ctx *context.VMContext,
+	devices object.VirtualDeviceList,
+	extraConfig extra.Config,
+	datastore *object.Datastore) (*types.VirtualMachineConfigSpec, error) {
+
+	return getConfigSpec(ctx, devices, extraConfig, datastore, "", false)
+}
+
+// getConfigSpec returns the spec that customises the NICs, CPU, memory, disks,
+// firmware and extra config of a VM created from a source with the provided
+// devices.
+// The size of the source's disk is only changed if resizeDisk is true.
+func getConfigSpec(
+	ctx *context.VMContext,
+	devices object.VirtualDeviceList,
+	extraConfig extra.Config,
+	datastore *object.Datastore,
+	storageProfileID string,
+	resizeDisk bool) (*types.VirtualMachineConfigSpec, error) {
+
+	// Create a new list of device specs for the VM.
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+
+	if resizeDisk {
+		diskSpec, err := getDiskSpec(ctx, devices)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error getting disk spec for %q", ctx)
+		}
+		deviceSpecs = append(deviceSpecs, diskSpec)
+	}
+
+	additionalDiskSpecs, err := getAdditionalDiskSpecs(ctx, devices, datastore, storageProfileID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting additional disk specs for %q", ctx)
+	}
+	deviceSpecs = append(deviceSpecs, additionalDiskSpecs...)
+
+	networkSpecs, err := getNetworkSpecs(ctx, devices)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting network specs for %q", ctx)
+	}
+	deviceSpecs = append(deviceSpecs, networkSpecs...)
+
+	if tpmSpec := getTPMSpec(ctx, devices); tpmSpec != nil {
+		deviceSpecs = append(deviceSpecs, tpmSpec)
+	}
+
+	numCPUs, numCoresPerSocket, memMiB := Size(ctx.VSphereVM.Spec.VirtualMachineCloneSpec)
+
+	configSpec := &types.VirtualMachineConfigSpec{
+		// Assign the VM's InstanceUUID the value of the Kubernetes Machine
+		// object's UID. This allows lookup of the VM prior to knowing the
+		// VM's UUID.
+		InstanceUuid:      string(ctx.VSphereVM.UID),
+		Flags:             newVMFlagInfo(),
+		DeviceChange:      deviceSpecs,
+		ExtraConfig:       extraConfig,
+		NumCPUs:           numCPUs,
+		NumCoresPerSocket: numCoresPerSocket,
+		MemoryMB:          memMiB,
+	}
+	if resources := ctx.VSphereVM.Spec.Resources; resources != nil {
+		configSpec.CpuAllocation = getResourceAllocation(resources.CPU)
+		configSpec.MemoryAllocation = getResourceAllocation(resources.Memory)
+	}
+	if firmware := ctx.VSphereVM.Spec.Firmware; firmware != "" {
+		configSpec.Firmware = string(firmware)
+	}
+	if ctx.VSphereVM.Spec.SecureBoot {
+		configSpec.BootOptions = &types.VirtualMachineBootOptions{
+			EfiSecureBootEnabled: types.NewBool(true),
+		}
+	}
+	return configSpec, nil
+}
+
+// Size returns the number of CPUs, the number of cores per socket and the
+// memory size in MiB of a VM cloned with the clone spec.
+func Size(spec infrav1.VirtualMachineCloneSpec) (numCPUs, numCoresPerSocket int32, memoryMiB int64) {
+	numCPUs = spec.NumCPUs
+	if numCPUs < 2 {
+		numCPUs = 2
+	}
+	numCoresPerSocket = spec.NumCoresPerSocket
+	if numCoresPerSocket == 0 {
+		numCoresPerSocket = numCPUs
+	}
+	memoryMiB = spec.MemoryMiB
+	if memoryMiB == 0 {
+		memoryMiB = 2048
+	}
+	return numCPUs, numCoresPerSocket, memoryMiB
+}
+
+// tpmKey is the temporary device key of a VM's new virtual TPM, which is
+// distinct from the temporary keys of its new disks and NICs.
+const tpmKey = int32(-200)
+
+// getTPMSpec returns the spec that adds a virtual TPM to a VM created from a
+// source with the provided devices, or nil if the VM does not need one or
+// the source already has one.
+func getTPMSpec(ctx *context.VMContext, devices object.VirtualDeviceList) types.BaseVirtualDeviceConfigSpec {
+	if !ctx.VSphereVM.Spec.VTPM || len(devices.SelectByType((*types.VirtualTPM)(nil))) > 0 {
+		return nil
+	}
+	return &types.VirtualDeviceConfigSpec{
+		Device: &types.VirtualTPM{
+			VirtualDevice: types.VirtualDevice{Key: tpmKey},
+		},
+		Operation: types.VirtualDeviceConfigSpecOperationAdd,
+	}
+}
+
+// getResourceAllocation returns the vSphere allocation for the CPU or memory
+// allocation of a VM, or nil if the VM keeps the allocation of its source.
+func getResourceAllocation(allocation *infrav1.ResourceAllocation) *types.ResourceAllocationInfo {
+	if allocation == nil {
+		return nil
+	}
+	info := &types.ResourceAllocationInfo{
+		Reservation: allocation.Reservation,
+		Limit:       allocation.Limit,
+	}
+	if shares := allocation.Shares; shares != nil {
+		info.Shares = &types.SharesInfo{Level: types.SharesLevel(shares.Level)}
+		if shares.Value != nil {
+			info.Shares.Shares = *shares.Value
+		}
+	}
+	return info
+}
+
+// getBootstrapISOSpec uploads the VM's NoCloud ISO to the datastore and
+// returns the spec that attaches it to the VM. The ISO's metadata does not
+// include the MAC addresses generated for the VM, so it is updated before the
+// VM is powered on.
+func getBootstrapISOSpec(
+	ctx *context.VMContext,
+	devices object.VirtualDeviceList,
+	datastore *object.Datastore,
+	bootstrapData []byte) (types.BaseVirtualDeviceConfigSpec, error) {
+
+	metadata, err := util.GetMachineMetadata(ctx.VSphereVM.Name, *ctx.VSphereVM)
+	if err != nil {
+		return nil, err
+	}
+	iso, err := nocloud.NewISO(bootstrapData, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	isoPath := nocloud.Path(ctx.VSphereVM, datastore.Name())
+	ctx.Logger.Info("uploading bootstrap ISO", "path", isoPath)
+	if err := nocloud.Upload(ctx, ctx.Session, isoPath, iso); err != nil {
+		return nil, err
+	}
+	ctx.VSphereVM.Status.BootstrapISO = isoPath
+
+	return nocloud.AttachSpec(devices, isoPath)
+}
+
+// getVAppConfigSpec returns the spec that sets the VSphereVM's vApp properties
+// on a VM created from the source, as well as the properties that provide
+// the bootstrap data and metadata if the VM uses the vApp bootstrap
+// transport. It returns nil if there are no properties to set.
+func getVAppConfigSpec(
+	ctx *context.VMContext,
+	source *object.VirtualMachine,
+	bootstrapData []byte) (*types.VmConfigSpec, error) {
+
+	values := map[string]string{}
+	for id, value := range ctx.VSphereVM.Spec.VAppProperties {
+		values[id] = value
+	}
+	if ctx.VSphereVM.Spec.BootstrapTransport == infrav1.VAppBootstrapTransport {
+		metadata, err := util.GetMachineMetadata(ctx.VSphereVM.Name, *ctx.VSphereVM)
+		if err != nil {
+			return nil, err
+		}
+		bootstrapValues, err := vapp.BootstrapProperties(bootstrapData, metadata)
+		if err != nil {
+			return nil, err
+		}
+		for id, value := range bootstrapValues {
+			values[id] = value
+		}
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	var vm mo.VirtualMachine
+	if err := source.Properties(ctx, source.Reference(), []string{"config.vAppConfig"}, &vm); err != nil {
+		return nil, errors.Wrapf(err, "error getting vApp properties of %s", source.Reference())
+	}
+	var info *types.VmConfigInfo
+	if vm.Config != nil && vm.Config.VAppConfig != nil {
+		info = vm.Config.VAppConfig.GetVmConfigInfo()
+	}
+	return vapp.ConfigSpec(info, values, vapp.MetadataProperties...)
+}
+
 func newVMFlagInfo() *types.VirtualMachineFlagInfo {
 	diskUUIDEnabled := true
 	return &types.VirtualMachineFlagInfo{
@@ -208,12 +523,11 @@ func getDiskSpec(
 	ctx *context.VMContext,
 	devices object.VirtualDeviceList) (types.BaseVirtualDeviceConfigSpec, error) {
 
-	disks := devices.SelectByType((*types.VirtualDisk)(nil))
-	if len(disks) != 1 {
-		return nil, errors.Errorf("invalid disk count: %d", len(disks))
+	disk, err := selectDisk(ctx, devices)
+	if err != nil {
+		return nil, err
 	}
 
-	disk := disks[0].(*types.VirtualDisk)
 	cloneCapacityKB := int64(ctx.VSphereVM.Spec.DiskGiB) * 1024 * 1024
 	if disk.CapacityInKB > cloneCapacityKB {
 		return nil, errors.Errorf(
@@ -228,7 +542,184 @@ func getDiskSpec(
 	}, nil
 }
 
-const ethCardType = "vmxnet3"
+// selectDisk returns the template's disk selected by the VSphereVM's
+// DiskIndex.
+func selectDisk(ctx *context.VMContext, devices object.VirtualDeviceList) (*types.VirtualDisk, error) {
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+	if len(disks) == 0 {
+		return nil, errors.Errorf("invalid disk count: %d", len(disks))
+	}
+	diskIndex := int(ctx.VSphereVM.Spec.DiskIndex)
+	if diskIndex < 0 || diskIndex >= len(disks) {
+		return nil, errors.Errorf("invalid disk index %d, the template has %d disks", diskIndex, len(disks))
+	}
+	return disks[diskIndex].(*types.VirtualDisk), nil
+}
+
+// getDiskLocators returns the locators that apply the storage policy and the
+// provisioning type to the template's disks. It returns nil if neither is set,
+// in which case the disks inherit the template's backing.
+func getDiskLocators(
+	ctx *context.VMContext,
+	devices object.VirtualDeviceList,
+	datastore *object.Datastore,
+	storageProfileID string) []types.VirtualMachineRelocateSpecDiskLocator {
+
+	provisioningType := ctx.VSphereVM.Spec.ProvisioningType
+	if provisioningType == "" && storageProfileID == "" {
+		return nil
+	}
+
+	var locators []types.VirtualMachineRelocateSpecDiskLocator
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		locator := types.VirtualMachineRelocateSpecDiskLocator{
+			DiskId:    device.GetVirtualDevice().Key,
+			Datastore: datastore.Reference(),
+		}
+		if provisioningType != "" {
+			backing := &types.VirtualDiskFlatVer2BackingInfo{
+				DiskMode: string(types.VirtualDiskModePersistent),
+			}
+			setBackingProvisioningType(backing, provisioningType)
+			locator.DiskBackingInfo = backing
+		}
+		if storageProfileID != "" {
+			locator.Profile = getStorageProfileSpec(storageProfileID)
+		}
+		locators = append(locators, locator)
+	}
+	return locators
+}
+
+func getStorageProfileSpec(storageProfileID string) []types.BaseVirtualMachineProfileSpec {
+	return []types.BaseVirtualMachineProfileSpec{
+		&types.VirtualMachineDefinedProfileSpec{ProfileId: storageProfileID},
+	}
+}
+
+func getAdditionalDiskSpecs(
+	ctx *context.VMContext,
+	devices object.VirtualDeviceList,
+	defaultDatastore *object.Datastore,
+	storageProfileID string) ([]types.BaseVirtualDeviceConfigSpec, error) {
+
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+	for i, diskSpec := range ctx.VSphereVM.Spec.AdditionalDisks {
+		controller, err := getDiskController(ctx, devices, diskSpec.ControllerNumber)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to find controller for additional disk %d", i)
+		}
+
+		datastore := defaultDatastore
+		if diskSpec.Datastore != "" {
+			if datastore, err = ctx.Session.Finder.Datastore(ctx, diskSpec.Datastore); err != nil {
+				return nil, errors.Wrapf(err, "unable to find datastore %q for additional disk %d", diskSpec.Datastore, i)
+			}
+		}
+
+		// Create the disk without a file name so that vSphere names the
+		// disk's backing file after the VM in the datastore's VM folder.
+		disk := devices.CreateDisk(controller, datastore.Reference(), "")
+		disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo).FileName = fmt.Sprintf("[%s]", datastore.Name())
+		disk.CapacityInKB = int64(diskSpec.SizeGiB) * 1024 * 1024
+		provisioningType := diskSpec.ProvisioningType
+		if provisioningType == "" {
+			provisioningType = ctx.VSphereVM.Spec.ProvisioningType
+		}
+		setBackingProvisioningType(disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo), provisioningType)
+
+		if diskSpec.UnitNumber != nil {
+			if !isUnitNumberFree(devices, controller, *diskSpec.UnitNumber) {
+				return nil, errors.Errorf("unit number %d is not available for additional disk %d", *diskSpec.UnitNumber, i)
+			}
+			unitNumber := *diskSpec.UnitNumber
+			disk.UnitNumber = &unitNumber
+		} else if *disk.UnitNumber < 0 {
+			return nil, errors.Errorf("no unit number is available for additional disk %d", i)
+		}
+
+		// Assign a temporary device key to ensure that a unique one will be
+		// generated when the device is created.
+		disk.Key = devices.NewKey()
+
+		// Add the disk to the list of devices so that it is accounted for
+		// when assigning keys and unit numbers to the next disk.
+		devices = append(devices, disk)
+
+		deviceSpec := &types.VirtualDeviceConfigSpec{
+			Device:        disk,
+			Operation:     types.VirtualDeviceConfigSpecOperationAdd,
+			FileOperation: types.VirtualDeviceConfigSpecFileOperationCreate,
+		}
+		if storageProfileID != "" {
+			deviceSpec.Profile = getStorageProfileSpec(storageProfileID)
+		}
+		deviceSpecs = append(deviceSpecs, deviceSpec)
+		ctx.Logger.V(4).Info("created additional disk", "disk-spec", diskSpec, "unit-number", *disk.UnitNumber)
+	}
+
+	return deviceSpecs, nil
+}
+
+// getDiskController returns the SCSI controller with the provided bus number,
+// or the controller of the disk selected by the VSphereVM's DiskIndex if the
+// bus number is nil.
+func getDiskController(
+	ctx *context.VMContext,
+	devices object.VirtualDeviceList,
+	busNumber *int32) (types.BaseVirtualController, error) {
+
+	if busNumber == nil {
+		disk, err := selectDisk(ctx, devices)
+		if err != nil {
+			return nil, err
+		}
+		controller, ok := devices.FindByKey(disk.ControllerKey).(types.BaseVirtualController)
+		if !ok {
+			return nil, errors.Errorf("unable to find controller of disk %d", ctx.VSphereVM.Spec.DiskIndex)
+		}
+		return controller, nil
+	}
+
+	for _, device := range devices.SelectByType((*types.VirtualSCSIController)(nil)) {
+		controller := device.(types.BaseVirtualController)
+		if controller.GetVirtualController().BusNumber == *busNumber {
+			return controller, nil
+		}
+	}
+	return nil, errors.Errorf("the template has no SCSI controller with bus number %d", *busNumber)
+}
+
+// isUnitNumberFree returns true if no device is attached to the controller at
+// the provided unit number.
+func isUnitNumberFree(devices object.VirtualDeviceList, controller types.BaseVirtualController, unitNumber int32) bool {
+	if scsi, ok := controller.(types.BaseVirtualSCSIController); ok {
+		if scsi.GetVirtualSCSIController().ScsiCtlrUnitNumber == unitNumber {
+			return false
+		}
+	}
+	key := controller.GetVirtualController().Key
+	for _, device := range devices {
+		d := device.GetVirtualDevice()
+		if d.ControllerKey == key && d.UnitNumber != nil && *d.UnitNumber == unitNumber {
+			return false
+		}
+	}
+	return true
+}
+
+func setBackingProvisioningType(backing *types.VirtualDiskFlatVer2BackingInfo, provisioningType infrav1.DiskProvisioningType) {
+	switch provisioningType {
+	case infrav1.ThickProvisioning:
+		backing.ThinProvisioned = types.NewBool(false)
+		backing.EagerlyScrub = types.NewBool(false)
+	case infrav1.EagerZeroedThickProvisioning:
+		backing.ThinProvisioned = types.NewBool(false)
+		backing.EagerlyScrub = types.NewBool(true)
+	default:
+		backing.ThinProvisioned = types.NewBool(true)
+	}
+}
 
 func getNetworkSpecs(
 	ctx *context.VMContext,
@@ -248,17 +739,21 @@ func getNetworkSpecs(
 	key := int32(-100)
 	for i := range ctx.VSphereVM.Spec.Network.Devices {
 		netSpec := &ctx.VSphereVM.Spec.Network.Devices[i]
-		ref, err := ctx.Session.Finder.Network(ctx, netSpec.NetworkName)
+		ref, err := findNetwork(ctx, *netSpec)
 		if err != nil {
-			return nil, errors.Wrapf(err, "unable to find network %q", netSpec.NetworkName)
+			return nil, err
 		}
 		backing, err := ref.EthernetCardBackingInfo(ctx)
 		if err != nil {
-			return nil, errors.Wrapf(err, "unable to create new ethernet card backing info for network %q on %q", netSpec.NetworkName, ctx)
+			return nil, errors.Wrapf(err, "unable to create new ethernet card backing info for network %q on %q", ref.GetInventoryPath(), ctx)
+		}
+		adapterType := netSpec.AdapterType
+		if adapterType == "" {
+			adapterType = infrav1.VMXNET3NetworkAdapter
 		}
-		dev, err := object.EthernetCardTypes().CreateEthernetCard(ethCardType, backing)
+		dev, err := object.EthernetCardTypes().CreateEthernetCard(string(adapterType), backing)
 		if err != nil {
-			return nil, errors.Wrapf(err, "unable to create new ethernet card %q for network %q on %q", ethCardType, netSpec.NetworkName, ctx)
+			return nil, errors.Wrapf(err, "unable to create new ethernet card %q for network %q on %q", adapterType, ref.GetInventoryPath(), ctx)
 		}
 
 		// Get the actual NIC object. This is safe to assert without a check
@@ -282,7 +777,7 @@ func getNetworkSpecs(
 			Device:    dev,
 			Operation: types.VirtualDeviceConfigSpecOperationAdd,
 		})
-		ctx.Logger.V(4).Info("created network device", "eth-card-type", ethCardType, "network-spec", netSpec)
+		ctx.Logger.V(4).Info("created network device", "eth-card-type", adapterType, "network-spec", netSpec)
 		key--
 	}
 
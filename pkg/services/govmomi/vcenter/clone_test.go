@@ -19,13 +19,16 @@ package vcenter
 import (
 	ctx "context"
 	"crypto/tls"
+	"fmt"
 	"testing"
 
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/simulator"
 	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/utils/pointer"
 	"sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
 	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
 )
 
@@ -55,6 +58,7 @@ func TestGetDiskSpec(t *testing.T) {
 	testCases := []struct {
 		expectDevice  bool
 		cloneDiskSize int32
+		diskIndex     int32
 		name          string
 		disks         object.VirtualDeviceList
 		err           string
@@ -71,9 +75,18 @@ func TestGetDiskSpec(t *testing.T) {
 			err:   "invalid disk count: 0",
 		},
 		{
-			name:  "Fail to clone template with multiple disk devices",
-			disks: append(defaultDisks, defaultDisks...),
-			err:   "invalid disk count: 2",
+			name:          "Successfully clone template with multiple disk devices",
+			disks:         append(defaultDisks, defaultDisks...),
+			diskIndex:     1,
+			cloneDiskSize: defaultSizeGiB,
+			expectDevice:  true,
+		},
+		{
+			name:          "Fail to clone template with a disk index out of range",
+			disks:         append(defaultDisks, defaultDisks...),
+			diskIndex:     2,
+			cloneDiskSize: defaultSizeGiB,
+			err:           "invalid disk index 2, the template has 2 disks",
 		},
 		{
 			name:          "Successfully clone template and increase disk requirements",
@@ -93,7 +106,8 @@ func TestGetDiskSpec(t *testing.T) {
 		tc := test
 		t.Run(tc.name, func(t *testing.T) {
 			cloneSpec := v1alpha3.VirtualMachineCloneSpec{
-				DiskGiB: tc.cloneDiskSize,
+				DiskGiB:   tc.cloneDiskSize,
+				DiskIndex: tc.diskIndex,
 			}
 			vsphereVM := &v1alpha3.VSphereVM{
 				Spec: v1alpha3.VSphereVMSpec{
@@ -128,6 +142,220 @@ func TestGetDiskSpec(t *testing.T) {
 	}
 }
 
+func TestGetAdditionalDiskSpecs(t *testing.T) {
+	model, session, server := initSimulator(t)
+	defer model.Remove()
+	defer server.Close()
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	machine := object.NewVirtualMachine(session.Client.Client, vm.Reference())
+
+	devices, err := machine.Device(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to obtain vm devices: %v", err)
+	}
+	disk := devices.SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk)
+	controller := devices.FindByKey(disk.ControllerKey).(types.BaseVirtualController).GetVirtualController()
+
+	datastore, err := session.Finder.DefaultDatastore(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to find datastore: %v", err)
+	}
+
+	testCases := []struct {
+		name             string
+		disks            []v1alpha3.AdditionalDiskSpec
+		expectUnits      []int32
+		expectThin       []bool
+		expectEagerScrub []bool
+		err              string
+	}{
+		{
+			name: "Successfully add disks with free unit numbers",
+			disks: []v1alpha3.AdditionalDiskSpec{
+				{SizeGiB: 10},
+				{SizeGiB: 20, ProvisioningType: v1alpha3.ThickProvisioning},
+				{SizeGiB: 30, ProvisioningType: v1alpha3.EagerZeroedThickProvisioning},
+			},
+			expectUnits:      []int32{*disk.UnitNumber + 1, *disk.UnitNumber + 2, *disk.UnitNumber + 3},
+			expectThin:       []bool{true, false, false},
+			expectEagerScrub: []bool{false, false, true},
+		},
+		{
+			name: "Successfully add a disk with an explicit unit number",
+			disks: []v1alpha3.AdditionalDiskSpec{
+				{SizeGiB: 10, ControllerNumber: &controller.BusNumber, UnitNumber: pointer.Int32Ptr(5)},
+				{SizeGiB: 10},
+			},
+			expectUnits:      []int32{5, *disk.UnitNumber + 1},
+			expectThin:       []bool{true, true},
+			expectEagerScrub: []bool{false, false},
+		},
+		{
+			name: "Fail to add a disk with a unit number in use",
+			disks: []v1alpha3.AdditionalDiskSpec{
+				{SizeGiB: 10, UnitNumber: disk.UnitNumber},
+			},
+			err: fmt.Sprintf("unit number %d is not available for additional disk 0", *disk.UnitNumber),
+		},
+		{
+			name: "Fail to add a disk to a missing controller",
+			disks: []v1alpha3.AdditionalDiskSpec{
+				{SizeGiB: 10, ControllerNumber: pointer.Int32Ptr(3)},
+			},
+			err: "unable to find controller for additional disk 0: the template has no SCSI controller with bus number 3",
+		},
+		{
+			name: "Fail to add a disk to a missing datastore",
+			disks: []v1alpha3.AdditionalDiskSpec{
+				{SizeGiB: 10, Datastore: "missing"},
+			},
+			err: "unable to find datastore \"missing\" for additional disk 0: datastore 'missing' not found",
+		},
+	}
+
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+			vmContext.VSphereVM.Spec.AdditionalDisks = tc.disks
+			vmContext.Session = session
+			specs, err := getAdditionalDiskSpecs(vmContext, devices, datastore, "")
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("Expected to get '%v' error from getAdditionalDiskSpecs, got: '%v'", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error from getAdditionalDiskSpecs: %v", err)
+			}
+			if len(specs) != len(tc.disks) {
+				t.Fatalf("Expected %d device specs, got %d", len(tc.disks), len(specs))
+			}
+			keys := map[int32]bool{}
+			for i, spec := range specs {
+				deviceSpec := spec.GetVirtualDeviceConfigSpec()
+				if deviceSpec.Operation != types.VirtualDeviceConfigSpecOperationAdd ||
+					deviceSpec.FileOperation != types.VirtualDeviceConfigSpecFileOperationCreate {
+					t.Errorf("Disk %d has unexpected operations %q and %q", i, deviceSpec.Operation, deviceSpec.FileOperation)
+				}
+				newDisk := deviceSpec.Device.(*types.VirtualDisk)
+				if newDisk.ControllerKey != controller.Key {
+					t.Errorf("Disk %d has controller key %d, expected %d", i, newDisk.ControllerKey, controller.Key)
+				}
+				if *newDisk.UnitNumber != tc.expectUnits[i] {
+					t.Errorf("Disk %d has unit number %d, expected %d", i, *newDisk.UnitNumber, tc.expectUnits[i])
+				}
+				if keys[newDisk.Key] || newDisk.Key >= 0 {
+					t.Errorf("Disk %d has unexpected key %d", i, newDisk.Key)
+				}
+				keys[newDisk.Key] = true
+				if expectedKB := int64(tc.disks[i].SizeGiB) * 1024 * 1024; newDisk.CapacityInKB != expectedKB {
+					t.Errorf("Disk %d has capacity %dKiB, expected %dKiB", i, newDisk.CapacityInKB, expectedKB)
+				}
+				backing := newDisk.Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+				if *backing.ThinProvisioned != tc.expectThin[i] {
+					t.Errorf("Disk %d has thin provisioning %v, expected %v", i, *backing.ThinProvisioned, tc.expectThin[i])
+				}
+				if eagerScrub := backing.EagerlyScrub != nil && *backing.EagerlyScrub; eagerScrub != tc.expectEagerScrub[i] {
+					t.Errorf("Disk %d has eager scrub %v, expected %v", i, eagerScrub, tc.expectEagerScrub[i])
+				}
+				if expected := fmt.Sprintf("[%s]", datastore.Name()); backing.FileName != expected {
+					t.Errorf("Disk %d has file name %q, expected %q", i, backing.FileName, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDiskLocators(t *testing.T) {
+	model, session, server := initSimulator(t)
+	defer model.Remove()
+	defer server.Close()
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	machine := object.NewVirtualMachine(session.Client.Client, vm.Reference())
+
+	devices, err := machine.Device(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to obtain vm devices: %v", err)
+	}
+	disks := devices.SelectByType((*types.VirtualDisk)(nil))
+
+	datastore, err := session.Finder.DefaultDatastore(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to find datastore: %v", err)
+	}
+
+	testCases := []struct {
+		name             string
+		provisioningType v1alpha3.DiskProvisioningType
+		storageProfileID string
+		expectLocators   bool
+		expectThin       bool
+	}{
+		{
+			name: "No locators without a provisioning type or storage policy",
+		},
+		{
+			name:             "Locators with a storage policy",
+			storageProfileID: "profile",
+			expectLocators:   true,
+		},
+		{
+			name:             "Locators with a thin provisioning type",
+			provisioningType: v1alpha3.ThinProvisioning,
+			expectLocators:   true,
+			expectThin:       true,
+		},
+		{
+			name:             "Locators with a thick provisioning type and a storage policy",
+			provisioningType: v1alpha3.ThickProvisioning,
+			storageProfileID: "profile",
+			expectLocators:   true,
+		},
+	}
+
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+			vmContext.VSphereVM.Spec.ProvisioningType = tc.provisioningType
+
+			locators := getDiskLocators(vmContext, devices, datastore, tc.storageProfileID)
+			if !tc.expectLocators {
+				if locators != nil {
+					t.Fatalf("Expected no disk locators, got: %#v", locators)
+				}
+				return
+			}
+			if len(locators) != len(disks) {
+				t.Fatalf("Expected %d disk locators, got %d", len(disks), len(locators))
+			}
+			for i, locator := range locators {
+				if locator.DiskId != disks[i].GetVirtualDevice().Key {
+					t.Errorf("Locator %d has disk ID %d, expected %d", i, locator.DiskId, disks[i].GetVirtualDevice().Key)
+				}
+				if locator.Datastore != datastore.Reference() {
+					t.Errorf("Locator %d has datastore %v, expected %v", i, locator.Datastore, datastore.Reference())
+				}
+				if hasProfile := len(locator.Profile) > 0; hasProfile != (tc.storageProfileID != "") {
+					t.Errorf("Locator %d has profile %v, expected one: %v", i, locator.Profile, tc.storageProfileID != "")
+				}
+				if tc.provisioningType == "" {
+					if locator.DiskBackingInfo != nil {
+						t.Errorf("Locator %d has unexpected backing %#v", i, locator.DiskBackingInfo)
+					}
+					continue
+				}
+				backing := locator.DiskBackingInfo.(*types.VirtualDiskFlatVer2BackingInfo)
+				if *backing.ThinProvisioned != tc.expectThin {
+					t.Errorf("Locator %d has thin provisioning %v, expected %v", i, *backing.ThinProvisioned, tc.expectThin)
+				}
+			}
+		})
+	}
+}
+
 func initSimulator(t *testing.T) (*simulator.Model, *session.Session, *simulator.Server) {
 	model := simulator.VPX()
 	model.Host = 0
@@ -141,13 +369,128 @@ func initSimulator(t *testing.T) (*simulator.Model, *session.Session, *simulator
 	server := model.Service.NewServer()
 	pass, _ := server.URL.User.Password()
 
-	authSession, err := session.GetOrCreate(
+	authSession, err := session.NewManager(session.ManagerOptions{}).GetOrCreate(
 		ctx.TODO(),
-		server.URL.Host, "",
-		server.URL.User.Username(), pass, "")
+		session.Params{
+			Server:   server.URL.Host,
+			Username: server.URL.User.Username(),
+			Password: pass,
+		})
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	return model, authSession, server
 }
+
+func TestGetResourceAllocation(t *testing.T) {
+	if info := getResourceAllocation(nil); info != nil {
+		t.Fatalf("Expected no allocation, got: %#v", info)
+	}
+
+	info := getResourceAllocation(&v1alpha3.ResourceAllocation{
+		Reservation: pointer.Int64Ptr(1000),
+		Limit:       pointer.Int64Ptr(-1),
+		Shares:      &v1alpha3.SharesSpec{Level: v1alpha3.CustomSharesLevel, Value: pointer.Int32Ptr(4000)},
+	})
+	if *info.Reservation != 1000 || *info.Limit != -1 {
+		t.Fatalf("Expected reservation 1000 and limit -1, got: %d and %d", *info.Reservation, *info.Limit)
+	}
+	if info.Shares.Level != types.SharesLevelCustom || info.Shares.Shares != 4000 {
+		t.Fatalf("Expected 4000 custom shares, got: %#v", info.Shares)
+	}
+
+	info = getResourceAllocation(&v1alpha3.ResourceAllocation{Shares: &v1alpha3.SharesSpec{Level: v1alpha3.HighSharesLevel}})
+	if info.Reservation != nil || info.Limit != nil || info.Shares.Level != types.SharesLevelHigh {
+		t.Fatalf("Expected only high shares, got: %#v", info)
+	}
+}
+
+func TestValidateReservations(t *testing.T) {
+	model, session, server := initSimulator(t)
+	defer model.Remove()
+	defer server.Close()
+
+	pool, err := session.Finder.DefaultResourcePool(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to find resource pool: %v", err)
+	}
+	runtime := &simulator.Map.Get(pool.Reference()).(*simulator.ResourcePool).Runtime
+	runtime.Cpu.UnreservedForVm = 4000
+	runtime.Memory.UnreservedForVm = 8192 * 1024 * 1024
+
+	testCases := []struct {
+		name      string
+		resources *v1alpha3.VirtualMachineResources
+		expectErr bool
+	}{
+		{
+			name: "No resources",
+		},
+		{
+			name: "Reservations within the capacity of the resource pool",
+			resources: &v1alpha3.VirtualMachineResources{
+				CPU:    &v1alpha3.ResourceAllocation{Reservation: pointer.Int64Ptr(4000)},
+				Memory: &v1alpha3.ResourceAllocation{Reservation: pointer.Int64Ptr(8192)},
+			},
+		},
+		{
+			name: "Limits without reservations",
+			resources: &v1alpha3.VirtualMachineResources{
+				CPU: &v1alpha3.ResourceAllocation{Limit: pointer.Int64Ptr(8000)},
+			},
+		},
+		{
+			name: "CPU reservation exceeding the capacity of the resource pool",
+			resources: &v1alpha3.VirtualMachineResources{
+				CPU: &v1alpha3.ResourceAllocation{Reservation: pointer.Int64Ptr(4001)},
+			},
+			expectErr: true,
+		},
+		{
+			name: "Memory reservation exceeding the capacity of the resource pool",
+			resources: &v1alpha3.VirtualMachineResources{
+				Memory: &v1alpha3.ResourceAllocation{Reservation: pointer.Int64Ptr(8193)},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+			vmContext.VSphereVM.Spec.Resources = tc.resources
+			err := validateReservations(vmContext, pool)
+			if tc.expectErr && err == nil {
+				t.Fatal("Expected the reservations to be rejected")
+			}
+			if !tc.expectErr && err != nil {
+				t.Fatalf("Unexpected error from validateReservations: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetTPMSpec(t *testing.T) {
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	devices := object.VirtualDeviceList{&types.VirtualMachineVideoCard{}}
+	if spec := getTPMSpec(vmContext, devices); spec != nil {
+		t.Fatalf("Expected no TPM spec without vtpm, got: %#v", spec)
+	}
+
+	vmContext.VSphereVM.Spec.VTPM = true
+	spec := getTPMSpec(vmContext, devices)
+	if spec == nil {
+		t.Fatal("Expected a TPM spec")
+	}
+	deviceSpec := spec.GetVirtualDeviceConfigSpec()
+	if _, ok := deviceSpec.Device.(*types.VirtualTPM); !ok || deviceSpec.Operation != types.VirtualDeviceConfigSpecOperationAdd {
+		t.Fatalf("Expected a spec that adds a TPM, got: %#v", deviceSpec)
+	}
+
+	devices = append(devices, &types.VirtualTPM{})
+	if spec := getTPMSpec(vmContext, devices); spec != nil {
+		t.Fatalf("Expected no TPM spec for a source with a TPM, got: %#v", spec)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/storagepolicy"
+)
+
+// getDatastore returns the datastore in which the VM is cloned. The VM's
+// Datastore may be the name or inventory path of a datastore, or of a
+// datastore cluster, in which case the datastore recommended by Storage DRS
+// is used, provided the VM is cloned from a template. If the VM has no
+// Datastore but has a storage policy, the datastore of the resource pool's
+// compute resource that is compatible with the policy and has the most free
+// space is used.
+func getDatastore(
+	ctx *context.VMContext,
+	tpl *object.VirtualMachine,
+	folder *object.Folder,
+	pool *object.ResourcePool,
+	storageProfileID string) (*object.Datastore, error) {
+
+	datastorePath := ctx.VSphereVM.Spec.Datastore
+	if datastorePath == "" && storageProfileID != "" {
+		return storagepolicy.SelectDatastore(ctx, storageProfileID, pool)
+	}
+
+	datastore, err := ctx.Session.Finder.DatastoreOrDefault(ctx, datastorePath)
+	if err == nil {
+		return datastore, nil
+	}
+	if _, ok := err.(*find.NotFoundError); !ok || datastorePath == "" {
+		return nil, err
+	}
+
+	pod, podErr := ctx.Session.Finder.DatastoreCluster(ctx, datastorePath)
+	if podErr != nil {
+		// Report the original error, since the path is more likely to refer
+		// to a datastore than to a datastore cluster.
+		return nil, err
+	}
+	if tpl == nil {
+		return nil, errors.Errorf("datastore cluster %q is not supported without a template to clone", datastorePath)
+	}
+	return recommendDatastore(ctx, tpl, folder, pool, pod)
+}
+
+// recommendDatastore asks Storage DRS for the placement of the clone in the
+// datastore cluster, and returns the datastore of the top recommendation.
+func recommendDatastore(
+	ctx *context.VMContext,
+	tpl *object.VirtualMachine,
+	folder *object.Folder,
+	pool *object.ResourcePool,
+	pod *object.StoragePod) (*object.Datastore, error) {
+
+	podRef := pod.Reference()
+	tplRef := tpl.Reference()
+	folderRef := folder.Reference()
+	poolRef := pool.Reference()
+
+	placementSpec := types.StoragePlacementSpec{
+		Type: string(types.StoragePlacementSpecPlacementTypeClone),
+		PodSelectionSpec: types.StorageDrsPodSelectionSpec{
+			StoragePod: &podRef,
+			InitialVmConfig: []types.VmPodConfigForPlacement{
+				{StoragePod: podRef},
+			},
+		},
+		Vm:        &tplRef,
+		Folder:    &folderRef,
+		CloneName: ctx.VSphereVM.Name,
+		CloneSpec: &types.VirtualMachineCloneSpec{
+			Location: types.VirtualMachineRelocateSpec{
+				Folder: &folderRef,
+				Pool:   &poolRef,
+			},
+		},
+		ResourcePool: &poolRef,
+	}
+
+	srm := object.NewStorageResourceManager(ctx.Session.Client.Client)
+	result, err := srm.RecommendDatastores(ctx, placementSpec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get Storage DRS recommendations for datastore cluster %q", pod.InventoryPath)
+	}
+
+	for _, recommendation := range result.Recommendations {
+		for _, action := range recommendation.Action {
+			placement, ok := action.(*types.StoragePlacementAction)
+			if !ok {
+				continue
+			}
+			datastore := object.NewDatastore(ctx.Session.Client.Client, placement.Destination)
+			name, err := datastore.ObjectName(ctx)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to get name of recommended datastore %q", placement.Destination.Value)
+			}
+			datastore.InventoryPath = name
+			ctx.Logger.Info("using datastore recommended by Storage DRS",
+				"datastore-cluster", pod.InventoryPath, "datastore", name, "recommendation", recommendation.Key)
+			return datastore, nil
+		}
+	}
+
+	return nil, errors.Errorf("no Storage DRS recommendations for datastore cluster %q", pod.InventoryPath)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	ctx "context"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestGetDatastore(t *testing.T) {
+	model, session, server := initSimulator(t)
+	defer model.Remove()
+	defer server.Close()
+
+	// Move one of the datastores into a datastore cluster.
+	datastores, err := session.Finder.DatastoreList(ctx.TODO(), "*")
+	if err != nil {
+		t.Fatalf("Failed to list datastores: %v", err)
+	}
+	datacenter, err := session.Finder.DefaultDatacenter(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to find datacenter: %v", err)
+	}
+	folders, err := datacenter.Folders(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to get datacenter folders: %v", err)
+	}
+	pod, err := folders.DatastoreFolder.CreateStoragePod(ctx.TODO(), "DC0_POD0")
+	if err != nil {
+		t.Fatalf("Failed to create datastore cluster: %v", err)
+	}
+	task, err := pod.MoveInto(ctx.TODO(), []types.ManagedObjectReference{datastores[0].Reference()})
+	if err != nil {
+		t.Fatalf("Failed to move datastore into datastore cluster: %v", err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatalf("Failed to move datastore into datastore cluster: %v", err)
+	}
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	tpl := object.NewVirtualMachine(session.Client.Client, vm.Reference())
+	folder, err := session.Finder.DefaultFolder(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to find folder: %v", err)
+	}
+	pool, err := session.Finder.DefaultResourcePool(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to find resource pool: %v", err)
+	}
+
+	testCases := []struct {
+		name            string
+		datastore       string
+		expectDatastore string
+		err             string
+	}{
+		{
+			name:            "Successfully get the datastore recommended for a datastore cluster",
+			datastore:       "DC0_POD0",
+			expectDatastore: datastores[0].Name(),
+		},
+		{
+			name:      "Fail to get a missing datastore",
+			datastore: "missing",
+			err:       "datastore 'missing' not found",
+		},
+	}
+
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+			vmContext.VSphereVM.Spec.Datastore = tc.datastore
+			vmContext.Session = session
+
+			datastore, err := getDatastore(vmContext, tpl, folder, pool, "")
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("Expected to get '%v' error from getDatastore, got: '%v'", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error from getDatastore: %v", err)
+			}
+			if datastore.Name() != tc.expectDatastore {
+				t.Errorf("Expected datastore %q, got %q", tc.expectDatastore, datastore.Name())
+			}
+		})
+	}
+	// Storage DRS recommendations need a template to clone.
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.VSphereVM.Spec.Datastore = "DC0_POD0"
+	vmContext.Session = session
+	if _, err := getDatastore(vmContext, nil, folder, pool, ""); err == nil {
+		t.Error("Expected an error getting a datastore cluster without a template")
+	}
+}
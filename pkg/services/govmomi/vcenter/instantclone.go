@@ -0,0 +1,168 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+// instantClone forks the VSphereVM's VM from the running source VM.
+//
+// The forked VM resumes running as soon as it is created, so its network
+// identity is injected along with the bootstrap data as extra config when it
+// is forked, rather than once the VM is reconciled. The metadata is updated
+// with the MAC addresses of the VM's network devices afterwards, as it is for
+// cloned VMs. Only cloud-config bootstrap data provided with the guestinfo
+// bootstrap transport is accompanied by cloud-init metadata.
+func instantClone(ctx *context.VMContext, source *object.VirtualMachine, extraConfig extra.Config, format infrav1.BootstrapFormat) error {
+	spec, err := getInstantCloneSpec(ctx, source, extraConfig, format)
+	if err != nil {
+		return err
+	}
+
+	ctx.Logger.Info("cloning machine", "namespace", ctx.VSphereVM.Namespace, "name", ctx.VSphereVM.Name, "cloneType", ctx.VSphereVM.Status.CloneMode)
+	res, err := methods.InstantClone_Task(ctx, ctx.Session.Client.Client, &types.InstantClone_Task{
+		This: source.Reference(),
+		Spec: *spec,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "error trigging instant clone op for machine %s", ctx)
+	}
+
+	ctx.VSphereVM.Status.TaskRef = res.Returnval.Value
+
+	// patch the vsphereVM early to ensure that the task is
+	// reflected in the status right away, this avoid situations
+	// of concurrent clones
+	if err := ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+	}
+	return nil
+}
+
+// getInstantCloneSpec returns the spec that forks the VSphereVM's VM from the
+// running source VM.
+func getInstantCloneSpec(
+	ctx *context.VMContext,
+	source *object.VirtualMachine,
+	extraConfig extra.Config,
+	format infrav1.BootstrapFormat) (*types.VirtualMachineInstantCloneSpec, error) {
+
+	powerState, err := source.PowerState(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting power state of instant clone source %s", ctx.VSphereVM.Spec.Template)
+	}
+	if powerState != types.VirtualMachinePowerStatePoweredOn {
+		return nil, errors.Errorf("instant clone source %s is %s, but must be powered on", ctx.VSphereVM.Spec.Template, powerState)
+	}
+	ctx.VSphereVM.Status.CloneMode = infrav1.InstantClone
+
+	folder, pool, storageProfileID, err := getPlacement(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	datastore, err := getDatastore(ctx, source, folder, pool, storageProfileID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get datastore for %q", ctx)
+	}
+	ctx.VSphereVM.Status.Datastore = datastore.Name()
+
+	devices, err := source.Device(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting devices for %q", ctx)
+	}
+	networkSpecs, err := getInstantCloneNetworkSpecs(ctx, devices)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting network specs for %q", ctx)
+	}
+
+	transport := ctx.VSphereVM.Spec.BootstrapTransport
+	guestInfo := transport == "" || transport == infrav1.GuestInfoBootstrapTransport
+	if guestInfo && format != infrav1.IgnitionBootstrapFormat {
+		metadata, err := util.GetMachineMetadata(ctx.VSphereVM.Name, *ctx.VSphereVM)
+		if err != nil {
+			return nil, err
+		}
+		if err := extraConfig.SetCloudInitMetadata(metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.VirtualMachineInstantCloneSpec{
+		Name: ctx.VSphereVM.Name,
+		Location: types.VirtualMachineRelocateSpec{
+			Datastore:    types.NewReference(datastore.Reference()),
+			Folder:       types.NewReference(folder.Reference()),
+			Pool:         types.NewReference(pool.Reference()),
+			DeviceChange: networkSpecs,
+		},
+		Config: extraConfig,
+	}, nil
+}
+
+// getInstantCloneNetworkSpecs returns the specs that connect the source VM's
+// network devices to the VSphereVM's networks. Unlike a clone, an instant
+// clone may only change the backing of its network devices, so the source VM
+// must have exactly one network device for each of the VSphereVM's devices.
+func getInstantCloneNetworkSpecs(
+	ctx *context.VMContext,
+	devices object.VirtualDeviceList) ([]types.BaseVirtualDeviceConfigSpec, error) {
+
+	nics := devices.SelectByType((*types.VirtualEthernetCard)(nil))
+	if len(nics) != len(ctx.VSphereVM.Spec.Network.Devices) {
+		return nil, errors.Errorf("instant clone source %s has %d network devices, but %d are required",
+			ctx.VSphereVM.Spec.Template, len(nics), len(ctx.VSphereVM.Spec.Network.Devices))
+	}
+
+	deviceSpecs := []types.BaseVirtualDeviceConfigSpec{}
+	for i := range ctx.VSphereVM.Spec.Network.Devices {
+		netSpec := &ctx.VSphereVM.Spec.Network.Devices[i]
+		ref, err := findNetwork(ctx, *netSpec)
+		if err != nil {
+			return nil, err
+		}
+		backing, err := ref.EthernetCardBackingInfo(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create new ethernet card backing info for network %q on %q", ref.GetInventoryPath(), ctx)
+		}
+
+		nic := nics[i].(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+		nic.Backing = backing
+		if netSpec.MACAddr != "" {
+			nic.MacAddress = netSpec.MACAddr
+			nic.AddressType = string(types.VirtualEthernetCardMacTypeManual)
+			ctx.Logger.V(4).Info("configured manual mac address", "mac-addr", nic.MacAddress)
+		}
+
+		deviceSpecs = append(deviceSpecs, &types.VirtualDeviceConfigSpec{
+			Device:    nics[i],
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+		})
+		ctx.Logger.V(4).Info("connected network device", "network-spec", netSpec)
+	}
+
+	return deviceSpecs, nil
+}
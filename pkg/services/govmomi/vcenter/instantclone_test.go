@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	ctx "context"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+)
+
+func TestGetInstantCloneSpec(t *testing.T) {
+	model, session, server := initSimulator(t)
+	defer model.Remove()
+	defer server.Close()
+
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	source := object.NewVirtualMachine(session.Client.Client, vm.Reference())
+	devices, err := source.Device(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to obtain vm devices: %v", err)
+	}
+	nics := devices.SelectByType((*types.VirtualEthernetCard)(nil))
+	if len(nics) != 1 {
+		t.Fatalf("Expected the source VM to have 1 network device, got %d", len(nics))
+	}
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.Session = session
+	vmContext.VSphereVM.Spec.Template = vm.Name
+	vmContext.VSphereVM.Spec.CloneMode = infrav1.InstantClone
+	vmContext.VSphereVM.Spec.Network.Devices = []infrav1.NetworkDeviceSpec{
+		{NetworkName: "VM Network", MACAddr: "00:50:56:00:00:01", DHCP4: true},
+	}
+
+	var extraConfig extra.Config
+	if err := extraConfig.SetCloudInitUserData([]byte("bootstrap")); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := getInstantCloneSpec(vmContext, source, extraConfig, infrav1.CloudConfigBootstrapFormat)
+	if err != nil {
+		t.Fatalf("Failed to get instant clone spec: %v", err)
+	}
+	if vmContext.VSphereVM.Status.CloneMode != infrav1.InstantClone {
+		t.Errorf("Expected clone mode %q, got %q", infrav1.InstantClone, vmContext.VSphereVM.Status.CloneMode)
+	}
+	if spec.Name != vmContext.VSphereVM.Name {
+		t.Errorf("Expected name %q, got %q", vmContext.VSphereVM.Name, spec.Name)
+	}
+
+	// The network identity and the bootstrap data are injected as extra
+	// config.
+	keys := map[string]bool{}
+	for _, option := range spec.Config {
+		keys[option.GetOptionValue().Key] = true
+	}
+	for _, key := range []string{"guestinfo.userdata", "guestinfo.metadata"} {
+		if !keys[key] {
+			t.Errorf("Expected extra config key %q", key)
+		}
+	}
+
+	// Ignition configs are not accompanied by cloud-init metadata.
+	spec, err = getInstantCloneSpec(vmContext, source, nil, infrav1.IgnitionBootstrapFormat)
+	if err != nil {
+		t.Fatalf("Failed to get instant clone spec: %v", err)
+	}
+	if len(spec.Config) != 0 {
+		t.Errorf("Expected no extra config for Ignition bootstrap data, got %d keys", len(spec.Config))
+	}
+
+	// The source VM's network device is connected to the VM's network.
+	if len(spec.Location.DeviceChange) != 1 {
+		t.Fatalf("Expected 1 device change, got %d", len(spec.Location.DeviceChange))
+	}
+	deviceSpec := spec.Location.DeviceChange[0].GetVirtualDeviceConfigSpec()
+	if deviceSpec.Operation != types.VirtualDeviceConfigSpecOperationEdit {
+		t.Errorf("Expected operation %q, got %q", types.VirtualDeviceConfigSpecOperationEdit, deviceSpec.Operation)
+	}
+	nic := deviceSpec.Device.(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+	if nic.Key != nics[0].GetVirtualDevice().Key {
+		t.Errorf("Expected device key %d, got %d", nics[0].GetVirtualDevice().Key, nic.Key)
+	}
+	if nic.MacAddress != "00:50:56:00:00:01" || nic.AddressType != string(types.VirtualEthernetCardMacTypeManual) {
+		t.Errorf("Expected manual MAC address, got %q (%s)", nic.MacAddress, nic.AddressType)
+	}
+	backing, ok := nic.Backing.(*types.VirtualEthernetCardNetworkBackingInfo)
+	if !ok || backing.DeviceName != "VM Network" {
+		t.Errorf("Expected device to be connected to %q, got %#v", "VM Network", nic.Backing)
+	}
+
+	// Instant clones may only edit the source VM's network devices.
+	vmContext.VSphereVM.Spec.Network.Devices = append(vmContext.VSphereVM.Spec.Network.Devices,
+		infrav1.NetworkDeviceSpec{NetworkName: "VM Network"})
+	if _, err := getInstantCloneSpec(vmContext, source, nil, infrav1.CloudConfigBootstrapFormat); err == nil {
+		t.Error("Expected an error for a source VM with too few network devices")
+	}
+
+	// The source VM must be running.
+	task, err := source.PowerOff(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	vmContext.VSphereVM.Spec.Network.Devices = vmContext.VSphereVM.Spec.Network.Devices[:1]
+	if _, err := getInstantCloneSpec(vmContext, source, nil, infrav1.CloudConfigBootstrapFormat); err == nil {
+		t.Error("Expected an error for a powered off source VM")
+	}
+}
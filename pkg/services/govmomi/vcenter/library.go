@@ -0,0 +1,269 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	goctx "context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/rest"
+	vapivcenter "github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/services/govmomi/extra"
+)
+
+const (
+	subscribedLibraryType     = "SUBSCRIBED"
+	useSpecifiedStoragePolicy = "USE_SPECIFIED_POLICY"
+
+	// libraryDeployTimeout bounds the time a reconcile waits for a Content
+	// Library item to be deployed.
+	libraryDeployTimeout = 15 * time.Minute
+)
+
+// deployLibraryItem deploys the VM from the Content Library item named by the
+// VSphereVM's Template, and then reconfigures the VM with the same
+// customization that is applied to a clone, including the vApp properties of
+// OVF templates.
+//
+// Unlike a clone, the deployment is not an asynchronous task, so only the
+// reconfiguration is tracked by the VSphereVM's TaskRef. The VSphereVM is
+// marked as pending customization before the deployment starts, so that a VM
+// whose reconfiguration failed or was never started, for example because the
+// controller restarted, is customized by CustomizeLibraryVM once it is found
+// rather than powered on as it was deployed.
+func deployLibraryItem(ctx *context.VMContext, extraConfig extra.Config, bootstrapData []byte) error {
+	folder, pool, storageProfileID, err := getPlacement(ctx)
+	if err != nil {
+		return err
+	}
+
+	datastore, err := getDatastore(ctx, nil, folder, pool, storageProfileID)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get datastore for %q", ctx)
+	}
+	ctx.VSphereVM.Status.Datastore = datastore.Name()
+	ctx.VSphereVM.Status.CloneMode = infrav1.FullClone
+	ctx.VSphereVM.Status.PendingCustomization = true
+
+	// patch the vsphereVM before deploying the item to ensure that the
+	// VM is customized even if the controller stops before the
+	// reconfiguration is started
+	if err := ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+	}
+
+	vm, err := deployVM(ctx, folder, pool, datastore, storageProfileID)
+	if err != nil {
+		return err
+	}
+	return customizeDeployedVM(ctx, vm, extraConfig, bootstrapData, datastore, storageProfileID)
+}
+
+// CustomizeLibraryVM reconfigures a VM that was deployed from a Content
+// Library item for the VSphereVM but is still pending customization.
+func CustomizeLibraryVM(ctx *context.VMContext, vmRef types.ManagedObjectReference, bootstrapData []byte, format infrav1.BootstrapFormat) error {
+	ctx = &context.VMContext{
+		ControllerContext: ctx.ControllerContext,
+		VSphereVM:         ctx.VSphereVM,
+		Session:           ctx.Session,
+		Logger:            ctx.Logger.WithName("vcenter"),
+		PatchHelper:       ctx.PatchHelper,
+	}
+	ctx.Logger.Info("customizing previously deployed machine", "vm", vmRef.Value)
+
+	extraConfig, err := BootstrapExtraConfig(ctx, bootstrapData, format)
+	if err != nil {
+		return err
+	}
+	_, _, storageProfileID, err := getPlacement(ctx)
+	if err != nil {
+		return err
+	}
+	datastore, err := ctx.Session.Finder.Datastore(ctx, ctx.VSphereVM.Status.Datastore)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find datastore %q for %q", ctx.VSphereVM.Status.Datastore, ctx)
+	}
+
+	vm := object.NewVirtualMachine(ctx.Session.Client.Client, vmRef)
+	return customizeDeployedVM(ctx, vm, extraConfig, bootstrapData, datastore, storageProfileID)
+}
+
+// customizeDeployedVM starts the reconfiguration of the deployed VM with the
+// VSphereVM's customization, which also assigns the VSphereVM's UID as the
+// VM's instance UUID.
+func customizeDeployedVM(
+	ctx *context.VMContext,
+	vm *object.VirtualMachine,
+	extraConfig extra.Config,
+	bootstrapData []byte,
+	datastore *object.Datastore,
+	storageProfileID string) error {
+
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "error getting devices for %q", ctx)
+	}
+
+	configSpec, err := getConfigSpec(ctx, devices, extraConfig, datastore, storageProfileID, true)
+	if err != nil {
+		return err
+	}
+	if storageProfileID != "" {
+		configSpec.VmProfile = getStorageProfileSpec(storageProfileID)
+	}
+	vAppConfig, err := getVAppConfigSpec(ctx, vm, bootstrapData)
+	if err != nil {
+		return errors.Wrapf(err, "error getting vApp config spec for %q", ctx)
+	}
+	if vAppConfig != nil {
+		configSpec.VAppConfig = vAppConfig
+	}
+
+	task, err := vm.Reconfigure(ctx, *configSpec)
+	if err != nil {
+		return errors.Wrapf(err, "error triggering reconfigure op for machine %s", ctx)
+	}
+
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+
+	// patch the vsphereVM early to ensure that the task is
+	// reflected in the status right away, this avoid situations
+	// of concurrent clones
+	if err := ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+	}
+	return nil
+}
+
+// deployVM deploys the Content Library item into the folder, resource pool
+// and datastore, and returns the powered off VM.
+//
+// The vSphere Automation API deploys an item synchronously, so the reconcile
+// is blocked until the item is deployed, for at most libraryDeployTimeout. A
+// deployment that is still running in vCenter once the timeout has elapsed
+// leaves behind a VM that is customized by CustomizeLibraryVM once found.
+func deployVM(
+	ctx *context.VMContext,
+	folder *object.Folder,
+	pool *object.ResourcePool,
+	datastore *object.Datastore,
+	storageProfileID string) (*object.VirtualMachine, error) {
+
+	deployCtx, cancel := goctx.WithTimeout(ctx, libraryDeployTimeout)
+	defer cancel()
+
+	restClient, err := ctx.Session.RestClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	item, err := findLibraryItem(ctx, restClient)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Logger.Info("deploying machine from content library",
+		"namespace", ctx.VSphereVM.Namespace, "name", ctx.VSphereVM.Name,
+		"library", ctx.VSphereVM.Spec.ContentLibrary, "item", item.Name, "type", item.Type)
+
+	manager := vapivcenter.NewManager(restClient)
+	var ref *types.ManagedObjectReference
+	switch item.Type {
+	case library.ItemTypeOVF:
+		ref, err = manager.DeployLibraryItem(deployCtx, item.ID, vapivcenter.Deploy{
+			DeploymentSpec: vapivcenter.DeploymentSpec{
+				Name:                ctx.VSphereVM.Name,
+				AcceptAllEULA:       true,
+				DefaultDatastoreID:  datastore.Reference().Value,
+				StorageProvisioning: string(ctx.VSphereVM.Spec.ProvisioningType),
+				StorageProfileID:    storageProfileID,
+			},
+			Target: vapivcenter.Target{
+				ResourcePoolID: pool.Reference().Value,
+				FolderID:       folder.Reference().Value,
+			},
+		})
+	case library.ItemTypeVMTX:
+		storage := &vapivcenter.DiskStorage{Datastore: datastore.Reference().Value}
+		if storageProfileID != "" {
+			storage.StoragePolicy = &vapivcenter.StoragePolicy{Policy: storageProfileID, Type: useSpecifiedStoragePolicy}
+		}
+		ref, err = manager.DeployTemplateLibraryItem(deployCtx, item.ID, vapivcenter.DeployTemplate{
+			Name: ctx.VSphereVM.Name,
+			Placement: &vapivcenter.Placement{
+				ResourcePool: pool.Reference().Value,
+				Folder:       folder.Reference().Value,
+			},
+			DiskStorage:   storage,
+			VMHomeStorage: storage,
+		})
+	default:
+		return nil, errors.Errorf("content library item %q has unsupported type %q", item.Name, item.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error deploying content library item %q for machine %s", item.Name, ctx)
+	}
+
+	return object.NewVirtualMachine(ctx.Session.Client.Client, *ref), nil
+}
+
+// findLibraryItem returns the item of the VSphereVM's Content Library named
+// by its Template. The item is synchronized first if the library is a
+// subscribed library whose item content has not been downloaded.
+func findLibraryItem(ctx *context.VMContext, restClient *rest.Client) (*library.Item, error) {
+	manager := library.NewManager(restClient)
+
+	libraryID := ctx.VSphereVM.Spec.ContentLibrary
+	var lib *library.Library
+	var err error
+	if _, uuidErr := uuid.Parse(libraryID); uuidErr == nil {
+		lib, err = manager.GetLibraryByID(ctx, libraryID)
+	} else {
+		lib, err = manager.GetLibraryByName(ctx, libraryID)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find content library %q", libraryID)
+	}
+
+	itemIDs, err := manager.FindLibraryItems(ctx, library.FindItem{LibraryID: lib.ID, Name: ctx.VSphereVM.Spec.Template})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find item %q of content library %q", ctx.VSphereVM.Spec.Template, lib.Name)
+	}
+	if len(itemIDs) != 1 {
+		return nil, errors.Errorf("found %d items named %q in content library %q", len(itemIDs), ctx.VSphereVM.Spec.Template, lib.Name)
+	}
+	item, err := manager.GetLibraryItem(ctx, itemIDs[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get item %q of content library %q", ctx.VSphereVM.Spec.Template, lib.Name)
+	}
+
+	if lib.Type == subscribedLibraryType && !item.Cached {
+		ctx.Logger.Info("synchronizing content library item", "library", lib.Name, "item", item.Name)
+		if err := manager.SyncLibraryItem(ctx, item, false); err != nil {
+			return nil, errors.Wrapf(err, "unable to synchronize item %q of content library %q", item.Name, lib.Name)
+		}
+	}
+
+	return item, nil
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	ctx "context"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vapi/library"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"github.com/vmware/govmomi/vapi/vcenter"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+)
+
+func TestDeployLibraryItem(t *testing.T) {
+	model, session, server := initSimulator(t)
+	defer model.Remove()
+	defer server.Close()
+
+	// Create a library with a VM template item.
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	datastore := simulator.Map.Any("Datastore")
+	restClient, err := session.RestClient(ctx.TODO())
+	if err != nil {
+		t.Fatalf("Failed to login to the vSphere Automation API: %v", err)
+	}
+	libraryID, err := library.NewManager(restClient).CreateLibrary(ctx.TODO(), library.Library{
+		Name: "capv",
+		Type: "LOCAL",
+		Storage: []library.StorageBackings{{
+			DatastoreID: datastore.Reference().Value,
+			Type:        "DATASTORE",
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create library: %v", err)
+	}
+	if _, err := vcenter.NewManager(restClient).CreateTemplate(ctx.TODO(), vcenter.Template{
+		Name:     "ubuntu",
+		Library:  libraryID,
+		SourceVM: vm.Reference().Value,
+		Placement: &vcenter.Placement{
+			Folder:       vm.Parent.Value,
+			ResourcePool: vm.ResourcePool.Value,
+		},
+	}); err != nil {
+		t.Fatalf("Failed to create library item: %v", err)
+	}
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.Session = session
+	vmContext.VSphereVM.Spec.ContentLibrary = libraryID
+	vmContext.VSphereVM.Spec.Template = "missing"
+	vmContext.VSphereVM.Spec.DiskGiB = 1
+
+	// A missing item is reported.
+	if err := Clone(vmContext, nil, ""); err == nil {
+		t.Fatal("Expected an error deploying a missing library item")
+	}
+
+	// The library may also be referenced by name.
+	vmContext.VSphereVM.Spec.ContentLibrary = "capv"
+	vmContext.VSphereVM.Spec.Template = "ubuntu"
+	folder, pool, _, err := getPlacement(vmContext)
+	if err != nil {
+		t.Fatalf("Failed to get placement: %v", err)
+	}
+	deployed, err := deployVM(vmContext, folder, pool, object.NewDatastore(session.Client.Client, datastore.Reference()), "")
+	if err != nil {
+		t.Fatalf("Failed to deploy library item: %v", err)
+	}
+
+	// The simulator deploys VM templates as templates, which cannot be
+	// reconfigured, unlike vCenter.
+	host := simulator.Map.Any("HostSystem")
+	if err := deployed.MarkAsVirtualMachine(ctx.TODO(), *pool, object.NewHostSystem(session.Client.Client, host.Reference())); err != nil {
+		t.Fatalf("Failed to mark deployed template as a VM: %v", err)
+	}
+
+	// The deployed VM is customized.
+	vmContext.VSphereVM.Status.Datastore = datastore.(*simulator.Datastore).Name
+	vmContext.VSphereVM.Spec.NumCPUs = 4
+	if err := CustomizeLibraryVM(vmContext, deployed.Reference(), []byte("bootstrap"), infrav1.CloudConfigBootstrapFormat); err != nil {
+		t.Fatalf("Failed to customize deployed VM: %v", err)
+	}
+
+	task := object.NewTask(session.Client.Client, types.ManagedObjectReference{Type: "Task", Value: vmContext.VSphereVM.Status.TaskRef})
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatalf("Failed to reconfigure deployed VM: %v", err)
+	}
+
+	var moVM mo.VirtualMachine
+	if err := deployed.Properties(ctx.TODO(), deployed.Reference(), []string{"config"}, &moVM); err != nil {
+		t.Fatalf("Failed to get deployed VM config: %v", err)
+	}
+	if moVM.Config.InstanceUuid != string(vmContext.VSphereVM.UID) {
+		t.Errorf("Expected instance UUID %q, got %q", vmContext.VSphereVM.UID, moVM.Config.InstanceUuid)
+	}
+	if moVM.Config.Hardware.NumCPU != 4 {
+		t.Errorf("Expected 4 CPUs, got %d", moVM.Config.Hardware.NumCPU)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// findNetwork returns the network to which the network device is connected.
+// The network must match all of the device's network references that are
+// set, and it is an error if more than one network matches them.
+func findNetwork(ctx *context.VMContext, device infrav1.NetworkDeviceSpec) (object.NetworkReference, error) {
+	// Without a name, all of the networks in the datacenter, including those
+	// in subfolders of its network folder, are candidates.
+	name := device.NetworkName
+	if name == "" {
+		name = "*"
+	}
+	networks, err := ctx.Session.Finder.NetworkList(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find network %s", describeNetwork(device))
+	}
+
+	matches, err := filterNetworks(ctx, networks, device)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find network %s", describeNetwork(device))
+	}
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return nil, errors.Errorf("no network matches %s", describeNetwork(device))
+	}
+
+	candidates := make([]string, 0, len(matches))
+	for _, network := range matches {
+		candidates = append(candidates, network.GetInventoryPath())
+	}
+	sort.Strings(candidates)
+	return nil, errors.Errorf("networks %s all match %s", strings.Join(candidates, ", "), describeNetwork(device))
+}
+
+// describeNetwork returns a description of the network references of the
+// network device for errors.
+func describeNetwork(device infrav1.NetworkDeviceSpec) string {
+	var refs []string
+	if device.NetworkName != "" {
+		refs = append(refs, "name "+device.NetworkName)
+	}
+	if device.PortGroupKey != "" {
+		refs = append(refs, "port group key "+device.PortGroupKey)
+	}
+	if device.DVSUUID != "" {
+		refs = append(refs, "distributed switch UUID "+device.DVSUUID)
+	}
+	if device.SegmentID != "" {
+		refs = append(refs, "segment ID "+device.SegmentID)
+	}
+	return strings.Join(refs, ", ")
+}
+
+// filterNetworks returns the networks that match the port group key,
+// distributed switch UUID and segment ID of the network device.
+func filterNetworks(ctx *context.VMContext, networks []object.NetworkReference, device infrav1.NetworkDeviceSpec) ([]object.NetworkReference, error) {
+	if device.PortGroupKey == "" && device.DVSUUID == "" && device.SegmentID == "" {
+		return networks, nil
+	}
+
+	byRef := map[types.ManagedObjectReference]object.NetworkReference{}
+	var portgroupRefs, opaqueNetworkRefs []types.ManagedObjectReference
+	for _, network := range networks {
+		ref := network.Reference()
+		byRef[ref] = network
+		switch ref.Type {
+		case "DistributedVirtualPortgroup":
+			portgroupRefs = append(portgroupRefs, ref)
+		case "OpaqueNetwork":
+			opaqueNetworkRefs = append(opaqueNetworkRefs, ref)
+		}
+	}
+
+	pc := property.DefaultCollector(ctx.Session.Client.Client)
+	var matches []object.NetworkReference
+	if len(portgroupRefs) > 0 {
+		var portgroups []mo.DistributedVirtualPortgroup
+		if err := pc.Retrieve(ctx, portgroupRefs, []string{"config"}, &portgroups); err != nil {
+			return nil, errors.Wrap(err, "unable to get distributed port groups")
+		}
+		switchUUIDs, err := getSwitchUUIDs(ctx, pc, portgroups, device)
+		if err != nil {
+			return nil, err
+		}
+		for _, portgroup := range portgroups {
+			config := portgroup.Config
+			if device.PortGroupKey != "" && config.Key != device.PortGroupKey {
+				continue
+			}
+			if device.DVSUUID != "" && (config.DistributedVirtualSwitch == nil || switchUUIDs[*config.DistributedVirtualSwitch] != device.DVSUUID) {
+				continue
+			}
+			if device.SegmentID != "" && config.SegmentId != device.SegmentID && config.LogicalSwitchUuid != device.SegmentID {
+				continue
+			}
+			matches = append(matches, byRef[portgroup.Reference()])
+		}
+	}
+
+	// Opaque networks are not port groups of distributed switches, so they
+	// may only be matched by their segment ID.
+	if len(opaqueNetworkRefs) > 0 && device.PortGroupKey == "" && device.DVSUUID == "" {
+		var opaqueNetworks []mo.OpaqueNetwork
+		if err := pc.Retrieve(ctx, opaqueNetworkRefs, []string{"summary"}, &opaqueNetworks); err != nil {
+			return nil, errors.Wrap(err, "unable to get opaque networks")
+		}
+		for _, network := range opaqueNetworks {
+			if summary, ok := network.Summary.(*types.OpaqueNetworkSummary); ok && summary.OpaqueNetworkId == device.SegmentID {
+				matches = append(matches, byRef[network.Reference()])
+			}
+		}
+	}
+	return matches, nil
+}
+
+// getSwitchUUIDs returns the UUIDs of the distributed switches of the port
+// groups if the network device references a distributed switch.
+func getSwitchUUIDs(
+	ctx *context.VMContext,
+	pc *property.Collector,
+	portgroups []mo.DistributedVirtualPortgroup,
+	device infrav1.NetworkDeviceSpec) (map[types.ManagedObjectReference]string, error) {
+
+	uuids := map[types.ManagedObjectReference]string{}
+	if device.DVSUUID == "" {
+		return uuids, nil
+	}
+	var switchRefs []types.ManagedObjectReference
+	for _, portgroup := range portgroups {
+		if ref := portgroup.Config.DistributedVirtualSwitch; ref != nil {
+			if _, ok := uuids[*ref]; !ok {
+				uuids[*ref] = ""
+				switchRefs = append(switchRefs, *ref)
+			}
+		}
+	}
+	if len(switchRefs) == 0 {
+		return uuids, nil
+	}
+
+	var switches []mo.DistributedVirtualSwitch
+	if err := pc.Retrieve(ctx, switchRefs, []string{"uuid"}, &switches); err != nil {
+		return nil, errors.Wrap(err, "unable to get distributed switches")
+	}
+	for _, dvs := range switches {
+		uuids[dvs.Reference()] = dvs.Uuid
+	}
+	return uuids, nil
+}
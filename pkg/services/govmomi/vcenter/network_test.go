@@ -0,0 +1,162 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	ctx "context"
+	"crypto/tls"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestFindNetwork(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0
+	model.PortgroupNSX = 1
+	model.OpaqueNetwork = 1
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	server := model.Service.NewServer()
+	defer server.Close()
+	pass, _ := server.URL.User.Password()
+	authSession, err := session.NewManager(session.ManagerOptions{}).GetOrCreate(ctx.TODO(), session.Params{
+		Server:   server.URL.Host,
+		Username: server.URL.User.Username(),
+		Password: pass,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	portgroup := simulator.Map.Any("DistributedVirtualPortgroup").(*simulator.DistributedVirtualPortgroup)
+	dvs := simulator.Map.Get(*portgroup.Config.DistributedVirtualSwitch).(*simulator.DistributedVirtualSwitch)
+	var nsxPortgroup, opaqueNetwork types.ManagedObjectReference
+	var segmentID, opaqueNetworkID string
+	for _, obj := range simulator.Map.All("DistributedVirtualPortgroup") {
+		if pg := obj.(*simulator.DistributedVirtualPortgroup); pg.Config.LogicalSwitchUuid != "" {
+			nsxPortgroup, segmentID = pg.Self, pg.Config.LogicalSwitchUuid
+		}
+	}
+	for _, obj := range simulator.Map.All("OpaqueNetwork") {
+		network := obj.(*mo.OpaqueNetwork)
+		opaqueNetwork, opaqueNetworkID = network.Self, network.Summary.(*types.OpaqueNetworkSummary).OpaqueNetworkId
+	}
+
+	// Add a distributed switch in a subfolder of the network folder with a
+	// port group of the same name as the first switch's port group.
+	networkFolder, err := authSession.Finder.Folder(ctx.TODO(), "network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subfolder, err := networkFolder.CreateFolder(ctx.TODO(), "nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := subfolder.CreateDVS(ctx.TODO(), types.DVSCreateSpec{
+		ConfigSpec: &types.VMwareDVSConfigSpec{DVSConfigSpec: types.DVSConfigSpec{Name: "DC0_DVS1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := task.WaitForResult(ctx.TODO(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDVS := object.NewDistributedVirtualSwitch(authSession.Client.Client, info.Result.(types.ManagedObjectReference))
+	task, err = otherDVS.AddPortgroup(ctx.TODO(), []types.DVPortgroupConfigSpec{{Name: portgroup.Name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name        string
+		device      v1alpha3.NetworkDeviceSpec
+		expectRef   types.ManagedObjectReference
+		expectError string
+	}{
+		{
+			name:      "Network by name",
+			device:    v1alpha3.NetworkDeviceSpec{NetworkName: "VM Network"},
+			expectRef: simulator.Map.Any("Network").Reference(),
+		},
+		{
+			name:      "Port group by key",
+			device:    v1alpha3.NetworkDeviceSpec{PortGroupKey: portgroup.Config.Key},
+			expectRef: portgroup.Self,
+		},
+		{
+			name:      "Port group by name and distributed switch UUID",
+			device:    v1alpha3.NetworkDeviceSpec{NetworkName: portgroup.Name, DVSUUID: dvs.Uuid},
+			expectRef: portgroup.Self,
+		},
+		{
+			name:      "NSX port group by segment ID",
+			device:    v1alpha3.NetworkDeviceSpec{SegmentID: segmentID},
+			expectRef: nsxPortgroup,
+		},
+		{
+			name:      "Opaque network by segment ID",
+			device:    v1alpha3.NetworkDeviceSpec{SegmentID: opaqueNetworkID},
+			expectRef: opaqueNetwork,
+		},
+		{
+			name:        "Port groups with the same name",
+			device:      v1alpha3.NetworkDeviceSpec{NetworkName: portgroup.Name},
+			expectError: "networks /DC0/network/" + portgroup.Name + ", /DC0/network/nested/" + portgroup.Name + " all match name " + portgroup.Name,
+		},
+		{
+			name:        "Port group key of another distributed switch",
+			device:      v1alpha3.NetworkDeviceSpec{PortGroupKey: portgroup.Config.Key, DVSUUID: "00 00 00 00"},
+			expectError: "no network matches port group key " + portgroup.Config.Key + ", distributed switch UUID 00 00 00 00",
+		},
+	}
+
+	for _, test := range testCases {
+		tc := test
+		t.Run(tc.name, func(t *testing.T) {
+			vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+			vmContext.Session = authSession
+			network, err := findNetwork(vmContext, tc.device)
+			if tc.expectError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectError) {
+					t.Fatalf("Expected to get '%v' error from findNetwork, got: '%v'", tc.expectError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error from findNetwork: %v", err)
+			}
+			if network.Reference() != tc.expectRef {
+				t.Fatalf("Expected network %v, got %v", tc.expectRef, network.Reference())
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context"
+)
+
+// defaultManagedSnapshot is the name of the snapshot that is managed on the
+// base VMs of a VSphereVM that does not name one.
+const defaultManagedSnapshot = "capv-linked-clone"
+
+// getManagedSnapshot returns the VM and the snapshot from which to create a
+// linked clone of a VSphereVM that manages the snapshot of its template. If
+// the template is on the datastore and has the snapshot, or any snapshot if
+// the VSphereVM does not name one, then the VM is the template itself.
+// Otherwise the VM is the template's base VM on the datastore, which is
+// cloned from the template if it does not exist yet and then snapshotted. The
+// template itself is never modified.
+//
+// Starting the clones of a template's base VMs and the creation of their
+// snapshots is serialized by a lock on the template that is shared by the
+// sessions to the server, since the VSphereVMs cloned from a template are
+// reconciled concurrently. The lock is never held while waiting for a task.
+//
+// The clone of a base VM and the creation of its snapshot are not waited for.
+// Instead they are recorded as the server's in-flight task for the base VM
+// and as the VSphereVM's TaskRef, and ready is false until the base VM has
+// the snapshot. The VSphereVM that starts either task keeps its clone slot
+// for it, while the VSphereVMs that wait for a task started by another
+// VSphereVM release theirs.
+func getManagedSnapshot(
+	ctx *context.VMContext,
+	tpl *object.VirtualMachine,
+	pool *object.ResourcePool,
+	datastore *object.Datastore) (_ *object.VirtualMachine, _ *types.ManagedObjectReference, ready bool, _ error) {
+
+	snapshotName := ctx.VSphereVM.Spec.Snapshot
+	if snapshotName == "" {
+		snapshotName = defaultManagedSnapshot
+	}
+
+	unlock := ctx.Session.Lock(tpl.Reference().Value)
+	defer unlock()
+
+	var obj mo.VirtualMachine
+	if err := tpl.Properties(ctx, tpl.Reference(), []string{"name", "parent", "config.changeVersion", "datastore", "snapshot"}, &obj); err != nil {
+		return nil, nil, false, errors.Wrapf(err, "error getting properties of template %s", ctx.VSphereVM.Spec.Template)
+	}
+	if snapshotRef := templateSnapshot(obj, datastore, ctx.VSphereVM.Spec.Snapshot); snapshotRef != nil {
+		return tpl, snapshotRef, true, nil
+	}
+	if obj.Parent == nil {
+		return nil, nil, false, errors.Errorf("template %s is not in a folder", ctx.VSphereVM.Spec.Template)
+	}
+	var changeVersion string
+	if obj.Config != nil {
+		changeVersion = obj.Config.ChangeVersion
+	}
+	name := baseVMName(obj.Name, datastore.Name(), changeVersion)
+
+	if waiting, err := waitForBaseVMTask(ctx, name); err != nil || waiting {
+		return nil, nil, false, err
+	}
+
+	folder := object.NewFolder(ctx.Session.Client.Client, *obj.Parent)
+	ref, err := object.NewSearchIndex(ctx.Session.Client.Client).FindChild(ctx, folder, name)
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "unable to find base vm %s", name)
+	}
+	if ref == nil {
+		ctx.Logger.Info("creating base vm for linked clones", "name", name, "datastore", datastore.Name())
+		task, err := tpl.Clone(ctx, folder, name, types.VirtualMachineCloneSpec{
+			Location: types.VirtualMachineRelocateSpec{
+				Datastore:    types.NewReference(datastore.Reference()),
+				DiskMoveType: string(fullCloneDiskMoveType),
+				Pool:         types.NewReference(pool.Reference()),
+			},
+			PowerOn: false,
+		})
+		if err != nil {
+			return nil, nil, false, errors.Wrapf(err, "error triggering clone op for base vm %s", name)
+		}
+		startBaseVMTask(ctx, name, task)
+		return nil, nil, false, nil
+	}
+
+	vm := object.NewVirtualMachine(ctx.Session.Client.Client, ref.Reference())
+	var vmObj mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"snapshot"}, &vmObj); err != nil {
+		return nil, nil, false, errors.Wrapf(err, "error getting snapshot information for base vm %s", name)
+	}
+	if vmObj.Snapshot != nil {
+		if snapshotRef := findSnapshot(vmObj.Snapshot.RootSnapshotList, snapshotName); snapshotRef != nil {
+			return vm, snapshotRef, true, nil
+		}
+	}
+
+	ctx.Logger.Info("creating snapshot for linked clones", "vm", name, "snapshotName", snapshotName)
+	task, err := vm.CreateSnapshot(ctx, snapshotName, "Created for linked clones by Cluster API Provider vSphere", false, false)
+	if err != nil {
+		return nil, nil, false, errors.Wrapf(err, "error triggering snapshot op for base vm %s", name)
+	}
+	startBaseVMTask(ctx, name, task)
+	return nil, nil, false, nil
+}
+
+// templateSnapshot returns the template's snapshot from which to create linked
+// clones on the datastore without a base VM, or nil if the template is not
+// only on the datastore or does not have the snapshot. Unless a snapshot is
+// named, the default managed snapshot or else the current snapshot is used.
+func templateSnapshot(tpl mo.VirtualMachine, datastore *object.Datastore, snapshotName string) *types.ManagedObjectReference {
+	if tpl.Snapshot == nil || len(tpl.Datastore) == 0 {
+		return nil
+	}
+	for _, ref := range tpl.Datastore {
+		if ref != datastore.Reference() {
+			return nil
+		}
+	}
+	if snapshotName != "" {
+		return findSnapshot(tpl.Snapshot.RootSnapshotList, snapshotName)
+	}
+	if snapshotRef := findSnapshot(tpl.Snapshot.RootSnapshotList, defaultManagedSnapshot); snapshotRef != nil {
+		return snapshotRef
+	}
+	return tpl.Snapshot.CurrentSnapshot
+}
+
+// baseVMName returns the name of the base VM of the template on the datastore,
+// which includes a hash of the template's change version so that a new base VM
+// is created whenever the template changes. The base VMs of earlier versions
+// of the template are left in place, since linked clones depend on them.
+func baseVMName(tplName, datastoreName, changeVersion string) string {
+	version := sha256.Sum256([]byte(changeVersion))
+	return fmt.Sprintf("%s-%s-%s", tplName, datastoreName, hex.EncodeToString(version[:4]))
+}
+
+// startBaseVMTask records the task that clones or snapshots a base VM as the
+// server's in-flight task of the base VM and as the VSphereVM's TaskRef. The
+// in-flight task is forgotten once a VSphereVM that waits for it finds it
+// completed.
+func startBaseVMTask(ctx *context.VMContext, name string, task *object.Task) {
+	ctx.Session.StartTask(name, task.Reference())
+	ctx.VSphereVM.Status.TaskRef = task.Reference().Value
+
+	// patch the vsphereVM early to ensure that the task is
+	// reflected in the status right away
+	if err := ctx.Patch(); err != nil {
+		ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+	}
+}
+
+// waitForBaseVMTask returns true if a task that clones or snapshots the base
+// VM is in flight, in which case the task is recorded as the VSphereVM's
+// TaskRef and the VSphereVM's clone slot is released. A task that is no longer
+// in flight is forgotten.
+func waitForBaseVMTask(ctx *context.VMContext, name string) (bool, error) {
+	taskRef, ok := ctx.Session.InFlightTask(name)
+	if !ok {
+		return false, nil
+	}
+
+	var task mo.Task
+	if err := ctx.Session.RetrieveOne(ctx, taskRef, []string{"info.state"}, &task); err != nil {
+		// The task may no longer exist once it has completed.
+		ctx.Session.FinishTask(taskRef.Value)
+		return false, nil
+	}
+	switch task.Info.State {
+	case types.TaskInfoStateQueued, types.TaskInfoStateRunning:
+		ctx.Logger.Info("waiting for base vm task", "task-ref", taskRef.Value)
+		ctx.Session.ReleaseClone(string(ctx.VSphereVM.UID))
+		ctx.VSphereVM.Status.TaskRef = taskRef.Value
+		if err := ctx.Patch(); err != nil {
+			ctx.Logger.Error(err, "patch failed", "vspherevm", ctx.VSphereVM)
+		}
+		return true, nil
+	default:
+		ctx.Session.FinishTask(taskRef.Value)
+		return false, nil
+	}
+}
+
+// findSnapshot returns the first snapshot in the tree with the given name, or
+// nil if there is none.
+func findSnapshot(tree []types.VirtualMachineSnapshotTree, name string) *types.ManagedObjectReference {
+	for i := range tree {
+		if tree[i].Name == name {
+			return &tree[i].Snapshot
+		}
+		if snapshotRef := findSnapshot(tree[i].ChildSnapshotList, name); snapshotRef != nil {
+			return snapshotRef
+		}
+	}
+	return nil
+}
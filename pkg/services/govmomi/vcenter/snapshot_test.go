@@ -0,0 +1,192 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vcenter
+
+import (
+	ctx "context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/context/fake"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+func TestGetManagedSnapshot(t *testing.T) {
+	model := simulator.VPX()
+	model.Host = 0
+	model.Datastore = 2
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	defer model.Remove()
+	model.Service.TLS = new(tls.Config)
+	server := model.Service.NewServer()
+	defer server.Close()
+	pass, _ := server.URL.User.Password()
+	authSession, err := session.NewManager(session.ManagerOptions{}).GetOrCreate(ctx.TODO(), session.Params{
+		Server:   server.URL.Host,
+		Username: server.URL.User.Username(),
+		Password: pass,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Turn a VM on the first datastore into a template without snapshots.
+	vm := simulator.Map.Any("VirtualMachine").(*simulator.VirtualMachine)
+	tpl := object.NewVirtualMachine(authSession.Client.Client, vm.Reference())
+	task, err := tpl.PowerOff(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tpl.MarkAsTemplate(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := authSession.Finder.DefaultResourcePool(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tplDatastore, err := authSession.Finder.Datastore(ctx.TODO(), simulator.Map.Get(vm.Datastore[0]).(*simulator.Datastore).Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDatastoreName := "LocalDS_1"
+	if tplDatastore.Name() == otherDatastoreName {
+		otherDatastoreName = "LocalDS_0"
+	}
+	otherDatastore, err := authSession.Finder.Datastore(ctx.TODO(), otherDatastoreName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vmContext := fake.NewVMContext(fake.NewControllerContext(fake.NewControllerManagerContext()))
+	vmContext.Session = authSession
+	vmContext.VSphereVM.Spec.Template = vm.Name
+
+	getManagedSnapshotOf := func(datastore *object.Datastore) (mo.VirtualMachine, string) {
+		// The base VM is cloned and then snapshotted by tasks that are
+		// recorded as the VSphereVM's TaskRef rather than waited for.
+		for i := 0; ; i++ {
+			vmContext.VSphereVM.Status.TaskRef = ""
+			source, snapshotRef, ready, err := getManagedSnapshot(vmContext, tpl, pool, datastore)
+			if err != nil {
+				t.Fatalf("Unexpected error from getManagedSnapshot: %v", err)
+			}
+			if ready {
+				var obj mo.VirtualMachine
+				if err := source.Properties(ctx.TODO(), source.Reference(), []string{"name", "datastore", "snapshot"}, &obj); err != nil {
+					t.Fatal(err)
+				}
+				if source.Reference() == tpl.Reference() {
+					return obj, snapshotRef.Value
+				}
+				if obj.Snapshot == nil || findSnapshot(obj.Snapshot.RootSnapshotList, defaultManagedSnapshot) == nil {
+					t.Fatalf("Expected %s to have the %s snapshot", obj.Name, defaultManagedSnapshot)
+				}
+				if *findSnapshot(obj.Snapshot.RootSnapshotList, defaultManagedSnapshot) != *snapshotRef {
+					t.Fatalf("Expected snapshot %v, got %v", findSnapshot(obj.Snapshot.RootSnapshotList, defaultManagedSnapshot), snapshotRef)
+				}
+				return obj, snapshotRef.Value
+			}
+			if i == 2 {
+				t.Fatal("Expected the base vm and its snapshot to be created by two tasks")
+			}
+			if vmContext.VSphereVM.Status.TaskRef == "" {
+				t.Fatal("Expected the VSphereVM to wait for a task")
+			}
+			task := object.NewTask(authSession.Client.Client, types.ManagedObjectReference{Type: "Task", Value: vmContext.VSphereVM.Status.TaskRef})
+			if err := task.Wait(ctx.TODO()); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// A base VM is cloned to each datastore and snapshotted, and the
+	// template itself is left untouched.
+	for _, datastore := range []*object.Datastore{tplDatastore, otherDatastore} {
+		obj, snapshot := getManagedSnapshotOf(datastore)
+		if expected := baseVMName(vm.Name, datastore.Name(), vm.Config.ChangeVersion); obj.Name != expected {
+			t.Errorf("Expected the snapshot of base vm %s, got the snapshot of %s", expected, obj.Name)
+		}
+		if len(obj.Datastore) != 1 || obj.Datastore[0] != datastore.Reference() {
+			t.Errorf("Expected base vm %s to be on datastore %s", obj.Name, datastore.Name())
+		}
+		if _, again := getManagedSnapshotOf(datastore); again != snapshot {
+			t.Errorf("Expected the existing base vm snapshot %s to be reused, got %s", snapshot, again)
+		}
+	}
+	if vm.Snapshot != nil || !vm.Config.Template {
+		t.Errorf("Expected template %s to remain a template without snapshots", vm.Name)
+	}
+
+	// A new base VM is created once the template changes.
+	previous, _ := getManagedSnapshotOf(otherDatastore)
+	vm.Config.ChangeVersion = "changed"
+	if obj, _ := getManagedSnapshotOf(otherDatastore); obj.Name == previous.Name {
+		t.Errorf("Expected a new base vm once the template changed, got %s again", obj.Name)
+	}
+
+	// The snapshot of a template that has one is used on its own datastore,
+	// while a base VM is still created on other datastores.
+	var snapshotted *simulator.VirtualMachine
+	for _, obj := range simulator.Map.All("VirtualMachine") {
+		if obj := obj.(*simulator.VirtualMachine); obj.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn {
+			snapshotted = obj
+			break
+		}
+	}
+	tpl = object.NewVirtualMachine(authSession.Client.Client, snapshotted.Reference())
+	task, err = tpl.CreateSnapshot(ctx.TODO(), "golden", "", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	task, err = tpl.PowerOff(ctx.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	if err := tpl.MarkAsTemplate(ctx.TODO()); err != nil {
+		t.Fatal(err)
+	}
+	vmContext.VSphereVM.Spec.Template = snapshotted.Name
+	tplDatastore = object.NewDatastore(authSession.Client.Client, snapshotted.Datastore[0])
+	if obj, snapshot := getManagedSnapshotOf(tplDatastore); obj.Reference() != tpl.Reference() || snapshot != snapshotted.Snapshot.CurrentSnapshot.Value {
+		t.Errorf("Expected the current snapshot of template %s, got snapshot %s of %s", snapshotted.Name, snapshot, obj.Name)
+	}
+	if otherDatastore.Reference() == tplDatastore.Reference() {
+		otherDatastore, err = authSession.Finder.Datastore(ctx.TODO(), "LocalDS_0")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if obj, _ := getManagedSnapshotOf(otherDatastore); obj.Reference() == tpl.Reference() {
+		t.Errorf("Expected a base vm of template %s on datastore %s", snapshotted.Name, otherDatastore.Name())
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// serverLimits are the limits shared by all of the sessions to a single
+// vSphere server, regardless of the credentials or datacenter they use, along
+// with the server's named locks and in-flight tasks.
+type serverLimits struct {
+	server  string
+	limiter flowcontrol.RateLimiter
+
+	mu        sync.Mutex
+	maxClones int
+	clones    map[string]struct{}
+	locks     map[string]*namedLock
+	tasks     map[string]types.ManagedObjectReference
+}
+
+func newServerLimits(server string, qps float32, burst, maxClones int) *serverLimits {
+	limiter := flowcontrol.NewFakeAlwaysRateLimiter()
+	if qps > 0 {
+		limiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	return &serverLimits{
+		server:    server,
+		limiter:   limiter,
+		maxClones: maxClones,
+		clones:    map[string]struct{}{},
+		locks:     map[string]*namedLock{},
+		tasks:     map[string]types.ManagedObjectReference{},
+	}
+}
+
+// tryAcquireClone reserves an in-flight clone slot for key. It returns false
+// if all of the slots are held by other keys.
+func (l *serverLimits) tryAcquireClone(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.clones[key]; ok {
+		return true
+	}
+	if l.maxClones > 0 && len(l.clones) >= l.maxClones {
+		return false
+	}
+	l.clones[key] = struct{}{}
+	clonesInFlight.WithLabelValues(l.server).Inc()
+	return true
+}
+
+// releaseClone releases the in-flight clone slot held by key, if any.
+func (l *serverLimits) releaseClone(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.clones[key]; !ok {
+		return
+	}
+	delete(l.clones, key)
+	clonesInFlight.WithLabelValues(l.server).Dec()
+}
+
+// wait blocks until the server's rate limiter allows another request, or
+// until the context is done.
+func (l *serverLimits) wait(ctx context.Context) error {
+	if !l.limiter.TryAccept() {
+		throttledRequests.WithLabelValues(l.server).Inc()
+		if err := l.limiter.Wait(ctx); err != nil {
+			return errors.Wrapf(err, "error waiting for the rate limiter of vSphere server %q", l.server)
+		}
+	}
+	return nil
+}
+
+// rateLimitedRoundTripper waits for the server's rate limiter before sending
+// each request, which includes the Finder and PropertyCollector calls as well
+// as the ones that start tasks.
+type rateLimitedRoundTripper struct {
+	soap.RoundTripper
+	limits *serverLimits
+}
+
+// RoundTrip implements the soap.RoundTripper interface.
+func (rt *rateLimitedRoundTripper) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	if err := rt.limits.wait(ctx); err != nil {
+		return err
+	}
+	return rt.RoundTripper.RoundTrip(ctx, req, res)
+}
+
+// rateLimitedTransport waits for the server's rate limiter before sending
+// each request of the vSphere Automation API client, such as the Content
+// Library and tagging calls, which do not go through the SOAP round tripper.
+type rateLimitedTransport struct {
+	http.RoundTripper
+	limits *serverLimits
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limits.wait(req.Context()); err != nil {
+		return nil, err
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// TryAcquireClone reserves one of the server's in-flight clone slots for the
+// object identified by key, such as the UID of a VSphereVM. It returns true
+// if key already holds a slot, and false if all of the slots are held by
+// other keys, in which case the clone should be retried later.
+func (s *Session) TryAcquireClone(key string) bool {
+	if s.limits == nil {
+		return true
+	}
+	return s.limits.tryAcquireClone(key)
+}
+
+// ReleaseClone releases the in-flight clone slot held by key once its clone
+// operation has completed or failed to start. Releasing a key that does not
+// hold a slot is a no-op.
+func (s *Session) ReleaseClone(key string) {
+	if s.limits == nil {
+		return
+	}
+	s.limits.releaseClone(key)
+}
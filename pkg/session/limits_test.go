@@ -0,0 +1,122 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	_ "github.com/vmware/govmomi/vapi/simulator"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+func TestManagerLimitsConcurrentClones(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, params := newSimulator(t)
+
+	m := NewManager(ManagerOptions{MaxConcurrentClones: 2})
+
+	s1, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The limit is shared by all of the sessions to the server.
+	params.Datacenter = "DC0"
+	s2, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s2).NotTo(BeIdenticalTo(s1))
+
+	g.Expect(s1.TryAcquireClone("vm-1")).To(BeTrue())
+	g.Expect(s2.TryAcquireClone("vm-2")).To(BeTrue())
+	g.Expect(s1.TryAcquireClone("vm-3")).To(BeFalse())
+
+	// A key that already holds a slot keeps it.
+	g.Expect(s2.TryAcquireClone("vm-1")).To(BeTrue())
+
+	s2.ReleaseClone("vm-1")
+	s2.ReleaseClone("vm-1")
+	g.Expect(s1.TryAcquireClone("vm-3")).To(BeTrue())
+	g.Expect(s1.TryAcquireClone("vm-4")).To(BeFalse())
+
+	// Evicting the sessions does not reset the limit.
+	g.Expect(m.DeleteSessionsForUser(ctx, params.Username)).To(Equal(2))
+	s3, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s3.TryAcquireClone("vm-4")).To(BeFalse())
+}
+
+func TestManagerLimitsRequestRate(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, params := newSimulator(t)
+
+	m := NewManager(ManagerOptions{QPS: 10, Burst: 1})
+	s, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := s.SessionManager.UserSession(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+	}
+	g.Expect(time.Since(start)).To(BeNumerically(">=", time.Millisecond*400))
+
+	// The rate limiter respects the context's deadline.
+	deadline, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		_, err = s.SessionManager.UserSession(deadline)
+	}
+	g.Expect(err).To(HaveOccurred())
+}
+
+// countingRateLimiter counts the tokens taken from the rate limiter it wraps.
+type countingRateLimiter struct {
+	flowcontrol.RateLimiter
+	tokens int
+}
+
+func (l *countingRateLimiter) TryAccept() bool {
+	l.tokens++
+	return l.RateLimiter.TryAccept()
+}
+
+func TestManagerLimitsRestRequestRate(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, params := newSimulator(t)
+
+	m := NewManager(ManagerOptions{QPS: 100, Burst: 100})
+	s, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	limiter := &countingRateLimiter{RateLimiter: s.limits.limiter}
+	s.limits.limiter = limiter
+
+	// Logging into the vSphere Automation API takes a token.
+	restClient, err := s.RestClient(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(limiter.tokens).To(Equal(1))
+
+	// So does every vSphere Automation API call.
+	tokens := limiter.tokens
+	_, err = restClient.Session(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(limiter.tokens).To(Equal(tokens + 1))
+}
@@ -0,0 +1,444 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// DefaultMaxSessions is the default value for the eponymous option.
+	DefaultMaxSessions = 100
+
+	// DefaultIdleTimeout is the default value for the eponymous option.
+	DefaultIdleTimeout = time.Minute * 30
+
+	// DefaultKeepAliveInterval is the default value for the eponymous option.
+	DefaultKeepAliveInterval = time.Minute * 5
+
+	// DefaultQPS is the default value for the eponymous option.
+	DefaultQPS = 20
+
+	// DefaultBurst is the default value for the eponymous option.
+	DefaultBurst = 40
+
+	// DefaultMaxConcurrentClones is the default value for the eponymous option.
+	DefaultMaxConcurrentClones = 10
+
+	// logoutTimeout bounds how long logging out an evicted session may take.
+	logoutTimeout = time.Second * 30
+)
+
+// CredentialSource identifies where the credentials of a session come from.
+type CredentialSource string
+
+const (
+	// ManagerCredentials are the controller manager's credentials. They are
+	// the default source.
+	ManagerCredentials CredentialSource = ""
+
+	// IdentityCredentials are the credentials of a VSphereClusterIdentity.
+	IdentityCredentials CredentialSource = "identity"
+)
+
+// Params describe the vSphere server, credentials and trust settings used to
+// get or create a session.
+type Params struct {
+	Server     string
+	Datacenter string
+	Username   string
+	Password   string
+	Trust      Trust
+
+	// Source is where the username and password come from.
+	Source CredentialSource
+}
+
+// key returns the key used to cache sessions created with the params. The
+// trust settings are part of the key so a session is never shared between
+// callers that verify the server differently, and so is a hash of the
+// password so a session is never shared with a caller that has the wrong
+// password for the same username.
+func (p Params) key() string {
+	trust := sha256.New()
+	_, _ = trust.Write([]byte(strings.ToUpper(strings.ReplaceAll(p.Trust.Thumbprint, ":", ""))))
+	_, _ = trust.Write([]byte{0})
+	_, _ = trust.Write(p.Trust.CABundle)
+	password := sha256.Sum256([]byte(p.Password))
+	return strings.Join([]string{
+		string(p.Source),
+		p.Server,
+		p.Datacenter,
+		p.Username,
+		hex.EncodeToString(password[:]),
+		hex.EncodeToString(trust.Sum(nil)),
+	}, "/")
+}
+
+// Provider gets or creates authenticated sessions to vSphere servers.
+type Provider interface {
+	// GetOrCreate returns a cached, active session for the params, or logs in
+	// and caches a new one. The caller must release the session once it is
+	// done with it, so that it can be evicted.
+	GetOrCreate(ctx context.Context, params Params) (*Session, error)
+
+	// DeleteSessionsForUser removes all of the cached sessions that were
+	// created with the provided username from the controller manager's
+	// credentials, and logs them out once they are released. Sessions created
+	// from the credentials of a VSphereClusterIdentity are kept. It returns
+	// the number of sessions that were removed.
+	DeleteSessionsForUser(ctx context.Context, username string) int
+}
+
+// ManagerOptions are the options used to create a Manager.
+type ManagerOptions struct {
+	// MaxSessions is the maximum number of cached sessions. The least
+	// recently used session that is not in use is logged out when the limit
+	// is exceeded.
+	//
+	// Defaults to the eponymous constant in this package.
+	MaxSessions int
+
+	// IdleTimeout is how long a cached session may go unused before it is
+	// logged out.
+	//
+	// Defaults to the eponymous constant in this package.
+	IdleTimeout time.Duration
+
+	// KeepAliveInterval is how often an idle session is kept alive so the
+	// vSphere server does not expire it.
+	//
+	// Defaults to the eponymous constant in this package.
+	KeepAliveInterval time.Duration
+
+	// QPS is the sustained number of requests per second sent to each vSphere
+	// server, shared by all of the sessions to the server. A negative value
+	// disables rate limiting.
+	//
+	// Defaults to the eponymous constant in this package.
+	QPS float32
+
+	// Burst is the number of requests that may be sent to each vSphere server
+	// in excess of the QPS.
+	//
+	// Defaults to the eponymous constant in this package.
+	Burst int
+
+	// MaxConcurrentClones is the maximum number of in-flight clone operations
+	// on each vSphere server. A negative value removes the limit.
+	//
+	// Defaults to the eponymous constant in this package.
+	MaxConcurrentClones int
+
+	Logger logr.Logger
+}
+
+// Manager is a Provider that caches sessions, keeps them alive and logs out
+// the ones that are no longer used.
+type Manager struct {
+	opts ManagerOptions
+
+	mu       sync.Mutex
+	lru      *list.List
+	sessions map[string]*list.Element
+
+	// keyLocks serialize getting or creating the session of each key.
+	keyLocks map[string]*keyLock
+
+	// limits are the per-server limits. They outlive the sessions so that
+	// evicting a session does not reset the server's limits.
+	limits map[string]*serverLimits
+
+	// now is used to determine whether sessions are idle; it is replaced in
+	// tests.
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	key      string
+	server   string
+	source   CredentialSource
+	session  *Session
+	lastUsed time.Time
+}
+
+type keyLock struct {
+	mu      sync.Mutex
+	waiters int
+}
+
+var _ Provider = &Manager{}
+
+// NewManager returns a new session Manager.
+func NewManager(opts ManagerOptions) *Manager {
+	if opts.MaxSessions <= 0 {
+		opts.MaxSessions = DefaultMaxSessions
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = DefaultIdleTimeout
+	}
+	if opts.KeepAliveInterval <= 0 {
+		opts.KeepAliveInterval = DefaultKeepAliveInterval
+	}
+	if opts.QPS == 0 {
+		opts.QPS = DefaultQPS
+	}
+	if opts.Burst <= 0 {
+		opts.Burst = DefaultBurst
+	}
+	if opts.MaxConcurrentClones == 0 {
+		opts.MaxConcurrentClones = DefaultMaxConcurrentClones
+	}
+	if opts.Logger == nil {
+		opts.Logger = ctrllog.Log.WithName("session-manager")
+	}
+	return &Manager{
+		opts:     opts,
+		lru:      list.New(),
+		sessions: map[string]*list.Element{},
+		keyLocks: map[string]*keyLock{},
+		limits:   map[string]*serverLimits{},
+		now:      time.Now,
+	}
+}
+
+// GetOrCreate implements Provider. The returned session is held by the caller
+// until it calls Release on it.
+func (m *Manager) GetOrCreate(ctx context.Context, params Params) (*Session, error) {
+	key := params.key()
+
+	// Only the lock of the key is held while talking to the vSphere server,
+	// so a slow server does not block the callers of other sessions.
+	unlock := m.lockKey(key)
+	defer unlock()
+
+	m.mu.Lock()
+	elem, ok := m.sessions[key]
+	if ok {
+		elem.Value.(*cacheEntry).session.Retain()
+	}
+	limits := m.limitsFor(params.Server)
+	m.mu.Unlock()
+
+	if ok {
+		entry := elem.Value.(*cacheEntry)
+		active, _ := entry.session.SessionManager.SessionIsActive(ctx)
+
+		m.mu.Lock()
+		// The session may have been removed from the cache by
+		// DeleteSessionsForUser while its lock was not held.
+		cached := m.sessions[key] == elem
+		if active && cached {
+			entry.lastUsed = m.now()
+			m.lru.MoveToFront(elem)
+			m.mu.Unlock()
+			return entry.session, nil
+		}
+		var evicted []*cacheEntry
+		if cached {
+			evicted = append(evicted, m.remove(elem))
+		}
+		m.mu.Unlock()
+
+		entry.session.Release()
+		m.logout(evicted)
+	}
+
+	session, err := newSession(ctx, params, m.opts.KeepAliveInterval, limits)
+	if err != nil {
+		return nil, err
+	}
+	session.Retain()
+
+	m.mu.Lock()
+	m.sessions[key] = m.lru.PushFront(&cacheEntry{
+		key:      key,
+		server:   params.Server,
+		source:   params.Source,
+		session:  session,
+		lastUsed: m.now(),
+	})
+	activeSessions.WithLabelValues(params.Server).Inc()
+	m.opts.Logger.V(2).Info("cached vSphere client session",
+		"server", params.Server, "datacenter", params.Datacenter, "username", params.Username)
+
+	// Sessions that are in use are never evicted, so the cache may hold more
+	// than MaxSessions until they are released.
+	var evicted []*cacheEntry
+	for elem := m.lru.Back(); elem != nil && m.lru.Len() > m.opts.MaxSessions; {
+		prev := elem.Prev()
+		if !elem.Value.(*cacheEntry).session.inUse() {
+			evicted = append(evicted, m.remove(elem))
+		}
+		elem = prev
+	}
+	m.mu.Unlock()
+
+	m.logout(evicted)
+	return session, nil
+}
+
+// DeleteSessionsForUser implements Provider.
+func (m *Manager) DeleteSessionsForUser(ctx context.Context, username string) int {
+	m.mu.Lock()
+	var evicted []*cacheEntry
+	for elem := m.lru.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*cacheEntry)
+		if entry.source == ManagerCredentials && entry.session.username == username {
+			evicted = append(evicted, m.remove(elem))
+		}
+		elem = next
+	}
+	m.mu.Unlock()
+
+	m.logout(evicted)
+	return len(evicted)
+}
+
+// Len returns the number of cached sessions.
+func (m *Manager) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// Start implements the manager.Runnable interface. It periodically logs out
+// the sessions that have been idle for longer than the IdleTimeout, and logs
+// out all sessions when stopped.
+func (m *Manager) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(m.opts.IdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.evictIdle()
+		case <-stop:
+			m.mu.Lock()
+			var evicted []*cacheEntry
+			for m.lru.Len() > 0 {
+				evicted = append(evicted, m.remove(m.lru.Back()))
+			}
+			m.mu.Unlock()
+			m.logout(evicted)
+			return nil
+		}
+	}
+}
+
+// NeedLeaderElection implements the manager.LeaderElectionRunnable interface.
+func (m *Manager) NeedLeaderElection() bool {
+	return false
+}
+
+// evictIdle logs out the sessions that have been idle for longer than the
+// IdleTimeout and have no users.
+func (m *Manager) evictIdle() {
+	m.mu.Lock()
+	var evicted []*cacheEntry
+	deadline := m.now().Add(-m.opts.IdleTimeout)
+	for elem := m.lru.Back(); elem != nil; {
+		prev := elem.Prev()
+		entry := elem.Value.(*cacheEntry)
+		if entry.lastUsed.After(deadline) {
+			// The list is ordered by use, so the remaining sessions were
+			// used more recently.
+			break
+		}
+		if !entry.session.inUse() {
+			evicted = append(evicted, m.remove(elem))
+		}
+		elem = prev
+	}
+	m.mu.Unlock()
+
+	m.logout(evicted)
+}
+
+// lockKey locks the key, and returns the func that unlocks it.
+func (m *Manager) lockKey(key string) func() {
+	m.mu.Lock()
+	l, ok := m.keyLocks[key]
+	if !ok {
+		l = &keyLock{}
+		m.keyLocks[key] = l
+	}
+	l.waiters++
+	m.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		l.waiters--
+		if l.waiters == 0 {
+			delete(m.keyLocks, key)
+		}
+	}
+}
+
+// limitsFor returns the limits for the server, creating them if necessary. The
+// caller must hold the lock.
+func (m *Manager) limitsFor(server string) *serverLimits {
+	limits, ok := m.limits[server]
+	if !ok {
+		limits = newServerLimits(server, m.opts.QPS, m.opts.Burst, m.opts.MaxConcurrentClones)
+		m.limits[server] = limits
+	}
+	return limits
+}
+
+// remove removes the element from the cache. The caller must hold the lock.
+func (m *Manager) remove(elem *list.Element) *cacheEntry {
+	entry := m.lru.Remove(elem).(*cacheEntry)
+	delete(m.sessions, entry.key)
+	activeSessions.WithLabelValues(entry.server).Dec()
+	return entry
+}
+
+// logout logs out of the evicted sessions, which also stops their keepalive.
+// Sessions that are still in use are logged out once they are released.
+func (m *Manager) logout(evicted []*cacheEntry) {
+	for _, entry := range evicted {
+		entry := entry
+		if entry.session.logoutWhenReleased(func() { m.logoutEntry(entry) }) {
+			m.logoutEntry(entry)
+		}
+	}
+}
+
+// logoutEntry logs out of an evicted session. The session may already be
+// invalid, so logging out is best effort.
+func (m *Manager) logoutEntry(entry *cacheEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+	defer cancel()
+	if err := entry.session.Logout(ctx); err != nil {
+		m.opts.Logger.V(4).Info("failed to logout evicted session", "server", entry.server, "error", err.Error())
+	}
+	m.opts.Logger.V(2).Info("evicted vSphere client session", "server", entry.server)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"crypto/sha1" // nolint:gosec
+	"crypto/tls"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/simulator"
+)
+
+func newSimulator(t *testing.T) (*simulator.Server, Params) {
+	model := simulator.VPX()
+	model.Host = 0
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+	model.Service.RegisterEndpoints = true
+	server := model.Service.NewServer()
+	t.Cleanup(func() {
+		server.Close()
+		model.Remove()
+	})
+
+	password, _ := server.URL.User.Password()
+	return server, Params{
+		Server:   server.URL.Host,
+		Username: server.URL.User.Username(),
+		Password: password,
+	}
+}
+
+func TestManagerGetOrCreate(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	server, params := newSimulator(t)
+
+	m := NewManager(ManagerOptions{})
+
+	s1, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	s2, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s2).To(BeIdenticalTo(s1))
+	g.Expect(m.Len()).To(Equal(1))
+
+	// Sessions verified with different trust settings are not shared.
+	sum := sha1.Sum(server.Certificate().Raw) // nolint:gosec
+	params.Trust = Trust{Thumbprint: thumbprint(sum[:])}
+	s3, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s3).NotTo(BeIdenticalTo(s1))
+	g.Expect(m.Len()).To(Equal(2))
+
+	// Sessions created with different passwords are not shared.
+	password := params.Password
+	params.Password = "another-" + password
+	s5, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s5).NotTo(BeIdenticalTo(s3))
+	g.Expect(m.Len()).To(Equal(3))
+	params.Password = password
+
+	// A session that is no longer active is replaced.
+	g.Expect(s3.Logout(ctx)).To(Succeed())
+	s4, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s4).NotTo(BeIdenticalTo(s3))
+	g.Expect(m.Len()).To(Equal(3))
+}
+
+func TestManagerEvictsLeastRecentlyUsed(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, params := newSimulator(t)
+
+	m := NewManager(ManagerOptions{MaxSessions: 1})
+
+	first, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Sessions in use are not evicted.
+	params.Datacenter = "DC0"
+	second, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(m.Len()).To(Equal(2))
+
+	first.Release()
+	second.Release()
+	params.Datacenter = "/DC0"
+	_, err = m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(m.Len()).To(Equal(1))
+
+	for _, s := range []*Session{first, second} {
+		active, err := s.SessionManager.SessionIsActive(ctx)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(active).To(BeFalse())
+	}
+}
+
+func TestManagerEvictsIdleSessions(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, params := newSimulator(t)
+
+	now := time.Now()
+	m := NewManager(ManagerOptions{IdleTimeout: time.Minute})
+	m.now = func() time.Time { return now }
+
+	idle, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	now = now.Add(time.Second * 30)
+	params.Datacenter = "DC0"
+	recent, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	recent.Release()
+
+	// Idle sessions in use are not evicted.
+	now = now.Add(time.Second * 45)
+	m.evictIdle()
+	g.Expect(m.Len()).To(Equal(2))
+
+	idle.Release()
+	m.evictIdle()
+	g.Expect(m.Len()).To(Equal(1))
+
+	active, err := idle.SessionManager.SessionIsActive(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(active).To(BeFalse())
+}
+
+func TestManagerDeleteSessionsForUser(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, params := newSimulator(t)
+
+	m := NewManager(ManagerOptions{})
+	s, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// Sessions created from the credentials of an identity are kept.
+	params.Source = IdentityCredentials
+	identitySession, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(identitySession).NotTo(BeIdenticalTo(s))
+	g.Expect(m.Len()).To(Equal(2))
+
+	g.Expect(m.DeleteSessionsForUser(ctx, "someone-else")).To(Equal(0))
+	g.Expect(m.DeleteSessionsForUser(ctx, params.Username)).To(Equal(1))
+	g.Expect(m.Len()).To(Equal(1))
+
+	// The session is logged out once it is released.
+	active, err := s.SessionManager.SessionIsActive(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(active).To(BeTrue())
+
+	s.Release()
+	active, err = s.SessionManager.SessionIsActive(ctx)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(active).To(BeFalse())
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// activeSessions is the number of cached vSphere sessions per server.
+	activeSessions = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "capv",
+			Subsystem: "vsphere",
+			Name:      "sessions_active",
+			Help:      "Number of cached vSphere sessions per server.",
+		},
+		[]string{"server"},
+	)
+
+	// clonesInFlight is the number of in-flight clone operations per server.
+	clonesInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "capv",
+			Subsystem: "vsphere",
+			Name:      "clones_in_flight",
+			Help:      "Number of in-flight clone operations per server.",
+		},
+		[]string{"server"},
+	)
+
+	// throttledRequests is the number of requests per server that had to wait
+	// for the client-side rate limiter.
+	throttledRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "capv",
+			Subsystem: "vsphere",
+			Name:      "requests_throttled_total",
+			Help:      "Number of requests per server that waited for the client-side rate limiter.",
+		},
+		[]string{"server"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(activeSessions, clonesInFlight, throttledRequests)
+}
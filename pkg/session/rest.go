@@ -0,0 +1,67 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/vmware/govmomi/vapi/rest"
+)
+
+// RestClient returns a client for the vSphere Automation API, such as the
+// Content Library API, that is logged in with the session's credentials.
+// The client is created on first use, and logged in again if its session
+// has expired. Its requests are subject to the server's rate limit.
+func (s *Session) RestClient(ctx context.Context) (*rest.Client, error) {
+	s.restMu.Lock()
+	defer s.restMu.Unlock()
+
+	if s.restClient != nil {
+		active, err := s.restClient.Session(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "error checking vSphere Automation API session")
+		}
+		if active != nil {
+			return s.restClient, nil
+		}
+	}
+
+	restClient := rest.NewClient(s.Client.Client)
+	if s.limits != nil {
+		restClient.Transport = &rateLimitedTransport{RoundTripper: restClient.Transport, limits: s.limits}
+	}
+	if err := restClient.Login(ctx, s.userInfo); err != nil {
+		return nil, errors.Wrap(err, "error logging into the vSphere Automation API")
+	}
+	s.restClient = restClient
+	return restClient, nil
+}
+
+// Logout logs out of the vSphere Automation API, if the session has logged
+// into it, and then out of the vSphere server.
+func (s *Session) Logout(ctx context.Context) error {
+	s.restMu.Lock()
+	restClient := s.restClient
+	s.restClient = nil
+	s.restMu.Unlock()
+
+	if restClient != nil {
+		_ = restClient.Logout(ctx)
+	}
+	return s.Client.Logout(ctx)
+}
@@ -20,92 +20,147 @@ import (
 	"context"
 	"net/url"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/session/keepalive"
+	"github.com/vmware/govmomi/vapi/rest"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/soap"
 
 	"sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
 )
 
-var sessionCache = map[string]Session{}
-var sessionMU sync.Mutex
-
 // Session is a vSphere session with a configured Finder.
 type Session struct {
 	*govmomi.Client
 	Finder     *find.Finder
 	datacenter *object.Datacenter
+	username   string
+	limits     *serverLimits
+
+	// userInfo holds the credentials used to log into the vSphere
+	// Automation API, which is only done once restClient is first used.
+	userInfo   *url.Userinfo
+	restMu     sync.Mutex
+	restClient *rest.Client
+
+	// users is the number of callers holding the session. A session removed
+	// from the Manager's cache while it has users is only logged out, by
+	// calling logout, once the last user releases it.
+	usersMu sync.Mutex
+	users   int
+	logout  func()
 }
 
-// GetOrCreate gets a cached session or creates a new one if one does not
-// already exist.
-func GetOrCreate(
-	ctx context.Context,
-	server, datacenter, username, password string, thumbprint string) (*Session, error) {
-
-	sessionMU.Lock()
-	defer sessionMU.Unlock()
-
-	sessionKey := server + username + datacenter
-	if session, ok := sessionCache[sessionKey]; ok {
-		if ok, _ := session.SessionManager.SessionIsActive(ctx); ok {
-			return &session, nil
-		}
-	}
-
-	soapURL, err := soap.ParseURL(server)
+// newSession logs into the vSphere server and returns a new session. The
+// session is kept alive by sending a request to the server whenever it has
+// been idle for the keepAliveInterval, and its requests are subject to the
+// server's limits.
+func newSession(ctx context.Context, params Params, keepAliveInterval time.Duration, limits *serverLimits) (*Session, error) {
+	soapURL, err := soap.ParseURL(params.Server)
 	if err != nil {
-		return nil, errors.Wrapf(err, "error parsing vSphere URL %q", server)
+		return nil, errors.Wrapf(err, "error parsing vSphere URL %q", params.Server)
 	}
 	if soapURL == nil {
-		return nil, errors.Errorf("error parsing vSphere URL %q", server)
+		return nil, errors.Errorf("error parsing vSphere URL %q", params.Server)
 	}
 
-	soapURL.User = url.UserPassword(username, password)
-	client, err := newClient(ctx, soapURL, thumbprint)
+	soapURL.User = url.UserPassword(params.Username, params.Password)
+	client, err := newClient(ctx, soapURL, params.Trust, keepAliveInterval, limits)
 	if err != nil {
 		return nil, err
 	}
 
-	session := Session{Client: client}
+	session := &Session{Client: client, username: params.Username, limits: limits, userInfo: soapURL.User}
 	session.UserAgent = v1alpha3.GroupVersion.String()
 
 	// Assign the finder to the session.
 	session.Finder = find.NewFinder(session.Client.Client, false)
 
 	// Assign the datacenter if one was specified.
-	dc, err := session.Finder.DatacenterOrDefault(ctx, datacenter)
+	dc, err := session.Finder.DatacenterOrDefault(ctx, params.Datacenter)
 	if err != nil {
-		return nil, errors.Wrapf(err, "unable to find datacenter %q", datacenter)
+		_ = session.Logout(ctx)
+		return nil, errors.Wrapf(err, "unable to find datacenter %q", params.Datacenter)
 	}
 	session.datacenter = dc
 	session.Finder.SetDatacenter(dc)
 
-	// Cache the session.
-	sessionCache[sessionKey] = session
+	return session, nil
+}
 
-	// TODO(akutz) Reintroduce the logger.
-	//ctx.Logger.V(2).Info("cached vSphere client session", "server", server, "datacenter", datacenter)
+// Retain adds a user of the session, which prevents the Manager from logging
+// out the session until the user calls Release. Callers of GetOrCreate already
+// hold the session, so Retain is only needed to use the session beyond that,
+// for example in a goroutine.
+func (s *Session) Retain() {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	s.users++
+}
+
+// Release removes a user of the session. The session must not be used by the
+// caller afterwards.
+func (s *Session) Release() {
+	s.usersMu.Lock()
+	s.users--
+	var logout func()
+	if s.users == 0 {
+		logout, s.logout = s.logout, nil
+	}
+	s.usersMu.Unlock()
 
-	return &session, nil
+	if logout != nil {
+		logout()
+	}
+}
+
+// inUse returns whether the session has users.
+func (s *Session) inUse() bool {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	return s.users > 0
 }
 
-func newClient(ctx context.Context, url *url.URL, thumprint string) (*govmomi.Client, error) {
-	insecure := thumprint == ""
+// logoutWhenReleased returns true if the session has no users and can be
+// logged out right away. Otherwise logout is called once the last user
+// releases the session.
+func (s *Session) logoutWhenReleased(logout func()) bool {
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	if s.users > 0 {
+		s.logout = logout
+		return false
+	}
+	return true
+}
+
+func newClient(ctx context.Context, url *url.URL, trust Trust, keepAliveInterval time.Duration, limits *serverLimits) (*govmomi.Client, error) {
+	insecure := trust.IsInsecure()
 	soapClient := soap.NewClient(url, insecure)
 	if !insecure {
-		soapClient.SetThumbprint(url.Host, thumprint)
+		tlsConfig, err := trust.TLSConfig(url.Host)
+		if err != nil {
+			return nil, err
+		}
+		soapClient.DefaultTransport().TLSClientConfig = tlsConfig
 	}
 
 	vimClient, err := vim25.NewClient(ctx, soapClient)
 	if err != nil {
 		return nil, err
 	}
+	if limits != nil {
+		vimClient.RoundTripper = &rateLimitedRoundTripper{RoundTripper: vimClient.RoundTripper, limits: limits}
+	}
+	if keepAliveInterval > 0 {
+		vimClient.RoundTripper = keepalive.NewHandlerSOAP(vimClient.RoundTripper, keepAliveInterval, nil)
+	}
 	c := &govmomi.Client{
 		Client:         vimClient,
 		SessionManager: session.NewManager(vimClient),
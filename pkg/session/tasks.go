@@ -0,0 +1,98 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"sync"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// namedLock is a mutex that is removed from the server's locks once it is
+// neither held nor waited for.
+type namedLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock locks the mutex that is shared by the sessions to the server under
+// name, such as the managed object ID of a template, and returns the function
+// that unlocks it. Sessions that are not created by a Manager have no server
+// state, so their locks do not exclude each other.
+func (s *Session) Lock(name string) (unlock func()) {
+	if s.limits == nil {
+		return func() {}
+	}
+	l := s.limits
+
+	l.mu.Lock()
+	lock, ok := l.locks[name]
+	if !ok {
+		lock = &namedLock{}
+		l.locks[name] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(l.locks, name)
+		}
+	}
+}
+
+// StartTask records the task as the server's in-flight task under name, such
+// as the name of the VM the task creates, so that the sessions that need the
+// outcome of the same operation wait for the same task.
+func (s *Session) StartTask(name string, task types.ManagedObjectReference) {
+	if s.limits == nil {
+		return
+	}
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	s.limits.tasks[name] = task
+}
+
+// InFlightTask returns the server's in-flight task recorded under name, if any.
+func (s *Session) InFlightTask(name string) (types.ManagedObjectReference, bool) {
+	if s.limits == nil {
+		return types.ManagedObjectReference{}, false
+	}
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	task, ok := s.limits.tasks[name]
+	return task, ok
+}
+
+// FinishTask forgets the task, identified by its managed object ID, once it
+// is no longer in flight. Finishing a task that is not recorded is a no-op.
+func (s *Session) FinishTask(taskID string) {
+	if s.limits == nil || taskID == "" {
+		return
+	}
+	s.limits.mu.Lock()
+	defer s.limits.mu.Unlock()
+	for name, task := range s.limits.tasks {
+		if task.Value == taskID {
+			delete(s.limits.tasks, name)
+		}
+	}
+}
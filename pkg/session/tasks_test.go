@@ -0,0 +1,62 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestSessionLocksAndTasks(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, params := newSimulator(t)
+
+	m := NewManager(ManagerOptions{})
+	s1, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+	params.Datacenter = "DC0"
+	s2, err := m.GetOrCreate(ctx, params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The locks are shared by all of the sessions to the server, and they
+	// are removed once they are no longer held.
+	unlock := s1.Lock("vm-1")
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		s2.Lock("vm-1")()
+	}()
+	g.Consistently(locked).ShouldNot(BeClosed())
+	unlock()
+	g.Eventually(locked).Should(BeClosed())
+	g.Expect(s1.limits.locks).To(BeEmpty())
+
+	// So are the in-flight tasks, which are forgotten once they finish.
+	task := types.ManagedObjectReference{Type: "Task", Value: "task-1"}
+	s1.StartTask("base-vm", task)
+	inFlight, ok := s2.InFlightTask("base-vm")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(inFlight).To(Equal(task))
+	s2.FinishTask(task.Value)
+	_, ok = s1.InFlightTask("base-vm")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(s1.limits.tasks).To(BeEmpty())
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package session
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTrustTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cert := server.Certificate()
+	sha1Sum := sha1.Sum(cert.Raw) // nolint:gosec
+	sha256Sum := sha256.Sum256(cert.Raw)
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+
+	otherCABundle := newCABundle(t)
+
+	testCases := []struct {
+		name         string
+		trust        Trust
+		expectConfig bool
+		expectDial   bool
+	}{
+		{
+			name:         "insecure",
+			trust:        Trust{},
+			expectConfig: true,
+			expectDial:   true,
+		},
+		{
+			name:         "matching SHA-1 thumbprint",
+			trust:        Trust{Thumbprint: thumbprint(sha1Sum[:])},
+			expectConfig: true,
+			expectDial:   true,
+		},
+		{
+			name:         "matching SHA-256 thumbprint",
+			trust:        Trust{Thumbprint: thumbprint(sha256Sum[:])},
+			expectConfig: true,
+			expectDial:   true,
+		},
+		{
+			name:         "lower-case SHA-256 thumbprint",
+			trust:        Trust{Thumbprint: strings.ToLower(thumbprint(sha256Sum[:]))},
+			expectConfig: true,
+			expectDial:   true,
+		},
+		{
+			name:         "mismatched thumbprint",
+			trust:        Trust{Thumbprint: thumbprint(make([]byte, sha256.Size))},
+			expectConfig: true,
+			expectDial:   false,
+		},
+		{
+			name:         "thumbprint of unsupported length",
+			trust:        Trust{Thumbprint: "AA:BB:CC"},
+			expectConfig: false,
+		},
+		{
+			name:         "CA bundle containing the server certificate",
+			trust:        Trust{CABundle: caBundle},
+			expectConfig: true,
+			expectDial:   true,
+		},
+		{
+			name:         "CA bundle not containing the server certificate",
+			trust:        Trust{CABundle: otherCABundle},
+			expectConfig: true,
+			expectDial:   false,
+		},
+		{
+			name:         "mismatched thumbprint with a valid CA bundle",
+			trust:        Trust{Thumbprint: thumbprint(make([]byte, sha1.Size)), CABundle: caBundle},
+			expectConfig: true,
+			expectDial:   true,
+		},
+		{
+			name:         "invalid CA bundle",
+			trust:        Trust{CABundle: []byte("invalid")},
+			expectConfig: false,
+		},
+	}
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			tlsConfig, err := tc.trust.TLSConfig(serverURL.Host)
+			if !tc.expectConfig {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+			resp, err := client.Get(server.URL)
+			if !tc.expectDial {
+				g.Expect(err).To(HaveOccurred())
+				return
+			}
+			g.Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+		})
+	}
+}
+
+// newCABundle returns a PEM-encoded, self-signed CA certificate that did not
+// sign the test server's certificate.
+func newCABundle(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func thumbprint(sum []byte) string {
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
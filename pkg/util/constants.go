@@ -87,3 +87,49 @@ network:
   {{- end }}
   {{- end }}
 `
+
+// networkdNetworkFormat is the systemd-networkd network unit that configures
+// one of a machine's network devices when the machine is bootstrapped with
+// Ignition.
+const networkdNetworkFormat = `[Match]
+MACAddress={{ .Device.MACAddr }}
+
+[Network]
+DHCP={{ dhcp .Device }}
+{{- range .Device.IPAddrs }}
+Address={{ . }}
+{{- end }}
+{{- if .Device.Gateway4 }}
+Gateway={{ .Device.Gateway4 }}
+{{- end }}
+{{- if .Device.Gateway6 }}
+Gateway={{ .Device.Gateway6 }}
+{{- end }}
+{{- range .Device.Nameservers }}
+DNS={{ . }}
+{{- end }}
+{{- if .Device.SearchDomains }}
+Domains={{ join .Device.SearchDomains " " }}
+{{- end }}
+{{- if .Device.MTU }}
+
+[Link]
+MTUBytes={{ .Device.MTU }}
+{{- end }}
+{{- range .Routes }}
+
+[Route]
+Destination={{ .To }}
+Gateway={{ .Via }}
+Metric={{ .Metric }}
+{{- end }}
+`
+
+// networkdLinkFormat is the systemd-networkd link unit that names one of a
+// machine's network devices when the machine is bootstrapped with Ignition.
+const networkdLinkFormat = `[Match]
+MACAddress={{ .MACAddr }}
+
+[Link]
+Name={{ .Name }}
+`
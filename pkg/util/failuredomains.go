@@ -0,0 +1,93 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+)
+
+// GetFailureDomains returns the VSphereCluster's failure domains in the form
+// published in its Status.FailureDomains. The attributes of each failure
+// domain are the vSphere objects it is mapped to.
+func GetFailureDomains(vsphereCluster *infrav1.VSphereCluster) clusterv1.FailureDomains {
+	if len(vsphereCluster.Spec.FailureDomains) == 0 {
+		return nil
+	}
+	failureDomains := clusterv1.FailureDomains{}
+	for _, failureDomain := range vsphereCluster.Spec.FailureDomains {
+		attributes := map[string]string{}
+		for key, value := range map[string]string{
+			"datacenter":     failureDomain.Datacenter,
+			"computeCluster": failureDomain.ComputeCluster,
+			"hostGroup":      failureDomain.HostGroup,
+			"datastore":      failureDomain.Datastore,
+		} {
+			if value != "" {
+				attributes[key] = value
+			}
+		}
+		if len(attributes) == 0 {
+			attributes = nil
+		}
+		failureDomains[failureDomain.Name] = clusterv1.FailureDomainSpec{
+			ControlPlane: failureDomain.ControlPlane == nil || *failureDomain.ControlPlane,
+			Attributes:   attributes,
+		}
+	}
+	return failureDomains
+}
+
+// GetFailureDomain returns the VSphereCluster's failure domain with the
+// provided name.
+func GetFailureDomain(vsphereCluster *infrav1.VSphereCluster, name string) (*infrav1.VSphereFailureDomain, error) {
+	for i := range vsphereCluster.Spec.FailureDomains {
+		if vsphereCluster.Spec.FailureDomains[i].Name == name {
+			return &vsphereCluster.Spec.FailureDomains[i], nil
+		}
+	}
+	return nil, errors.Errorf("failure domain %q is not defined by VSphereCluster %s/%s",
+		name, vsphereCluster.Namespace, vsphereCluster.Name)
+}
+
+// ApplyFailureDomain copies the placement fields of the failure domain into
+// the VSphereVM's spec. The fields the failure domain does not set are left
+// untouched, so they may still be inherited from elsewhere.
+func ApplyFailureDomain(spec *infrav1.VSphereVMSpec, failureDomain *infrav1.VSphereFailureDomain) {
+	if failureDomain.Datacenter != "" {
+		spec.Datacenter = failureDomain.Datacenter
+	}
+	if failureDomain.ResourcePool != "" {
+		spec.ResourcePool = failureDomain.ResourcePool
+	} else if failureDomain.ComputeCluster != "" {
+		spec.ResourcePool = failureDomain.ComputeCluster + "/Resources"
+	}
+	if failureDomain.Folder != "" {
+		spec.Folder = failureDomain.Folder
+	}
+	if failureDomain.Datastore != "" {
+		spec.Datastore = failureDomain.Datastore
+	}
+	spec.HostGroup = failureDomain.HostGroup
+	for i := range spec.Network.Devices {
+		if i < len(failureDomain.Networks) {
+			spec.Network.Devices[i].NetworkName = failureDomain.Networks[i]
+		}
+	}
+}
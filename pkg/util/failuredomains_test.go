@@ -0,0 +1,117 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+func TestGetFailureDomains(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	vsphereCluster := &v1alpha3.VSphereCluster{}
+	g.Expect(util.GetFailureDomains(vsphereCluster)).To(gomega.BeNil())
+
+	vsphereCluster.Spec.FailureDomains = []v1alpha3.VSphereFailureDomain{
+		{Name: "az1", Datacenter: "dc1", ComputeCluster: "cluster1"},
+		{Name: "az2", Datacenter: "dc1", ComputeCluster: "cluster2", HostGroup: "hosts2", Datastore: "ds2"},
+		{Name: "workers", ControlPlane: pointer.BoolPtr(false)},
+	}
+	g.Expect(util.GetFailureDomains(vsphereCluster)).To(gomega.Equal(clusterv1.FailureDomains{
+		"az1": {
+			ControlPlane: true,
+			Attributes:   map[string]string{"datacenter": "dc1", "computeCluster": "cluster1"},
+		},
+		"az2": {
+			ControlPlane: true,
+			Attributes:   map[string]string{"datacenter": "dc1", "computeCluster": "cluster2", "hostGroup": "hosts2", "datastore": "ds2"},
+		},
+		"workers": {
+			ControlPlane: false,
+		},
+	}))
+}
+
+func TestApplyFailureDomain(t *testing.T) {
+	testCases := []struct {
+		name          string
+		failureDomain v1alpha3.VSphereFailureDomain
+		expected      v1alpha3.VSphereVMSpec
+	}{
+		{
+			name:          "empty failure domain",
+			failureDomain: v1alpha3.VSphereFailureDomain{Name: "az1"},
+			expected:      newVSphereVMSpec("dc0", "pool0", "ds0", "net0", "net1"),
+		},
+		{
+			name: "compute cluster",
+			failureDomain: v1alpha3.VSphereFailureDomain{
+				Name:           "az1",
+				Datacenter:     "dc1",
+				ComputeCluster: "cluster1",
+				Networks:       []string{"net2"},
+			},
+			expected: newVSphereVMSpec("dc1", "cluster1/Resources", "ds0", "net2", "net1"),
+		},
+		{
+			name: "resource pool in host group",
+			failureDomain: v1alpha3.VSphereFailureDomain{
+				Name:           "az1",
+				ComputeCluster: "cluster1",
+				HostGroup:      "hosts1",
+				ResourcePool:   "cluster1/Resources/pool1",
+				Datastore:      "ds1",
+				Networks:       []string{"net2", "net3", "net4"},
+			},
+			expected: func() v1alpha3.VSphereVMSpec {
+				spec := newVSphereVMSpec("dc0", "cluster1/Resources/pool1", "ds1", "net2", "net3")
+				spec.HostGroup = "hosts1"
+				return spec
+			}(),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := gomega.NewWithT(t)
+			spec := newVSphereVMSpec("dc0", "pool0", "ds0", "net0", "net1")
+			util.ApplyFailureDomain(&spec, &tc.failureDomain)
+			g.Expect(spec).To(gomega.Equal(tc.expected))
+		})
+	}
+}
+
+func newVSphereVMSpec(datacenter, resourcePool, datastore string, networks ...string) v1alpha3.VSphereVMSpec {
+	spec := v1alpha3.VSphereVMSpec{
+		VirtualMachineCloneSpec: v1alpha3.VirtualMachineCloneSpec{
+			Datacenter:   datacenter,
+			ResourcePool: resourcePool,
+			Datastore:    datastore,
+		},
+	}
+	for _, network := range networks {
+		spec.Network.Devices = append(spec.Network.Devices, v1alpha3.NetworkDeviceSpec{NetworkName: network})
+	}
+	return spec
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+)
+
+const (
+	ignitionFileMode    = 0644
+	networkdUnitDir     = "/etc/systemd/network"
+	ignitionDataURLBase = "data:;base64,"
+)
+
+// ignitionConfig is the subset of an Ignition config, of either spec version
+// 2 or 3, that is used to wrap a machine's bootstrap Ignition config.
+type ignitionConfig struct {
+	Ignition ignitionSection   `json:"ignition"`
+	Storage  *ignitionStorage  `json:"storage,omitempty"`
+	Networkd *ignitionNetworkd `json:"networkd,omitempty"`
+}
+
+type ignitionSection struct {
+	Version string                    `json:"version"`
+	Config  *ignitionConfigReferences `json:"config,omitempty"`
+}
+
+type ignitionConfigReferences struct {
+	Append []ignitionResource `json:"append,omitempty"`
+	Merge  []ignitionResource `json:"merge,omitempty"`
+}
+
+type ignitionResource struct {
+	Source string `json:"source"`
+}
+
+type ignitionStorage struct {
+	Files []ignitionFile `json:"files"`
+}
+
+type ignitionFile struct {
+	Filesystem string           `json:"filesystem,omitempty"`
+	Path       string           `json:"path"`
+	Mode       int              `json:"mode"`
+	Contents   ignitionResource `json:"contents"`
+}
+
+type ignitionNetworkd struct {
+	Units []ignitionUnit `json:"units"`
+}
+
+type ignitionUnit struct {
+	Name     string `json:"name"`
+	Contents string `json:"contents"`
+}
+
+// GetMachineIgnitionConfig returns an Ignition config for a given VSphereVM
+// that includes its bootstrap Ignition config, and sets its hostname and
+// configures its network devices with systemd-networkd units, since Ignition
+// has no equivalent of cloud-init's network metadata.
+//
+// The bootstrap config is merged into, or appended to, a config of the same
+// spec version, so spec version 2 and 3 configs are supported. The MAC
+// addresses of the network devices are taken from the network status, if
+// any, as they are for the cloud-init metadata.
+func GetMachineIgnitionConfig(hostname string, machine infrav1.VSphereVM, bootstrapData []byte, networkStatus ...infrav1.NetworkStatus) ([]byte, error) {
+	var bootstrapConfig struct {
+		Ignition struct {
+			Version string `json:"version"`
+		} `json:"ignition"`
+	}
+	if err := json.Unmarshal(bootstrapData, &bootstrapConfig); err != nil {
+		return nil, errors.Wrapf(err,
+			"error parsing ignition config for machine %s/%s/%s",
+			machine.Namespace, machine.ClusterName, machine.Name)
+	}
+	version := bootstrapConfig.Ignition.Version
+
+	files := []ignitionFile{
+		{
+			Path:     "/etc/hostname",
+			Contents: newIgnitionDataResource([]byte(hostname + "\n")), // note that hostname determines the Kubernetes node name
+		},
+	}
+	units, err := getNetworkdUnits(machine, networkStatus...)
+	if err != nil {
+		return nil, errors.Wrapf(err,
+			"error getting networkd units for machine %s/%s/%s",
+			machine.Namespace, machine.ClusterName, machine.Name)
+	}
+
+	config := ignitionConfig{
+		Ignition: ignitionSection{Version: version},
+	}
+	bootstrapResource := newIgnitionDataResource(bootstrapData)
+	switch {
+	case strings.HasPrefix(version, "2."):
+		config.Ignition.Config = &ignitionConfigReferences{Append: []ignitionResource{bootstrapResource}}
+		for i := range files {
+			files[i].Filesystem = "root"
+		}
+		config.Networkd = &ignitionNetworkd{Units: units}
+	case strings.HasPrefix(version, "3."):
+		config.Ignition.Config = &ignitionConfigReferences{Merge: []ignitionResource{bootstrapResource}}
+		for _, unit := range units {
+			files = append(files, ignitionFile{
+				Path:     networkdUnitDir + "/" + unit.Name,
+				Contents: newIgnitionDataResource([]byte(unit.Contents)),
+			})
+		}
+	default:
+		return nil, errors.Errorf(
+			"unsupported ignition config version %q for machine %s/%s/%s",
+			version, machine.Namespace, machine.ClusterName, machine.Name)
+	}
+	for i := range files {
+		files[i].Mode = ignitionFileMode
+	}
+	config.Storage = &ignitionStorage{Files: files}
+
+	return json.Marshal(config)
+}
+
+// getNetworkdUnits returns the units that name and configure each of the
+// machine's network devices. The machine's routes are configured on its
+// first network device.
+func getNetworkdUnits(machine infrav1.VSphereVM, networkStatus ...infrav1.NetworkStatus) ([]ignitionUnit, error) {
+	networkTpl := template.Must(template.New("network").Funcs(
+		template.FuncMap{
+			"dhcp": func(spec infrav1.NetworkDeviceSpec) string {
+				switch {
+				case spec.DHCP4 && spec.DHCP6:
+					return "yes"
+				case spec.DHCP4:
+					return "ipv4"
+				case spec.DHCP6:
+					return "ipv6"
+				default:
+					return "no"
+				}
+			},
+			"join": strings.Join,
+		}).Parse(networkdNetworkFormat))
+	linkTpl := template.Must(template.New("link").Parse(networkdLinkFormat))
+
+	var units []ignitionUnit
+	for i := range machine.Spec.Network.Devices {
+		device := machine.Spec.Network.Devices[i].DeepCopy()
+		if len(networkStatus) > 0 {
+			device.MACAddr = networkStatus[i].MACAddr
+		}
+		name := device.DeviceName
+		if name == "" {
+			name = fmt.Sprintf("eth%d", i)
+		}
+		routes := device.Routes
+		if i == 0 {
+			routes = append(routes, machine.Spec.Network.Routes...)
+		}
+
+		link := &bytes.Buffer{}
+		if err := linkTpl.Execute(link, struct {
+			MACAddr string
+			Name    string
+		}{
+			MACAddr: device.MACAddr,
+			Name:    name,
+		}); err != nil {
+			return nil, err
+		}
+		network := &bytes.Buffer{}
+		if err := networkTpl.Execute(network, struct {
+			Device *infrav1.NetworkDeviceSpec
+			Routes []infrav1.NetworkRouteSpec
+		}{
+			Device: device,
+			Routes: routes,
+		}); err != nil {
+			return nil, err
+		}
+
+		units = append(units,
+			ignitionUnit{Name: fmt.Sprintf("00-id%d.link", i), Contents: link.String()},
+			ignitionUnit{Name: fmt.Sprintf("00-id%d.network", i), Contents: network.String()},
+		)
+	}
+	return units, nil
+}
+
+func newIgnitionDataResource(data []byte) ignitionResource {
+	return ignitionResource{Source: ignitionDataURLBase + base64.StdEncoding.EncodeToString(data)}
+}
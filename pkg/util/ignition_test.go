@@ -0,0 +1,202 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/util"
+)
+
+const expectedNetworkUnit = `[Match]
+MACAddress=00:00:00:00:00
+
+[Network]
+DHCP=no
+Address=192.168.4.21/24
+Gateway=192.168.4.1
+DNS=1.1.1.1
+DNS=8.8.8.8
+Domains=vmware.ci example.com
+
+[Link]
+MTUBytes=9000
+
+[Route]
+Destination=10.0.0.0/8
+Gateway=192.168.4.254
+Metric=10
+
+[Route]
+Destination=172.16.0.0/12
+Gateway=192.168.4.253
+Metric=20
+`
+
+const expectedLinkUnit = `[Match]
+MACAddress=00:00:00:00:00
+
+[Link]
+Name=eth0
+`
+
+const expectedSecondNetworkUnit = `[Match]
+MACAddress=00:00:00:00:01
+
+[Network]
+DHCP=yes
+`
+
+type testIgnitionConfig struct {
+	Ignition struct {
+		Version string `json:"version"`
+		Config  struct {
+			Append []testIgnitionResource `json:"append"`
+			Merge  []testIgnitionResource `json:"merge"`
+		} `json:"config"`
+	} `json:"ignition"`
+	Storage struct {
+		Files []struct {
+			Filesystem string               `json:"filesystem"`
+			Path       string               `json:"path"`
+			Mode       int                  `json:"mode"`
+			Contents   testIgnitionResource `json:"contents"`
+		} `json:"files"`
+	} `json:"storage"`
+	Networkd struct {
+		Units []struct {
+			Name     string `json:"name"`
+			Contents string `json:"contents"`
+		} `json:"units"`
+	} `json:"networkd"`
+}
+
+type testIgnitionResource struct {
+	Source string `json:"source"`
+}
+
+func (r testIgnitionResource) data(t *testing.T) string {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(r.Source, "data:;base64,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func Test_GetMachineIgnitionConfig(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	machine := v1alpha3.VSphereVM{
+		Spec: v1alpha3.VSphereVMSpec{
+			VirtualMachineCloneSpec: v1alpha3.VirtualMachineCloneSpec{
+				Network: v1alpha3.NetworkSpec{
+					Devices: []v1alpha3.NetworkDeviceSpec{
+						{
+							NetworkName:   "network1",
+							IPAddrs:       []string{"192.168.4.21/24"},
+							Gateway4:      "192.168.4.1",
+							MTU:           pointer.Int64Ptr(9000),
+							Nameservers:   []string{"1.1.1.1", "8.8.8.8"},
+							SearchDomains: []string{"vmware.ci", "example.com"},
+							Routes: []v1alpha3.NetworkRouteSpec{
+								{To: "10.0.0.0/8", Via: "192.168.4.254", Metric: 10},
+							},
+						},
+						{
+							NetworkName: "network2",
+							DeviceName:  "ens224",
+							DHCP4:       true,
+							DHCP6:       true,
+						},
+					},
+					Routes: []v1alpha3.NetworkRouteSpec{
+						{To: "172.16.0.0/12", Via: "192.168.4.253", Metric: 20},
+					},
+				},
+			},
+		},
+	}
+	networkStatus := []v1alpha3.NetworkStatus{
+		{MACAddr: "00:00:00:00:00"},
+		{MACAddr: "00:00:00:00:01"},
+	}
+
+	t.Run("spec version 2", func(t *testing.T) {
+		bootstrapData := []byte(`{"ignition":{"version":"2.3.0"}}`)
+		data, err := util.GetMachineIgnitionConfig("test-vm", machine, bootstrapData, networkStatus...)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		var config testIgnitionConfig
+		g.Expect(json.Unmarshal(data, &config)).To(gomega.Succeed())
+		g.Expect(config.Ignition.Version).To(gomega.Equal("2.3.0"))
+		g.Expect(config.Ignition.Config.Append).To(gomega.HaveLen(1))
+		g.Expect(config.Ignition.Config.Append[0].data(t)).To(gomega.Equal(string(bootstrapData)))
+
+		g.Expect(config.Storage.Files).To(gomega.HaveLen(1))
+		g.Expect(config.Storage.Files[0].Filesystem).To(gomega.Equal("root"))
+		g.Expect(config.Storage.Files[0].Path).To(gomega.Equal("/etc/hostname"))
+		g.Expect(config.Storage.Files[0].Mode).To(gomega.Equal(0644))
+		g.Expect(config.Storage.Files[0].Contents.data(t)).To(gomega.Equal("test-vm\n"))
+
+		units := map[string]string{}
+		for _, unit := range config.Networkd.Units {
+			units[unit.Name] = unit.Contents
+		}
+		g.Expect(units).To(gomega.HaveLen(4))
+		g.Expect(units["00-id0.link"]).To(gomega.Equal(expectedLinkUnit))
+		g.Expect(units["00-id0.network"]).To(gomega.Equal(expectedNetworkUnit))
+		g.Expect(units["00-id1.link"]).To(gomega.ContainSubstring("Name=ens224\n"))
+		g.Expect(units["00-id1.network"]).To(gomega.Equal(expectedSecondNetworkUnit))
+	})
+
+	t.Run("spec version 3", func(t *testing.T) {
+		bootstrapData := []byte(`{"ignition":{"version":"3.1.0"}}`)
+		data, err := util.GetMachineIgnitionConfig("test-vm", machine, bootstrapData, networkStatus...)
+		g.Expect(err).NotTo(gomega.HaveOccurred())
+
+		var config testIgnitionConfig
+		g.Expect(json.Unmarshal(data, &config)).To(gomega.Succeed())
+		g.Expect(config.Ignition.Version).To(gomega.Equal("3.1.0"))
+		g.Expect(config.Ignition.Config.Merge).To(gomega.HaveLen(1))
+		g.Expect(config.Ignition.Config.Merge[0].data(t)).To(gomega.Equal(string(bootstrapData)))
+		g.Expect(config.Networkd.Units).To(gomega.BeEmpty())
+
+		files := map[string]string{}
+		for _, file := range config.Storage.Files {
+			g.Expect(file.Filesystem).To(gomega.BeEmpty())
+			files[file.Path] = file.Contents.data(t)
+		}
+		g.Expect(files).To(gomega.HaveLen(5))
+		g.Expect(files["/etc/hostname"]).To(gomega.Equal("test-vm\n"))
+		g.Expect(files["/etc/systemd/network/00-id0.link"]).To(gomega.Equal(expectedLinkUnit))
+		g.Expect(files["/etc/systemd/network/00-id0.network"]).To(gomega.Equal(expectedNetworkUnit))
+	})
+
+	t.Run("unsupported spec version", func(t *testing.T) {
+		_, err := util.GetMachineIgnitionConfig("test-vm", machine, []byte(`{"ignition":{"version":"1.0.0"}}`))
+		g.Expect(err).To(gomega.HaveOccurred())
+		_, err = util.GetMachineIgnitionConfig("test-vm", machine, []byte(`#cloud-config`))
+		g.Expect(err).To(gomega.HaveOccurred())
+	})
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-vsphere/api/v1alpha3"
+	"sigs.k8s.io/cluster-api-provider-vsphere/pkg/session"
+)
+
+// DefaultCASecretKey is the key in a Secret referenced by a CASecretRef that
+// contains the CA bundle when no key is specified.
+const DefaultCASecretKey = "ca.crt"
+
+// InheritTrust copies the settings used to verify the vSphere server's
+// certificate from the VSphereCluster into the clone spec, unless the clone
+// spec already specifies its own.
+func InheritTrust(spec *infrav1.VirtualMachineCloneSpec, vsphereCluster *infrav1.VSphereCluster) {
+	if spec.Thumbprint != "" || len(spec.CABundle) > 0 || spec.CASecretRef != nil {
+		return
+	}
+	spec.Thumbprint = vsphereCluster.Spec.Thumbprint
+	spec.CABundle = vsphereCluster.Spec.CABundle
+	if ref := vsphereCluster.Spec.CASecretRef; ref != nil {
+		spec.CASecretRef = ref.DeepCopy()
+	}
+}
+
+// GetTrust returns the settings used to verify the certificate of the
+// vSphere server the clone spec refers to. The Secret referenced by the
+// CASecretRef is read from the provided namespace and appended to the
+// CABundle.
+func GetTrust(
+	ctx context.Context,
+	controllerClient client.Client,
+	namespace string,
+	spec *infrav1.VirtualMachineCloneSpec) (session.Trust, error) {
+
+	trust := session.Trust{
+		Thumbprint: spec.Thumbprint,
+		CABundle:   append([]byte{}, spec.CABundle...),
+	}
+
+	if ref := spec.CASecretRef; ref != nil {
+		secret := &corev1.Secret{}
+		secretKey := client.ObjectKey{Namespace: namespace, Name: ref.Name}
+		if err := controllerClient.Get(ctx, secretKey, secret); err != nil {
+			return session.Trust{}, errors.Wrapf(err, "failed to get CA secret %s", secretKey)
+		}
+		key := ref.Key
+		if key == "" {
+			key = DefaultCASecretKey
+		}
+		data, ok := secret.Data[key]
+		if !ok || len(data) == 0 {
+			return session.Trust{}, errors.Errorf("CA secret %s is missing key %q", secretKey, key)
+		}
+		if len(trust.CABundle) > 0 {
+			trust.CABundle = append(trust.CABundle, '\n')
+		}
+		trust.CABundle = append(trust.CABundle, data...)
+	}
+
+	return trust, nil
+}